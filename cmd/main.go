@@ -6,13 +6,28 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/service"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/attempts"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/breached"
 	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/clients"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/encryption"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/hashing"
 	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/http/rest"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/jobs"
 	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/logger"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/mailer"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/repositories"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/vault"
 	"github.com/FacuBar/bookstore_utils-go/auth"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
 	"github.com/joho/godotenv"
+	"go.uber.org/zap"
 )
 
 func main() {
@@ -28,13 +43,238 @@ func main() {
 	if err != nil {
 		panic("error connecting grpc client")
 	}
+	sessions := clients.NewOauthSessionRevoker(oauthClient.CC)
 
 	RMQ, err := clients.NewRabbitMQ(os.Getenv("RMQ_URI"))
 	if err != nil {
 		panic("error connecting rabbitMQ")
 	}
 
-	server := rest.NewServer(&http.Server{Addr: os.Getenv("PORT")}, db, l, oauthClient, RMQ)
+	cardVault, err := vault.New(vault.SettingsFromEnv())
+	if err != nil {
+		panic("error opening card vault: " + err.Error())
+	}
+	untokenized, err := repositories.NewCardTokenizer(db, l, cardVault).Pending()
+	if err != nil {
+		panic("error checking for untokenized card numbers")
+	}
+	if untokenized > 0 {
+		panic("payment options still hold card numbers, run cmd/tokenizecards first")
+	}
+
+	cipher, err := encryption.NewKeyring(os.Getenv("ENCRYPTION_KEYS_DIR"), os.Getenv("ENCRYPTION_ACTIVE_KEY"), os.Getenv("ENCRYPTION_INDEX_KEY_PATH"))
+	if err != nil {
+		panic("error loading encryption keys")
+	}
+	unindexed, err := repositories.NewKeyRotator(db, l, cipher).PendingIndexes()
+	if err != nil {
+		panic("error checking for users without an email index")
+	}
+	if unindexed > 0 {
+		panic("users still lack an email index, run cmd/rotatekeys first")
+	}
+
+	var mailSender ports.Mailer
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		mailSender, err = mailer.NewSMTPMailer(os.Getenv("SMTP_ADDRESS"), os.Getenv("MAIL_FROM"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+		if err != nil {
+			panic("invalid MAIL_FROM")
+		}
+	case "outbox":
+		mailSender, err = mailer.NewOutboxMailer(os.Getenv("MAIL_OUTBOX_DIR"), os.Getenv("MAIL_FROM"))
+		if err != nil {
+			panic("error opening mail outbox")
+		}
+	default:
+		panic("invalid MAILER, expected smtp or outbox")
+	}
+
+	passwordRules := validation.PasswordRules{
+		RequireLower:       envBool("PASSWORD_REQUIRE_LOWER"),
+		RequireUpper:       envBool("PASSWORD_REQUIRE_UPPER"),
+		RequireDigit:       envBool("PASSWORD_REQUIRE_DIGIT"),
+		RequireSymbol:      envBool("PASSWORD_REQUIRE_SYMBOL"),
+		ForbidPersonalInfo: envBool("PASSWORD_FORBID_PERSONAL_INFO"),
+	}
+	passwordRules.MinLength, err = strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	if err != nil {
+		panic("invalid PASSWORD_MIN_LENGTH")
+	}
+	var breachedPasswords ports.BreachedPasswords
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		breachedPasswords, err = breached.NewFileList(path)
+		if err != nil {
+			panic("error opening breached passwords list")
+		}
+	}
+	passwordPolicy := service.NewPasswordPolicy(passwordRules, breachedPasswords)
+
+	var loginAttempts ports.LoginAttemptStore
+	switch os.Getenv("LOGIN_ATTEMPTS_STORE") {
+	case "mysql":
+		loginAttempts = repositories.NewLoginAttemptRepository(db, l)
+	case "memory":
+		loginAttempts = attempts.NewMemoryStore()
+	default:
+		panic("invalid LOGIN_ATTEMPTS_STORE, expected mysql or memory")
+	}
+	var loginThrottle service.LoginThrottle
+	loginThrottle.MaxAccountFailures, err = strconv.Atoi(os.Getenv("LOGIN_MAX_ACCOUNT_FAILURES"))
+	if err != nil {
+		panic("invalid LOGIN_MAX_ACCOUNT_FAILURES")
+	}
+	loginThrottle.MaxAddressFailures, err = strconv.Atoi(os.Getenv("LOGIN_MAX_ADDRESS_FAILURES"))
+	if err != nil {
+		panic("invalid LOGIN_MAX_ADDRESS_FAILURES")
+	}
+	loginThrottle.Window, err = time.ParseDuration(os.Getenv("LOGIN_FAILURE_WINDOW"))
+	if err != nil {
+		panic("invalid LOGIN_FAILURE_WINDOW")
+	}
+	loginThrottle.Lockout, err = time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"))
+	if err != nil {
+		panic("invalid LOGIN_LOCKOUT_DURATION")
+	}
+
+	bcryptScheme := hashing.NewBcrypt(envInt("BCRYPT_COST"))
+	argon2Scheme := hashing.NewArgon2id(hashing.Argon2Params{
+		Memory:      uint32(envInt("ARGON2_MEMORY")),
+		Iterations:  uint32(envInt("ARGON2_ITERATIONS")),
+		Parallelism: uint8(envInt("ARGON2_PARALLELISM")),
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+	var passwordHasher ports.PasswordHasher
+	switch os.Getenv("PASSWORD_HASH") {
+	case "argon2id":
+		passwordHasher = hashing.NewHasher(argon2Scheme, bcryptScheme)
+	case "bcrypt":
+		passwordHasher = hashing.NewHasher(bcryptScheme, argon2Scheme)
+	default:
+		panic("invalid PASSWORD_HASH, expected argon2id or bcrypt")
+	}
+
+	twoFactor := service.TwoFactorSettings{
+		Issuer:            os.Getenv("TWO_FACTOR_ISSUER"),
+		RequiredForAdmins: envBool("TWO_FACTOR_REQUIRED_FOR_ADMINS"),
+	}
+
+	server := rest.NewServer(&http.Server{Addr: os.Getenv("PORT")}, rest.ServerDeps{
+		DB:        db,
+		Logger:    l,
+		OAuth:     oauthClient,
+		RMQ:       RMQ,
+		Sessions:  sessions,
+		Vault:     cardVault,
+		Cipher:    cipher,
+		Mailer:    mailSender,
+		Policy:    passwordPolicy,
+		Attempts:  loginAttempts,
+		Throttle:  loginThrottle,
+		Hasher:    passwordHasher,
+		TwoFactor: twoFactor,
+		AppURL:    os.Getenv("APP_URL"),
+
+		TrustedProxies: envList("TRUSTED_PROXIES"),
+	})
+
+	expiringWindow, err := time.ParseDuration(os.Getenv("EXPIRING_CARDS_WINDOW"))
+	if err != nil {
+		panic("invalid EXPIRING_CARDS_WINDOW")
+	}
+	expiringInterval, err := time.ParseDuration(os.Getenv("EXPIRING_CARDS_INTERVAL"))
+	if err != nil {
+		panic("invalid EXPIRING_CARDS_INTERVAL")
+	}
+	notifier := service.NewExpiringCardsNotifier(
+		repositories.NewPaymentOptionRepository(db, l, cipher),
+		repositories.NewUsersRepository(db, l, cipher),
+		RMQ,
+		expiringWindow,
+	)
+
+	deletionGrace, err := time.ParseDuration(os.Getenv("USERS_DELETION_GRACE_PERIOD"))
+	if err != nil {
+		panic("invalid USERS_DELETION_GRACE_PERIOD")
+	}
+	purgeInterval, err := time.ParseDuration(os.Getenv("USERS_PURGE_INTERVAL"))
+	if err != nil {
+		panic("invalid USERS_PURGE_INTERVAL")
+	}
+	purger := service.NewDeletedUsersPurger(
+		repositories.NewUsersRepository(db, l, cipher),
+		repositories.NewPaymentOptionRepository(db, l, cipher),
+		cardVault,
+		RMQ,
+		deletionGrace,
+	)
+
+	var dormancy service.DormancySettings
+	dormancy.InactiveFor, err = time.ParseDuration(os.Getenv("USERS_DORMANCY_PERIOD"))
+	if err != nil {
+		panic("invalid USERS_DORMANCY_PERIOD")
+	}
+	dormancy.WarnBefore, err = time.ParseDuration(os.Getenv("USERS_DORMANCY_WARNING"))
+	if err != nil || dormancy.WarnBefore >= dormancy.InactiveFor {
+		panic("invalid USERS_DORMANCY_WARNING, it must be shorter than USERS_DORMANCY_PERIOD")
+	}
+	dormancyInterval, err := time.ParseDuration(os.Getenv("USERS_DORMANCY_INTERVAL"))
+	if err != nil {
+		panic("invalid USERS_DORMANCY_INTERVAL")
+	}
+	dormancyMarker := service.NewDormantUsersMarker(
+		repositories.NewUsersRepository(db, l, cipher),
+		mailSender,
+		RMQ,
+		l,
+		dormancy,
+	)
+
+	revocationRetryInterval, err := time.ParseDuration(os.Getenv("SESSION_REVOCATION_RETRY_INTERVAL"))
+	if err != nil {
+		panic("invalid SESSION_REVOCATION_RETRY_INTERVAL")
+	}
+	revocationRetrier := service.NewSessionRevocationRetrier(repositories.NewSessionRevocationRepository(db, l), sessions, l)
+
+	loginEventsRetention, err := time.ParseDuration(os.Getenv("LOGIN_EVENTS_RETENTION"))
+	if err != nil {
+		panic("invalid LOGIN_EVENTS_RETENTION")
+	}
+	loginEvents := repositories.NewLoginEventRepository(db, l, cipher)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go jobs.Every(jobsCtx, expiringInterval, l, "expiring_cards", func(now time.Time) rest_errors.RestErr {
+		published, err := notifier.NotifyExpiring(now)
+		l.Info("expiring cards announced", zap.Int("published", published))
+		return err
+	})
+	go jobs.Every(jobsCtx, purgeInterval, l, "purge_deleted_users", func(now time.Time) rest_errors.RestErr {
+		purged, err := purger.PurgeDeleted(now)
+		l.Info("deleted users purged", zap.Int("purged", purged))
+		return err
+	})
+	go jobs.Every(jobsCtx, dormancyInterval, l, "dormant_users", func(now time.Time) rest_errors.RestErr {
+		warned, err := dormancyMarker.WarnInactive(now)
+		l.Info("inactive users warned", zap.Int("warned", warned))
+		if err != nil {
+			return err
+		}
+		marked, err := dormancyMarker.MarkDormant(now)
+		l.Info("inactive users marked as dormant", zap.Int("marked", marked))
+		return err
+	})
+	go jobs.Every(jobsCtx, purgeInterval, l, "prune_login_events", func(now time.Time) rest_errors.RestErr {
+		pruned, err := loginEvents.Prune(now.Add(-loginEventsRetention))
+		l.Info("old login events pruned", zap.Int64("pruned", pruned))
+		return err
+	})
+
+	go jobs.Every(jobsCtx, revocationRetryInterval, l, "retry_session_revocations", func(now time.Time) rest_errors.RestErr {
+		revoked, err := revocationRetrier.RetryRevocations()
+		l.Info("pending session revocations retried", zap.Int("revoked", revoked))
+		return err
+	})
 
 	go server.Start()
 
@@ -43,9 +283,37 @@ func main() {
 
 	<-quit
 	log.Println("Shutdown server ...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	server.Stop(ctx)
 }
+
+func envBool(key string) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		panic("invalid " + key)
+	}
+	return value
+}
+
+// envList splits a comma separated variable, empty when it isn't set.
+func envList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func envInt(key string) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		panic("invalid " + key)
+	}
+	return value
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/clients"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/encryption"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/logger"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/repositories"
+	"github.com/joho/godotenv"
+)
+
+// rotatekeys re-encrypts every personal data column with the key set in
+// ENCRYPTION_ACTIVE_KEY. To rotate: add the new key file to the keyring,
+// point ENCRYPTION_ACTIVE_KEY at it, restart the servers and then run this
+// command. Old key files can be removed once it finishes without errors.
+//
+// It also encrypts the users stored before personal data was, and fills in
+// their email blind index: run it once after migration 000006, the servers
+// refuse to start until then.
+func main() {
+	if err := godotenv.Load(); err != nil {
+		panic("Error loading .env file")
+	}
+
+	batchSize := 500
+	if len(os.Args) > 1 {
+		size, err := strconv.Atoi(os.Args[1])
+		if err != nil || size < 1 {
+			log.Fatalf("usage: %s [batch size]", os.Args[0])
+		}
+		batchSize = size
+	}
+
+	db := clients.ConnectDB()
+	defer db.Close()
+	l := logger.NewUserLogger()
+
+	cipher, err := encryption.NewKeyring(os.Getenv("ENCRYPTION_KEYS_DIR"), os.Getenv("ENCRYPTION_ACTIVE_KEY"), os.Getenv("ENCRYPTION_INDEX_KEY_PATH"))
+	if err != nil {
+		log.Fatalf("error loading encryption keys: %v", err)
+	}
+
+	stats, err := repositories.NewKeyRotator(db, l, cipher).Rotate(batchSize)
+	for table, rotated := range stats.Rotated {
+		log.Printf("%s: %d rows rotated", table, rotated)
+	}
+	for table, changed := range stats.Changed {
+		log.Printf("%s: %d rows changed while rotating, left untouched", table, changed)
+	}
+	if err != nil {
+		log.Fatalf("error rotating keys: %v", err)
+	}
+	log.Println("keys rotated")
+}
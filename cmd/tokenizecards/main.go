@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/clients"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/logger"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/repositories"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/vault"
+	"github.com/joho/godotenv"
+)
+
+// tokenizecards moves the card numbers of the payment options saved before
+// migration 000002 into the vault configured by CARD_VAULT. Run it after
+// migrating and before starting the servers, which refuse to start while any
+// payment option still holds a card number. It can be run again if it
+// fails halfway.
+func main() {
+	if err := godotenv.Load(); err != nil {
+		panic("Error loading .env file")
+	}
+
+	batchSize := 500
+	if len(os.Args) > 1 {
+		size, err := strconv.Atoi(os.Args[1])
+		if err != nil || size < 1 {
+			log.Fatalf("usage: %s [batch size]", os.Args[0])
+		}
+		batchSize = size
+	}
+
+	db := clients.ConnectDB()
+	defer db.Close()
+	l := logger.NewUserLogger()
+
+	cardVault, err := vault.New(vault.SettingsFromEnv())
+	if err != nil {
+		log.Fatalf("error opening card vault: %v", err)
+	}
+
+	stats, err := repositories.NewCardTokenizer(db, l, cardVault).Tokenize(batchSize)
+	log.Printf("%d payment options tokenized", stats.Tokenized)
+	if stats.Changed > 0 {
+		log.Printf("%d payment options changed while tokenizing, left untouched", stats.Changed)
+	}
+	if err != nil {
+		log.Fatalf("error tokenizing card numbers: %v", err)
+	}
+	log.Println("card numbers tokenized")
+}
@@ -0,0 +1,5 @@
+ALTER TABLE `payment_options` DROP COLUMN `last4`;
+
+ALTER TABLE `payment_options` CHANGE `card_token` `card_number` varchar(255) NOT NULL;
+
+ALTER TABLE `payment_options` ADD COLUMN `cvv` int NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+ALTER TABLE `payment_options` DROP COLUMN `cvv`;
+
+ALTER TABLE `payment_options` CHANGE `card_number` `card_token` varchar(255) NOT NULL;
+
+ALTER TABLE `payment_options` ADD COLUMN `last4` char(4) NOT NULL AFTER `card_token`;
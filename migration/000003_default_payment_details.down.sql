@@ -0,0 +1,11 @@
+DROP INDEX `payment_options_default_unique` ON `payment_options`;
+
+ALTER TABLE `payment_options` DROP COLUMN `default_user_id`;
+
+ALTER TABLE `payment_options` DROP COLUMN `is_default`;
+
+DROP INDEX `shipping_addresses_default_unique` ON `shipping_addresses`;
+
+ALTER TABLE `shipping_addresses` DROP COLUMN `default_user_id`;
+
+ALTER TABLE `shipping_addresses` DROP COLUMN `is_default`;
@@ -0,0 +1,15 @@
+ALTER TABLE `shipping_addresses` ADD COLUMN `is_default` boolean NOT NULL DEFAULT false;
+
+ALTER TABLE `shipping_addresses` ADD COLUMN `default_user_id` bigint AS (IF(`is_default`, `user_id`, NULL)) STORED;
+
+CREATE UNIQUE INDEX `shipping_addresses_default_unique` ON `shipping_addresses` (`default_user_id`);
+
+ALTER TABLE `payment_options` ADD COLUMN `is_default` boolean NOT NULL DEFAULT false;
+
+ALTER TABLE `payment_options` ADD COLUMN `default_user_id` bigint AS (IF(`is_default`, `user_id`, NULL)) STORED;
+
+CREATE UNIQUE INDEX `payment_options_default_unique` ON `payment_options` (`default_user_id`);
+
+UPDATE `shipping_addresses` s JOIN (SELECT MIN(`id`) AS `id` FROM `shipping_addresses` GROUP BY `user_id`) f ON s.`id` = f.`id` SET s.`is_default` = true;
+
+UPDATE `payment_options` p JOIN (SELECT MIN(`id`) AS `id` FROM `payment_options` GROUP BY `user_id`) f ON p.`id` = f.`id` SET p.`is_default` = true;
@@ -0,0 +1,3 @@
+DROP INDEX `payment_options_index_4` ON `payment_options`;
+
+DROP TABLE `payment_option_notifications`;
@@ -0,0 +1,11 @@
+CREATE TABLE `payment_option_notifications` (
+  `payment_option_id` bigint NOT NULL,
+  `expiry_year` int NOT NULL,
+  `expiry_month` int NOT NULL,
+  `notified_at` datetime NOT NULL,
+  PRIMARY KEY (`payment_option_id`, `expiry_year`, `expiry_month`)
+);
+
+ALTER TABLE `payment_option_notifications` ADD FOREIGN KEY (`payment_option_id`) REFERENCES `payment_options` (`id`) ON DELETE CASCADE;
+
+CREATE INDEX `payment_options_index_4` ON `payment_options` (`expiry_year`, `expiry_month`);
@@ -0,0 +1,7 @@
+ALTER TABLE `payment_options` DROP FOREIGN KEY `payment_options_billing_address_fk`;
+
+ALTER TABLE `payment_options` DROP COLUMN `billing_same_as_shipping`;
+
+ALTER TABLE `payment_options` DROP COLUMN `billing_address_id`;
+
+DROP TABLE `billing_addresses`;
@@ -0,0 +1,20 @@
+CREATE TABLE `billing_addresses` (
+  `id` bigint PRIMARY KEY AUTO_INCREMENT,
+  `payment_option_id` bigint UNIQUE NOT NULL,
+  `email_invoice` varchar(255) NOT NULL,
+  `full_name` varchar(255) NOT NULL,
+  `address_line1` varchar(255) NOT NULL,
+  `address_line2` varchar(255),
+  `city` varchar(255) NOT NULL,
+  `state` varchar(255),
+  `post_code` varchar(255) NOT NULL,
+  `country` varchar(255) NOT NULL
+);
+
+ALTER TABLE `billing_addresses` ADD FOREIGN KEY (`payment_option_id`) REFERENCES `payment_options` (`id`) ON DELETE CASCADE;
+
+ALTER TABLE `payment_options` ADD COLUMN `billing_address_id` bigint;
+
+ALTER TABLE `payment_options` ADD COLUMN `billing_same_as_shipping` boolean NOT NULL DEFAULT false;
+
+ALTER TABLE `payment_options` ADD CONSTRAINT `payment_options_billing_address_fk` FOREIGN KEY (`billing_address_id`) REFERENCES `shipping_addresses` (`id`);
@@ -0,0 +1,15 @@
+ALTER TABLE `payment_options` MODIFY `name_on_card` varchar(255) NOT NULL;
+
+ALTER TABLE `billing_addresses` MODIFY `email_invoice` varchar(255) NOT NULL, MODIFY `full_name` varchar(255) NOT NULL, MODIFY `address_line1` varchar(255) NOT NULL, MODIFY `address_line2` varchar(255), MODIFY `post_code` varchar(255) NOT NULL;
+
+ALTER TABLE `shipping_addresses` MODIFY `email_invoice` varchar(255) NOT NULL, MODIFY `full_name` varchar(255) NOT NULL, MODIFY `address_line1` varchar(255) NOT NULL, MODIFY `address_line2` varchar(255), MODIFY `post_code` varchar(255) NOT NULL;
+
+DROP INDEX `users_email_bidx_unique` ON `users`;
+
+ALTER TABLE `users` DROP COLUMN `email_bidx`;
+
+ALTER TABLE `users` MODIFY `email` varchar(255) NOT NULL, MODIFY `first_name` varchar(255) NOT NULL, MODIFY `last_name` varchar(255) NOT NULL;
+
+CREATE UNIQUE INDEX `email` ON `users` (`email`);
+
+CREATE INDEX `users_index_1` ON `users` (`email`);
@@ -0,0 +1,15 @@
+ALTER TABLE `users` DROP INDEX `email`;
+
+DROP INDEX `users_index_1` ON `users`;
+
+ALTER TABLE `users` MODIFY `email` varchar(1024) NOT NULL, MODIFY `first_name` varchar(1024) NOT NULL, MODIFY `last_name` varchar(1024) NOT NULL;
+
+ALTER TABLE `users` ADD COLUMN `email_bidx` char(64);
+
+CREATE UNIQUE INDEX `users_email_bidx_unique` ON `users` (`email_bidx`);
+
+ALTER TABLE `shipping_addresses` MODIFY `email_invoice` varchar(1024) NOT NULL, MODIFY `full_name` varchar(1024) NOT NULL, MODIFY `address_line1` varchar(1024) NOT NULL, MODIFY `address_line2` varchar(1024), MODIFY `post_code` varchar(1024) NOT NULL;
+
+ALTER TABLE `billing_addresses` MODIFY `email_invoice` varchar(1024) NOT NULL, MODIFY `full_name` varchar(1024) NOT NULL, MODIFY `address_line1` varchar(1024) NOT NULL, MODIFY `address_line2` varchar(1024), MODIFY `post_code` varchar(1024) NOT NULL;
+
+ALTER TABLE `payment_options` MODIFY `name_on_card` varchar(1024) NOT NULL;
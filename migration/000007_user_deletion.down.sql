@@ -0,0 +1,3 @@
+DROP INDEX `users_index_2` ON `users`;
+
+ALTER TABLE `users` DROP COLUMN `deleted_at`;
@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD COLUMN `deleted_at` datetime;
+
+CREATE INDEX `users_index_2` ON `users` (`status`, `deleted_at`);
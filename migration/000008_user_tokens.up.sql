@@ -0,0 +1,13 @@
+CREATE TABLE `user_tokens` (
+  `id` bigint PRIMARY KEY AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `purpose` varchar(32) NOT NULL,
+  `token_hash` char(64) UNIQUE NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `used_at` datetime,
+  `date_created` datetime NOT NULL
+);
+
+ALTER TABLE `user_tokens` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+CREATE INDEX `user_tokens_index_0` ON `user_tokens` (`user_id`, `purpose`);
@@ -0,0 +1,6 @@
+CREATE TABLE `login_attempts` (
+  `key_hash` char(64) PRIMARY KEY,
+  `failures` int NOT NULL DEFAULT 0,
+  `last_failure` datetime,
+  `locked_until` datetime
+);
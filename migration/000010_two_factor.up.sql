@@ -0,0 +1,21 @@
+CREATE TABLE `user_two_factor` (
+  `id` bigint PRIMARY KEY AUTO_INCREMENT,
+  `user_id` bigint UNIQUE NOT NULL,
+  `secret` varchar(255),
+  `enabled_at` datetime,
+  `last_used_step` bigint NOT NULL DEFAULT 0,
+  `required` boolean NOT NULL DEFAULT false
+);
+
+CREATE TABLE `user_recovery_codes` (
+  `id` bigint PRIMARY KEY AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `used_at` datetime
+);
+
+ALTER TABLE `user_two_factor` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+ALTER TABLE `user_recovery_codes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX `user_recovery_codes_index_0` ON `user_recovery_codes` (`user_id`, `code_hash`);
@@ -0,0 +1,17 @@
+CREATE TABLE `login_events` (
+  `id` bigint PRIMARY KEY AUTO_INCREMENT,
+  `user_id` bigint,
+  `success` boolean NOT NULL,
+  `reason` varchar(32),
+  `ip` varchar(1024) NOT NULL,
+  `user_agent` varchar(1024) NOT NULL,
+  `fingerprint` char(64) NOT NULL,
+  `new_device` boolean NOT NULL DEFAULT false,
+  `created_at` datetime NOT NULL
+);
+
+ALTER TABLE `login_events` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+CREATE INDEX `login_events_index_0` ON `login_events` (`user_id`, `fingerprint`);
+
+CREATE INDEX `login_events_index_1` ON `login_events` (`created_at`);
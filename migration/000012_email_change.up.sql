@@ -0,0 +1 @@
+ALTER TABLE `user_tokens` ADD COLUMN `payload` varchar(1024);
@@ -0,0 +1,16 @@
+CREATE TABLE `access_tokens` (
+  `id` bigint PRIMARY KEY AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `name` varchar(64) NOT NULL,
+  `hint` varchar(16) NOT NULL,
+  `scopes` varchar(255) NOT NULL,
+  `token_hash` char(64) UNIQUE NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `last_used_at` datetime,
+  `revoked_at` datetime,
+  `date_created` datetime NOT NULL
+);
+
+ALTER TABLE `access_tokens` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+CREATE INDEX `access_tokens_index_0` ON `access_tokens` (`user_id`, `revoked_at`);
@@ -0,0 +1,7 @@
+UPDATE `users` SET `status`='active' WHERE `status`='dormant';
+
+DROP INDEX `users_index_3` ON `users`;
+
+ALTER TABLE `users` DROP COLUMN `dormancy_warned_at`;
+
+ALTER TABLE `users` DROP COLUMN `last_login_at`;
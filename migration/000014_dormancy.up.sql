@@ -0,0 +1,5 @@
+ALTER TABLE `users` ADD COLUMN `last_login_at` datetime;
+
+ALTER TABLE `users` ADD COLUMN `dormancy_warned_at` datetime;
+
+CREATE INDEX `users_index_3` ON `users` (`status`, `dormancy_warned_at`);
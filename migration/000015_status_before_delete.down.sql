@@ -0,0 +1 @@
+ALTER TABLE `users` DROP COLUMN `status_before_delete`;
@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD COLUMN `status_before_delete` varchar(255);
+
+UPDATE `users` SET `status_before_delete`='active' WHERE `status`='deleted';
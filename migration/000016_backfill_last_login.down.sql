@@ -0,0 +1,2 @@
+-- the backfilled times can't be told apart from real logins, they are kept
+DO 0;
@@ -0,0 +1 @@
+UPDATE `users` SET `last_login_at`=UTC_TIMESTAMP() WHERE `last_login_at` IS NULL;
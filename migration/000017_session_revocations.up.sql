@@ -0,0 +1,8 @@
+CREATE TABLE `session_revocations` (
+  `user_id` bigint PRIMARY KEY,
+  `requested_at` datetime NOT NULL
+);
+
+ALTER TABLE `session_revocations` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+CREATE INDEX `session_revocations_index_0` ON `session_revocations` (`requested_at`);
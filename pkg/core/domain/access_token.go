@@ -0,0 +1,55 @@
+package domain
+
+// AccessTokenPrefix starts every personal access token, so leaked ones are
+// easy to spot and tell apart from OAuth access tokens.
+const AccessTokenPrefix = "bpat_"
+
+const (
+	ScopeUsersRead           = "users:read"
+	ScopeAddressesRead       = "addresses:read"
+	ScopeAddressesWrite      = "addresses:write"
+	ScopePaymentOptionsRead  = "payment_options:read"
+	ScopePaymentOptionsWrite = "payment_options:write"
+)
+
+// AccessTokenScopes lists the scopes a personal access token can be granted.
+var AccessTokenScopes = []string{
+	ScopeUsersRead,
+	ScopeAddressesRead,
+	ScopeAddressesWrite,
+	ScopePaymentOptionsRead,
+	ScopePaymentOptionsWrite,
+}
+
+// AccessToken is a long-lived credential a user creates for scripts and
+// integrations. Only a hash of the token is stored, Hint is enough of it for
+// the user to recognize it.
+type AccessToken struct {
+	Id          int64    `json:"id"`
+	UserID      int64    `json:"user_id"`
+	Name        string   `json:"name"`
+	Hint        string   `json:"hint"`
+	Scopes      []string `json:"scopes"`
+	TokenHash   string   `json:"-"`
+	ExpiresAt   string   `json:"expires_at"`
+	LastUsedAt  string   `json:"last_used_at,omitempty"`
+	DateCreated string   `json:"date_created"`
+	RevokedAt   string   `json:"-"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAccessToken is returned when a token is created, the only time the
+// token itself is shown.
+type NewAccessToken struct {
+	AccessToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// LoginAttempts counts the failed logins of an account or of a client
+// address. A zero value means nothing was recorded.
+type LoginAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AccountLocked is published as users.event.account_locked when too many
+// failed logins lock an account for a while.
+type AccountLocked struct {
+	UserID      int64  `json:"user_id"`
+	Email       string `json:"email"`
+	FirstName   string `json:"first_name"`
+	Failures    int    `json:"failures"`
+	LockedUntil string `json:"locked_until"`
+}
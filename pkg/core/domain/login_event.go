@@ -0,0 +1,47 @@
+package domain
+
+// ClientInfo describes the client a request comes from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+const (
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonInvalidCode        = "invalid_code"
+	LoginReasonAccountLocked      = "account_locked"
+	LoginReasonTooManyAttempts    = "too_many_attempts"
+	LoginReasonAccountBlocked     = "account_blocked"
+	// LoginReasonTwoFactorPending marks a right password whose login
+	// challenge is still to be completed, which is recorded apart.
+	LoginReasonTwoFactorPending = "two_factor_pending"
+)
+
+// LoginEvent records a login attempt on an account. Reason tells why it
+// didn't succeed.
+type LoginEvent struct {
+	Id        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason,omitempty"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	// Fingerprint identifies the device of the client, see NewDevice.
+	Fingerprint string `json:"-"`
+	// NewDevice is set on successful logins from a device the user never
+	// logged in from before.
+	NewDevice bool   `json:"new_device"`
+	CreatedAt string `json:"created_at"`
+}
+
+// NewDeviceLogin is published as users.event.new_device_login when a user
+// logs in from a device they never used, so they can react if it wasn't
+// them.
+type NewDeviceLogin struct {
+	UserID     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	FirstName  string `json:"first_name"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	OccurredAt string `json:"occurred_at"`
+}
@@ -3,13 +3,54 @@ package domain
 type PaymentOption struct {
 	UserID int64 `json:"user_id"`
 
-	Id          int64  `json:"id"`
-	CardType    string `json:"card_type"`
-	CardNumber  string `json:"card_number"`
-	ExpiryMonth int    `json:"expiry_month"`
-	ExpiryYear  int    `json:"expiry_year"`
-	NameOnCard  string `json:"name_on_card"`
-	CVV         string `json:"cvv"`
-
-	BillingAddress ShippingAddress `json:"billing_address"`
+	Id        int64  `json:"id"`
+	CardType  string `json:"card_type"`
+	CardToken string `json:"-"`
+	Last4     string `json:"last4"`
+	// MaskedNumber is the only form of the card number included in regular
+	// responses, the full number requires a step-up reveal.
+	MaskedNumber string `json:"masked_number"`
+	ExpiryMonth  int    `json:"expiry_month"`
+	ExpiryYear   int    `json:"expiry_year"`
+	NameOnCard   string `json:"name_on_card"`
+	IsDefault    bool   `json:"is_default"`
+
+	// CardNumber and CVV are only populated while a card is being created.
+	// The number is exchanged for CardToken and the CVV is discarded, so
+	// neither is ever persisted nor serialized.
+	CardNumber string `json:"-"`
+	CVV        string `json:"-"`
+
+	// A payment option has at most one billing address source: an entry of
+	// the address book (BillingAddressID), the default shipping address of
+	// the user (BillingSameAsShipping), or an inline address of its own.
+	// BillingAddress always holds the resolved address when reading.
+	BillingAddressID      int64            `json:"billing_address_id,omitempty"`
+	BillingSameAsShipping bool             `json:"billing_same_as_shipping"`
+	BillingAddress        *ShippingAddress `json:"billing_address,omitempty"`
+}
+
+// PaymentCardDetails holds the full card data of a payment option. It is
+// only returned by the step-up reveal, after the owner re-entered their
+// password.
+type PaymentCardDetails struct {
+	PaymentOptionID int64  `json:"payment_option_id"`
+	CardType        string `json:"card_type"`
+	CardNumber      string `json:"card_number"`
+	ExpiryMonth     int    `json:"expiry_month"`
+	ExpiryYear      int    `json:"expiry_year"`
+	NameOnCard      string `json:"name_on_card"`
+}
+
+// PaymentOptionExpiring is published once per card and expiry date when the
+// card is about to expire, so customers can be asked to replace it.
+type PaymentOptionExpiring struct {
+	UserID          int64  `json:"user_id"`
+	PaymentOptionID int64  `json:"payment_option_id"`
+	Email           string `json:"email"`
+	FirstName       string `json:"first_name"`
+	CardType        string `json:"card_type"`
+	Last4           string `json:"last4"`
+	ExpiryMonth     int    `json:"expiry_month"`
+	ExpiryYear      int    `json:"expiry_year"`
 }
@@ -0,0 +1,8 @@
+package domain
+
+// SessionRevocation is a revocation of the sessions of a user the oauth API
+// couldn't be reached for, kept until a retry goes through.
+type SessionRevocation struct {
+	UserID      int64
+	RequestedAt string
+}
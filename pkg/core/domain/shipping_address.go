@@ -1,6 +1,7 @@
 package domain
 
 type ShippingAddress struct {
+	Id     int64 `json:"id"`
 	UserId int64 `json:"user_id"`
 
 	EmailInvoice string `json:"email_invoice"`
@@ -11,4 +12,33 @@ type ShippingAddress struct {
 	State        string `json:"state,omitempty"`
 	PostCode     string `json:"post_code"`
 	Country      string `json:"country"`
+	IsDefault    bool   `json:"is_default"`
+}
+
+const (
+	ImportRowImported = "imported"
+	ImportRowValid    = "valid"
+	ImportRowInvalid  = "invalid"
+	ImportRowFailed   = "failed"
+)
+
+// AddressImportRow is a single entry of an address book import. Errors maps
+// the offending fields to what is wrong with them, parse errors of the row
+// itself are reported under "row".
+type AddressImportRow struct {
+	Row     int               `json:"row"`
+	Status  string            `json:"status"`
+	Address ShippingAddress   `json:"address"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// AddressImportReport summarizes an address book import. On a dry run rows
+// are only validated, so none of them is imported.
+type AddressImportReport struct {
+	DryRun   bool               `json:"dry_run"`
+	Total    int                `json:"total"`
+	Imported int                `json:"imported"`
+	Invalid  int                `json:"invalid"`
+	Failed   int                `json:"failed"`
+	Rows     []AddressImportRow `json:"rows"`
 }
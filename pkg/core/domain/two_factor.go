@@ -0,0 +1,45 @@
+package domain
+
+// TwoFactor holds the TOTP settings of a user. Secret is set once enrollment
+// starts, but codes are only asked for once EnabledAt is.
+type TwoFactor struct {
+	UserID int64
+	Secret string
+	// EnabledAt is empty until the user confirms enrollment with a code.
+	EnabledAt string
+	// LastUsedStep is the time step of the last accepted code, codes of that
+	// step or earlier ones are rejected so they can't be replayed.
+	LastUsedStep int64
+	// Required is set by admins, users can't disable two-factor
+	// authentication and have to enroll on their next login.
+	Required bool
+}
+
+// Enabled reports whether the user has to send a code when logging in.
+func (t *TwoFactor) Enabled() bool {
+	return t.Secret != "" && t.EnabledAt != ""
+}
+
+// TwoFactorSecret is sent to a user enrolling, to add it to an authenticator
+// app.
+type TwoFactorSecret struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorEnrollment is returned once enrollment is confirmed. Recovery
+// codes are only ever shown this once. User is set when enrolling while
+// logging in, which completes the login.
+type TwoFactorEnrollment struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	User          *User    `json:"user,omitempty"`
+}
+
+// LoginChallenge is returned by a login with the right password that still
+// needs a second factor. Enroll is set when the account requires two-factor
+// authentication but the user hasn't set it up yet.
+type LoginChallenge struct {
+	Challenge string `json:"challenge"`
+	ExpiresAt string `json:"expires_at"`
+	Enroll    bool   `json:"enroll"`
+}
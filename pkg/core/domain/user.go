@@ -10,4 +10,56 @@ type User struct {
 	LastModified string `json:"-"`
 	Status       string `json:"-"`
 	Role         string `json:"role"`
+	// PendingEmail is the address an update asked to change to, until it's
+	// confirmed. It's only set in the response to that update.
+	PendingEmail string `json:"pending_email,omitempty"`
+	DeletedAt    string `json:"-"`
+	// LastLoginAt is empty for users that never logged in.
+	LastLoginAt string `json:"-"`
+}
+
+const (
+	DeletionRequested = "requested"
+	DeletionCancelled = "cancelled"
+	DeletionPurged    = "purged"
+)
+
+// UserDeletion is published as users.event.delete on every step of the
+// deletion of an account: when it's requested, when a login cancels it
+// during the grace period and when the account is finally purged. Purged
+// events carry no personal data.
+type UserDeletion struct {
+	UserID    int64  `json:"user_id"`
+	Step      string `json:"step"`
+	Email     string `json:"email,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// PasswordReset is published as users.event.password_reset when a user sets
+// a new password through a reset link.
+type PasswordReset struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	ResetAt   string `json:"reset_at"`
+}
+
+const (
+	SecurityEventPasswordChanged    = "password_changed"
+	SecurityEventTwoFactorEnabled   = "two_factor_enabled"
+	SecurityEventTwoFactorDisabled  = "two_factor_disabled"
+	SecurityEventTwoFactorReset     = "two_factor_reset"
+	SecurityEventEmailChanged       = "email_changed"
+	SecurityEventAccessTokenCreated = "access_token_created"
+)
+
+// SecurityEvent is published as users.event.security to notify a user of a
+// change to the way they log in, so they can react if it wasn't them.
+type SecurityEvent struct {
+	UserID     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	FirstName  string `json:"first_name"`
+	Type       string `json:"type"`
+	OccurredAt string `json:"occurred_at"`
 }
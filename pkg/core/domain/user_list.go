@@ -0,0 +1,72 @@
+package domain
+
+// Fields users can be sorted on when listed. Names and emails are stored
+// encrypted, so they can't be sorted on.
+const (
+	UserSortId          = "id"
+	UserSortDateCreated = "date_created"
+	UserSortStatus      = "status"
+	UserSortRole        = "role"
+)
+
+// IsValidUserSort reports whether users can be sorted on field.
+func IsValidUserSort(field string) bool {
+	switch field {
+	case UserSortId, UserSortDateCreated, UserSortStatus, UserSortRole:
+		return true
+	}
+	return false
+}
+
+// UserFilter selects the users to list and the page to return. Empty fields
+// don't filter. Purged users are only listed when Status asks for them.
+type UserFilter struct {
+	Status string
+	Role   string
+	// CreatedFrom and CreatedTo bound the creation date, both included.
+	CreatedFrom string
+	CreatedTo   string
+	// Prefix matches the start of the email, the first name or the last
+	// name, ignoring case.
+	Prefix string
+
+	Sort string
+	Desc bool
+
+	Limit  int
+	Offset int
+	// After is the position of the last user of the previous page, the page
+	// starts right after it.
+	After *UserCursor
+}
+
+// UserCursor is the position of a user in a listing, the value of the sort
+// field and the id that breaks ties.
+type UserCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	Id    int64  `json:"id"`
+}
+
+// ListedUser is a user as listed to admins, along with the state of the
+// account.
+type ListedUser struct {
+	Id          int64  `json:"id"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	Status      string `json:"status"`
+	DateCreated string `json:"date_created"`
+	LastLoginAt string `json:"last_login_at,omitempty"`
+}
+
+// UserPage is a page of a user listing. Total counts every user matching the
+// filter, not only the ones in the page. NextCursor is empty on the last
+// page.
+type UserPage struct {
+	Users      []ListedUser `json:"users"`
+	Total      int          `json:"total"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+const (
+	StatusPendingVerification = "pending_verification"
+	StatusActive              = "active"
+	StatusSuspended           = "suspended"
+	StatusInactive            = "inactive"
+	StatusDeleted             = "deleted"
+	// StatusPurged is only reached through the purge of a deleted user, its
+	// row is anonymized and it can't leave this state.
+	StatusPurged = "purged"
+	// StatusDormant is reached by accounts nobody logged in to for a long
+	// time, their next login makes them active again.
+	StatusDormant = "dormant"
+)
+
+// statusTransitions lists the states each state can move to.
+var statusTransitions = map[string][]string{
+	StatusPendingVerification: {StatusActive, StatusDeleted},
+	StatusActive:              {StatusSuspended, StatusInactive, StatusDormant, StatusDeleted},
+	StatusSuspended:           {StatusActive, StatusInactive, StatusDeleted},
+	StatusInactive:            {StatusActive, StatusDeleted},
+	StatusDormant:             {StatusActive, StatusSuspended, StatusInactive, StatusDeleted},
+	StatusDeleted:             {StatusActive, StatusPurged},
+	StatusPurged:              {},
+}
+
+// IsValidStatus reports whether status is one of the account states.
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an account in state from may move to state
+// to. Staying in the same state is not a transition.
+func CanTransition(from, to string) bool {
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UserStatusChanged is published as users.event.status_changed on every
+// transition of the state of an account.
+type UserStatusChanged struct {
+	UserID    int64  `json:"user_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	ChangedAt string `json:"changed_at"`
+}
+
+// DormancyWarning is published as users.event.dormancy_warning when a user
+// is told their account will become dormant unless they log in before
+// DormantAt.
+type DormancyWarning struct {
+	UserID      int64  `json:"user_id"`
+	Email       string `json:"email"`
+	FirstName   string `json:"first_name"`
+	LastLoginAt string `json:"last_login_at,omitempty"`
+	DormantAt   string `json:"dormant_at"`
+}
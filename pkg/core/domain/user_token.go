@@ -0,0 +1,32 @@
+package domain
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeTwoFactorLogin    = "two_factor_login"
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// UserToken is a single-use secret sent to a user to prove they own their
+// email address. Only a hash of the token is stored, the token itself is
+// only known by the email it was sent in.
+type UserToken struct {
+	Id        int64
+	UserID    int64
+	Purpose   string
+	TokenHash string
+	// Payload is data the token applies when redeemed, like the new address
+	// of an email change. It's stored encrypted.
+	Payload     string
+	ExpiresAt   string
+	UsedAt      string
+	DateCreated string
+}
+
+// Email is a plain text message for a user.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type AccessTokenRepository interface {
+	Save(*domain.AccessToken) rest_errors.RestErr
+	// List returns the tokens of a user that weren't revoked, expired ones
+	// included, newest first.
+	List(userId int64) ([]domain.AccessToken, rest_errors.RestErr)
+	// GetByHash fails with not found for unknown and revoked tokens.
+	GetByHash(hash string) (*domain.AccessToken, rest_errors.RestErr)
+	// Revoke fails with not found when the user has no such token left.
+	Revoke(userId int64, id int64, revokedAt string) rest_errors.RestErr
+	// RevokeAll revokes every token of a user, it succeeds when there's none.
+	RevokeAll(userId int64, revokedAt string) rest_errors.RestErr
+	Touch(id int64, usedAt string) rest_errors.RestErr
+}
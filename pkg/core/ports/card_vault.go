@@ -0,0 +1,10 @@
+package ports
+
+// CardVault keeps card numbers (PANs) out of the users database. Tokenize
+// stores a PAN and returns an opaque token that can later be resolved with
+// Detokenize or dropped with Delete.
+type CardVault interface {
+	Tokenize(string) (string, error)
+	Detokenize(string) (string, error)
+	Delete(string) error
+}
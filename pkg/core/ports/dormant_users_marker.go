@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type DormantUsersMarker interface {
+	WarnInactive(now time.Time) (int, rest_errors.RestErr)
+	MarkDormant(now time.Time) (int, rest_errors.RestErr)
+}
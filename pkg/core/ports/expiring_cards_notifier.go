@@ -0,0 +1,11 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type ExpiringCardsNotifier interface {
+	NotifyExpiring(now time.Time) (int, rest_errors.RestErr)
+}
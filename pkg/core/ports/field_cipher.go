@@ -0,0 +1,14 @@
+package ports
+
+// FieldCipher encrypts single column values before they are stored and
+// decrypts them when read back.
+type FieldCipher interface {
+	Encrypt(string) (string, error)
+	Decrypt(string) (string, error)
+	// BlindIndex returns a deterministic keyed hash of a value, so encrypted
+	// columns can still be looked up by equality.
+	BlindIndex(string) string
+	// NeedsRotation reports whether a stored value is plaintext or was
+	// encrypted with a key other than the active one.
+	NeedsRotation(string) bool
+}
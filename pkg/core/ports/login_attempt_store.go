@@ -0,0 +1,23 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// LoginAttemptStore keeps the failed logins and the locks of a key, which
+// names either an account or a client address.
+type LoginAttemptStore interface {
+	// Get returns the attempts of key, a zero value when there are none.
+	Get(key string) (*domain.LoginAttempts, rest_errors.RestErr)
+	// Fail records a failed login at failedAt and returns the updated
+	// attempts. The count starts over when the previous failure is older
+	// than window.
+	Fail(key string, failedAt time.Time, window time.Duration) (*domain.LoginAttempts, rest_errors.RestErr)
+	// Lock rejects logins on key until the given time and clears its count.
+	Lock(key string, until time.Time) rest_errors.RestErr
+	// Reset forgets every attempt and lock of key.
+	Reset(key string) rest_errors.RestErr
+}
@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type LoginEventRepository interface {
+	Save(*domain.LoginEvent) rest_errors.RestErr
+	// List returns up to limit events of a user older than the event with
+	// id before, newest first. A before of 0 starts from the newest one.
+	List(userId int64, before int64, limit int) ([]domain.LoginEvent, rest_errors.RestErr)
+	// DeviceLogins counts the successful logins of a user, in total and
+	// from the device with the given fingerprint.
+	DeviceLogins(userId int64, fingerprint string) (total int, device int, err rest_errors.RestErr)
+	// Prune deletes the events created before the given time and returns
+	// how many there were.
+	Prune(before time.Time) (int64, rest_errors.RestErr)
+}
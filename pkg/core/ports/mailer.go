@@ -0,0 +1,8 @@
+package ports
+
+import "github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+
+// Mailer delivers emails to users.
+type Mailer interface {
+	Send(domain.Email) error
+}
@@ -0,0 +1,17 @@
+package ports
+
+import "errors"
+
+// ErrUnknownHashFormat is returned by Verify when a hash wasn't made by any
+// known scheme.
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords into self-describing strings, which carry
+// the algorithm and parameters needed to verify them later.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash and, when it does,
+	// whether hash was made with outdated parameters and should be replaced
+	// by a new Hash of the password.
+	Verify(password string, hash string) (match bool, rehash bool, err error)
+}
@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// PasswordPolicy decides whether a user may choose a password. Rejected
+// passwords come back as a validation error listing every broken rule.
+type PasswordPolicy interface {
+	Check(password string, user *domain.User) rest_errors.RestErr
+}
+
+// BreachedPasswords tells whether a password is known to have leaked.
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
+}
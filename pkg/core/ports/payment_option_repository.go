@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type PaymentOptionRepository interface {
+	List(int64) ([]domain.PaymentOption, rest_errors.RestErr)
+	Get(int64, int64) (*domain.PaymentOption, rest_errors.RestErr)
+	GetDefault(int64) (*domain.PaymentOption, rest_errors.RestErr)
+	SetDefault(int64, int64) rest_errors.RestErr
+	Delete(int64, int64) rest_errors.RestErr
+
+	Save(*domain.PaymentOption) rest_errors.RestErr
+
+	ListExpiring(from time.Time, until time.Time) ([]domain.PaymentOption, rest_errors.RestErr)
+	MarkExpiryNotified(*domain.PaymentOption) (bool, rest_errors.RestErr)
+	UnmarkExpiryNotified(*domain.PaymentOption) rest_errors.RestErr
+}
@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type PaymentOptionService interface {
+	ListPaymentOptions(int64) ([]domain.PaymentOption, rest_errors.RestErr)
+	GetPaymentOption(int64, int64) (*domain.PaymentOption, rest_errors.RestErr)
+	CreatePaymentOption(*domain.PaymentOption) rest_errors.RestErr
+	DeletePaymentOption(int64, int64) rest_errors.RestErr
+	RevealPaymentOption(int64, int64, string) (*domain.PaymentCardDetails, rest_errors.RestErr)
+
+	GetDefaultPaymentOption(int64) (*domain.PaymentOption, rest_errors.RestErr)
+	SetDefaultPaymentOption(int64, int64) rest_errors.RestErr
+}
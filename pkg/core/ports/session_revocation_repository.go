@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// SessionRevocationRepository is the outbox of the session revocations that
+// failed, a user has at most one pending.
+type SessionRevocationRepository interface {
+	// Enqueue records a revocation, or moves the pending one of the user to
+	// requestedAt.
+	Enqueue(userId int64, requestedAt string) rest_errors.RestErr
+	// ListPending returns the oldest pending revocations first.
+	ListPending(limit int) ([]domain.SessionRevocation, rest_errors.RestErr)
+	// Done drops a revocation, unless it was requested again since it was
+	// listed.
+	Done(domain.SessionRevocation) rest_errors.RestErr
+}
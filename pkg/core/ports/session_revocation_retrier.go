@@ -0,0 +1,9 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type SessionRevocationRetrier interface {
+	RetryRevocations() (int, rest_errors.RestErr)
+}
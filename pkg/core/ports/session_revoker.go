@@ -0,0 +1,7 @@
+package ports
+
+// SessionRevoker ends the sessions of a user, so the access tokens issued
+// before a credential change stop being accepted.
+type SessionRevoker interface {
+	RevokeSessions(userId int64) error
+}
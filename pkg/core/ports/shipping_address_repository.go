@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type ShippingAddressRepository interface {
+	List(int64) ([]domain.ShippingAddress, rest_errors.RestErr)
+	Get(int64, int64) (*domain.ShippingAddress, rest_errors.RestErr)
+	GetDefault(int64) (*domain.ShippingAddress, rest_errors.RestErr)
+	SetDefault(int64, int64) rest_errors.RestErr
+	Delete(int64, int64) rest_errors.RestErr
+
+	Save(*domain.ShippingAddress) rest_errors.RestErr
+	Update(*domain.ShippingAddress) rest_errors.RestErr
+}
@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type ShippingAddressService interface {
+	ListAddresses(int64) ([]domain.ShippingAddress, rest_errors.RestErr)
+	GetAddress(int64, int64) (*domain.ShippingAddress, rest_errors.RestErr)
+	CreateAddress(*domain.ShippingAddress) rest_errors.RestErr
+	UpdateAddress(*domain.ShippingAddress) rest_errors.RestErr
+	DeleteAddress(int64, int64) rest_errors.RestErr
+	ImportAddresses(int64, []domain.AddressImportRow, bool) (*domain.AddressImportReport, rest_errors.RestErr)
+
+	GetDefaultAddress(int64) (*domain.ShippingAddress, rest_errors.RestErr)
+	SetDefaultAddress(int64, int64) rest_errors.RestErr
+}
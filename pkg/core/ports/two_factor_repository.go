@@ -0,0 +1,27 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type TwoFactorRepository interface {
+	// Get fails with not found for users that never set up two-factor
+	// authentication nor had it required.
+	Get(userId int64) (*domain.TwoFactor, rest_errors.RestErr)
+	// SaveSecret starts an enrollment, replacing any previous pending one.
+	SaveSecret(userId int64, secret string) rest_errors.RestErr
+	// Enable confirms an enrollment with the step of its first code and
+	// replaces the recovery codes of the user.
+	Enable(userId int64, enabledAt string, step int64, recoveryCodeHashes []string) rest_errors.RestErr
+	// UseStep records step as the last one used, it reports false when a code
+	// of that step or a later one was already accepted.
+	UseStep(userId int64, step int64) (bool, rest_errors.RestErr)
+	// UseRecoveryCode consumes a recovery code, it reports false when it
+	// doesn't exist or was already used.
+	UseRecoveryCode(userId int64, codeHash string, usedAt string) (bool, rest_errors.RestErr)
+	// Disable removes the secret and the recovery codes of the user, but
+	// keeps whether it's required.
+	Disable(userId int64) rest_errors.RestErr
+	SetRequired(userId int64, required bool) rest_errors.RestErr
+}
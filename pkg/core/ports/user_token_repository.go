@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type UserTokenRepository interface {
+	Save(*domain.UserToken) rest_errors.RestErr
+	GetByHash(purpose string, hash string) (*domain.UserToken, rest_errors.RestErr)
+	GetLatest(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr)
+	// MarkUsed consumes a token, it reports false when it was already used.
+	MarkUsed(id int64, usedAt string) (bool, rest_errors.RestErr)
+	// Invalidate consumes every unused token of the user for purpose.
+	Invalidate(userId int64, purpose string, usedAt string) rest_errors.RestErr
+}
@@ -7,10 +7,36 @@ import (
 
 type UsersRepository interface {
 	Get(int64) (*domain.User, rest_errors.RestErr)
-	Delete(int64) rest_errors.RestErr
+	Delete(id int64, deletedAt string) rest_errors.RestErr
 	GetByEmail(string) (*domain.User, rest_errors.RestErr)
 
 	Save(*domain.User) rest_errors.RestErr
 	Update(*domain.User) rest_errors.RestErr
 	UpdateAdmin(*domain.User) rest_errors.RestErr
+	// UpdateEmail fails with conflict when the email belongs to another user.
+	UpdateEmail(id int64, email string, modifiedAt string) rest_errors.RestErr
+	UpdatePassword(id int64, password string, modifiedAt string) rest_errors.RestErr
+	// SetStatus moves a user from status from to status to, it fails with not
+	// found when the user isn't in status from anymore.
+	SetStatus(id int64, from string, to string, modifiedAt string) rest_errors.RestErr
+
+	// Restore returns the status the user was restored to, the one it had
+	// before being deleted.
+	Restore(int64) (string, rest_errors.RestErr)
+	ListDeleted(before string, limit int) ([]domain.User, rest_errors.RestErr)
+	Purge(id int64, purgedAt string) rest_errors.RestErr
+
+	RecordLogin(id int64, loggedInAt string) rest_errors.RestErr
+	ListInactive(before string, limit int) ([]domain.User, rest_errors.RestErr)
+	ListDormancyWarned(before string, limit int) ([]domain.User, rest_errors.RestErr)
+	// MarkDormancyWarned returns false when the user was already warned.
+	MarkDormancyWarned(id int64, warnedAt string) (bool, rest_errors.RestErr)
+	UnmarkDormancyWarned(id int64) rest_errors.RestErr
+	// MarkDormant fails with not found when the user isn't a warned active
+	// user anymore.
+	MarkDormant(id int64, modifiedAt string) rest_errors.RestErr
+
+	// List returns the page of users matching the filter and how many users
+	// match it in total.
+	List(domain.UserFilter) ([]domain.User, int, rest_errors.RestErr)
 }
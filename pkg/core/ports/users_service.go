@@ -1,6 +1,8 @@
 package ports
 
 import (
+	"context"
+
 	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
 	"github.com/FacuBar/bookstore_utils-go/rest_errors"
 )
@@ -8,8 +10,73 @@ import (
 type UsersService interface {
 	GetUser(int64) (*domain.User, rest_errors.RestErr)
 	Register(*domain.User) rest_errors.RestErr
+	// Update doesn't change the email right away, a new one is stored once
+	// confirmed with ConfirmEmailChange.
 	Update(*domain.User, bool) rest_errors.RestErr
-	Login(string, string) (*domain.User, rest_errors.RestErr)
+	Delete(int64) rest_errors.RestErr
+	// Login returns either the user or, when a second factor is needed, a
+	// challenge to complete with VerifyLogin.
+	Login(email string, password string, client domain.ClientInfo) (*domain.User, *domain.LoginChallenge, rest_errors.RestErr)
+	// RequestMagicLink emails a login link and returns the nonce of the
+	// client that asked for it, to send back to LoginWithMagicLink.
+	RequestMagicLink(email string) (string, rest_errors.RestErr)
+	LoginWithMagicLink(token string, nonce string, client domain.ClientInfo) (*domain.User, *domain.LoginChallenge, rest_errors.RestErr)
+	Unlock(int64) rest_errors.RestErr
+	// ListLogins returns the login history of a user, newest first, see
+	// LoginEventRepository.List.
+	ListLogins(userId int64, before int64, limit int) ([]domain.LoginEvent, rest_errors.RestErr)
+	// ListUsers returns a page of the users matching the filter, starting
+	// after the cursor of a previous page when given one.
+	ListUsers(filter domain.UserFilter, cursor string) (*domain.UserPage, rest_errors.RestErr)
+	VerifyEmail(string) (*domain.User, rest_errors.RestErr)
+	ResendVerification(string) rest_errors.RestErr
+	ConfirmEmailChange(string) (*domain.User, rest_errors.RestErr)
+	ForgotPassword(string) rest_errors.RestErr
+	ResetPassword(token string, password string) rest_errors.RestErr
+	ChangePassword(userId int64, currentPassword string, password string) rest_errors.RestErr
+	PasswordVerifier
+	TwoFactorService
+	AccessTokenService
+
+	// Wait blocks until the emails being sent in the background are out, or
+	// until ctx is done. Servers call it while shutting down.
+	Wait(ctx context.Context) error
 
 	// Logout() *rest_errors.RestErr
 }
+
+// PasswordVerifier checks the password of an already authenticated user, for
+// operations that require them to re-enter it.
+type PasswordVerifier interface {
+	VerifyPassword(int64, string) rest_errors.RestErr
+}
+
+// TwoFactorService manages the TOTP second factor of users.
+type TwoFactorService interface {
+	EnrollTwoFactor(userId int64) (*domain.TwoFactorSecret, rest_errors.RestErr)
+	ConfirmTwoFactor(userId int64, code string) (*domain.TwoFactorEnrollment, rest_errors.RestErr)
+	DisableTwoFactor(userId int64, password string) rest_errors.RestErr
+	ResetTwoFactor(userId int64) rest_errors.RestErr
+	SetTwoFactorRequired(userId int64, required bool) rest_errors.RestErr
+
+	// VerifyLogin completes a login challenge with a code of the
+	// authenticator app or a recovery code.
+	VerifyLogin(challenge string, code string, client domain.ClientInfo) (*domain.User, rest_errors.RestErr)
+	// EnrollWithChallenge and ConfirmWithChallenge let users that must use
+	// two-factor authentication set it up while logging in.
+	EnrollWithChallenge(challenge string) (*domain.TwoFactorSecret, rest_errors.RestErr)
+	ConfirmWithChallenge(challenge string, code string, client domain.ClientInfo) (*domain.TwoFactorEnrollment, rest_errors.RestErr)
+}
+
+// AccessTokenService manages the personal access tokens of users.
+type AccessTokenService interface {
+	// CreateAccessToken returns the new token, the only time it can be
+	// read. An expiry of 0 days uses the default one.
+	CreateAccessToken(userId int64, name string, scopes []string, expiresInDays int) (*domain.NewAccessToken, rest_errors.RestErr)
+	ListAccessTokens(userId int64) ([]domain.AccessToken, rest_errors.RestErr)
+	RevokeAccessToken(userId int64, tokenId int64) rest_errors.RestErr
+	// AuthenticateAccessToken resolves a token sent by a client, it fails
+	// with unauthorized for unknown, revoked and expired ones and for users
+	// that can't log in.
+	AuthenticateAccessToken(token string) (*domain.AccessToken, rest_errors.RestErr)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceDormantUsersMarker     sync.Once
+	instanceDormantUsersMarker *dormantUsersMarker
+)
+
+// DormancySettings configures when accounts become dormant. An account nobody
+// logged in to, or used an access token of, for InactiveFor goes dormant, its
+// user is warned by email WarnBefore that.
+type DormancySettings struct {
+	InactiveFor time.Duration
+	WarnBefore  time.Duration
+}
+
+type dormantUsersMarker struct {
+	users    ports.UsersRepository
+	mailer   ports.Mailer
+	rmq      ports.UserRMQ
+	log      ports.UserLogger
+	settings DormancySettings
+}
+
+// dormancyBatchSize caps how many users a single run warns or marks as
+// dormant, the rest are left for the next one.
+const dormancyBatchSize = 100
+
+// NewDormantUsersMarker returns a marker that moves the accounts inactive
+// for longer than settings allow to the dormant state.
+func NewDormantUsersMarker(users ports.UsersRepository, mailer ports.Mailer, rmq ports.UserRMQ, log ports.UserLogger, settings DormancySettings) ports.DormantUsersMarker {
+	onceDormantUsersMarker.Do(func() {
+		instanceDormantUsersMarker = &dormantUsersMarker{
+			users:    users,
+			mailer:   mailer,
+			rmq:      rmq,
+			log:      log,
+			settings: settings,
+		}
+	})
+	return instanceDormantUsersMarker
+}
+
+// WarnInactive emails the active users that will become dormant within
+// WarnBefore unless they log in, publishes a users.event.dormancy_warning
+// event for each and returns how many were warned. Each user is claimed
+// before being emailed, so running it again, or from several instances at
+// once, never warns a user twice.
+func (m *dormantUsersMarker) WarnInactive(now time.Time) (int, rest_errors.RestErr) {
+	users, err := m.users.ListInactive(now.Add(m.settings.WarnBefore-m.settings.InactiveFor).UTC().Format(dateLayout), dormancyBatchSize)
+	if err != nil {
+		return 0, rest_errors.NewInternalServerError("error while trying to get inactive users")
+	}
+
+	dormantAt := now.Add(m.settings.WarnBefore).UTC()
+	warned := 0
+	for _, user := range users {
+		claimed, err := m.users.MarkDormancyWarned(user.Id, now.UTC().Format(dateLayout))
+		if err != nil {
+			return warned, rest_errors.NewInternalServerError("error while trying to mark user as warned")
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := m.mailer.Send(domain.Email{
+			To:      user.Email,
+			Subject: "Your account is about to become dormant",
+			Body: fmt.Sprintf("Hi %s,\n\n"+
+				"You haven't logged in to your account for a while. "+
+				"Unless you log in before %s, it will become dormant.\n\n"+
+				"Nothing is deleted, logging in after that makes it active again.\n",
+				user.FirstName, dormantAt.Format("January 2, 2006")),
+		}); err != nil {
+			m.log.Error(fmt.Sprintf("error while sending dormancy warning to user %d", user.Id), err)
+			// release the claim so the next run retries this user
+			m.users.UnmarkDormancyWarned(user.Id)
+			continue
+		}
+
+		m.rmq.Publish("users.event.dormancy_warning", domain.DormancyWarning{
+			UserID:      user.Id,
+			Email:       user.Email,
+			FirstName:   user.FirstName,
+			LastLoginAt: user.LastLoginAt,
+			DormantAt:   dormantAt.Format(dateLayout),
+		})
+		warned++
+	}
+	return warned, nil
+}
+
+// MarkDormant moves the users warned at least WarnBefore ago that didn't log
+// in since to the dormant state and returns how many were moved. Users that
+// logged in in the meantime are skipped.
+func (m *dormantUsersMarker) MarkDormant(now time.Time) (int, rest_errors.RestErr) {
+	users, err := m.users.ListDormancyWarned(now.Add(-m.settings.WarnBefore).UTC().Format(dateLayout), dormancyBatchSize)
+	if err != nil {
+		return 0, rest_errors.NewInternalServerError("error while trying to get warned users")
+	}
+
+	marked := 0
+	for _, user := range users {
+		if err := m.users.MarkDormant(user.Id, now.UTC().Format(dateLayout)); err != nil {
+			if err.Status() == http.StatusInternalServerError {
+				return marked, rest_errors.NewInternalServerError("error while trying to mark user as dormant")
+			}
+			continue
+		}
+
+		m.rmq.Publish("users.event.status_changed", domain.UserStatusChanged{
+			UserID:    user.Id,
+			From:      domain.StatusActive,
+			To:        domain.StatusDormant,
+			ChangedAt: now.UTC().Format(dateLayout),
+		})
+		marked++
+	}
+	return marked, nil
+}
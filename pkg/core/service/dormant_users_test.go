@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var dormancyTest = DormancySettings{
+	InactiveFor: 365 * 24 * time.Hour,
+	WarnBefore:  30 * 24 * time.Hour,
+}
+
+func TestWarnInactive(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+	inactive := []domain.User{
+		{Id: 1, Email: "oscaac@gmail.com", FirstName: "Oscar", Status: domain.StatusActive, LastLoginAt: "2025-11-01 10:00:00"},
+		{Id: 2, Email: "isaac@gmail.com", FirstName: "Isaac", Status: domain.StatusActive},
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		expiringRMQ.events = nil
+		outbox.sent = nil
+		var before string
+		funcListInactive = func(b string, l int) ([]domain.User, rest_errors.RestErr) {
+			before = b
+			return inactive, nil
+		}
+		funcMarkDormancyWarned = func(id int64, warnedAt string) (bool, rest_errors.RestErr) {
+			// user 2 was warned by a concurrent run
+			return id == 1, nil
+		}
+
+		m := NewDormantUsersMarker(repoMock, outbox, expiringRMQ, serviceLogger, dormancyTest)
+		warned, err := m.WarnInactive(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 1, warned)
+		assert.EqualValues(t, "2025-11-16 12:00:00", before)
+		if assert.Len(t, outbox.sent, 1) {
+			assert.EqualValues(t, "oscaac@gmail.com", outbox.sent[0].To)
+			assert.Contains(t, outbox.sent[0].Body, "November 16, 2026")
+		}
+		if assert.Len(t, expiringRMQ.events, 1) {
+			event := expiringRMQ.events[0].(domain.DormancyWarning)
+			assert.EqualValues(t, 1, event.UserID)
+			assert.EqualValues(t, "2026-11-16 12:00:00", event.DormantAt)
+		}
+	})
+
+	t.Run("MailerError", func(t *testing.T) {
+		expiringRMQ.events = nil
+		outbox.err = errors.New("smtp unavailable")
+		defer func() { outbox.err = nil }()
+		funcListInactive = func(b string, l int) ([]domain.User, rest_errors.RestErr) {
+			return inactive[:1], nil
+		}
+		funcMarkDormancyWarned = func(id int64, warnedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		var released int64
+		funcUnmarkDormancyWarned = func(id int64) rest_errors.RestErr {
+			released = id
+			return nil
+		}
+
+		m := NewDormantUsersMarker(repoMock, outbox, expiringRMQ, serviceLogger, dormancyTest)
+		warned, err := m.WarnInactive(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, warned)
+		assert.EqualValues(t, 1, released)
+		assert.Len(t, expiringRMQ.events, 0)
+	})
+}
+
+func TestMarkDormant(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+	warned := []domain.User{
+		{Id: 1, Status: domain.StatusActive},
+		{Id: 2, Status: domain.StatusActive},
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		expiringRMQ.events = nil
+		var before string
+		funcListDormancyWarned = func(b string, l int) ([]domain.User, rest_errors.RestErr) {
+			before = b
+			return warned, nil
+		}
+		funcMarkDormant = func(id int64, modifiedAt string) rest_errors.RestErr {
+			// user 2 logged in since being listed
+			if id == 2 {
+				return rest_errors.NewNotFoundError("warned user not found")
+			}
+			return nil
+		}
+
+		m := NewDormantUsersMarker(repoMock, outbox, expiringRMQ, serviceLogger, dormancyTest)
+		marked, err := m.MarkDormant(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 1, marked)
+		assert.EqualValues(t, "2026-09-17 12:00:00", before)
+		if assert.Len(t, expiringRMQ.events, 1) {
+			event := expiringRMQ.events[0].(domain.UserStatusChanged)
+			assert.EqualValues(t, domain.StatusActive, event.From)
+			assert.EqualValues(t, domain.StatusDormant, event.To)
+		}
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		funcListDormancyWarned = func(b string, l int) ([]domain.User, rest_errors.RestErr) {
+			return warned, nil
+		}
+		funcMarkDormant = func(id int64, modifiedAt string) rest_errors.RestErr {
+			return rest_errors.NewInternalServerError("db error")
+		}
+
+		m := NewDormantUsersMarker(repoMock, outbox, expiringRMQ, serviceLogger, dormancyTest)
+		marked, err := m.MarkDormant(now)
+
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.EqualValues(t, 0, marked)
+	})
+}
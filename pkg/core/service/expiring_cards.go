@@ -0,0 +1,86 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceExpiringCardsNotifier     sync.Once
+	instanceExpiringCardsNotifier *expiringCardsNotifier
+)
+
+type expiringCardsNotifier struct {
+	repo   ports.PaymentOptionRepository
+	users  ports.UsersRepository
+	rmq    ports.UserRMQ
+	window time.Duration
+}
+
+// NewExpiringCardsNotifier returns a notifier that announces every card
+// expiring within window from the moment it runs.
+func NewExpiringCardsNotifier(repo ports.PaymentOptionRepository, users ports.UsersRepository, rmq ports.UserRMQ, window time.Duration) ports.ExpiringCardsNotifier {
+	onceExpiringCardsNotifier.Do(func() {
+		instanceExpiringCardsNotifier = &expiringCardsNotifier{
+			repo:   repo,
+			users:  users,
+			rmq:    rmq,
+			window: window,
+		}
+	})
+	return instanceExpiringCardsNotifier
+}
+
+// NotifyExpiring publishes a users.event.payment_option_expiring event for
+// every card that expires between now and now+window and returns how many
+// were published. Each card is claimed before publishing, so running it
+// again, or from several instances at once, never announces a card twice for
+// the same expiry date. Cards of accounts that aren't active are skipped
+// without being claimed, they are announced if the account becomes active
+// again before they expire.
+func (n *expiringCardsNotifier) NotifyExpiring(now time.Time) (int, rest_errors.RestErr) {
+	options, err := n.repo.ListExpiring(now, now.Add(n.window))
+	if err != nil {
+		return 0, rest_errors.NewInternalServerError("error while trying to get expiring payment options")
+	}
+
+	published := 0
+	for i := range options {
+		po := &options[i]
+
+		user, err := n.users.Get(po.UserID)
+		if err != nil || user.Status != domain.StatusActive {
+			continue
+		}
+
+		claimed, err := n.repo.MarkExpiryNotified(po)
+		if err != nil {
+			return published, rest_errors.NewInternalServerError("error while trying to mark payment option as notified")
+		}
+		if !claimed {
+			continue
+		}
+
+		event := domain.PaymentOptionExpiring{
+			UserID:          po.UserID,
+			PaymentOptionID: po.Id,
+			Email:           user.Email,
+			FirstName:       user.FirstName,
+			CardType:        po.CardType,
+			Last4:           po.Last4,
+			ExpiryMonth:     po.ExpiryMonth,
+			ExpiryYear:      po.ExpiryYear,
+		}
+		if err := n.rmq.Publish("users.event.payment_option_expiring", event); err != nil {
+			// release the claim so the next run retries this card
+			n.repo.UnmarkExpiryNotified(po)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
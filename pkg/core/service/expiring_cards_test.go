@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingRMQ struct {
+	events  []interface{}
+	failing bool
+}
+
+func (r *recordingRMQ) Publish(routingKey string, e interface{}) error {
+	if r.failing {
+		return errors.New("channel closed")
+	}
+	r.events = append(r.events, e)
+	return nil
+}
+
+var expiringRMQ = &recordingRMQ{}
+
+func TestNotifyExpiring(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	expiring := []domain.PaymentOption{
+		{Id: 1, UserID: 1, CardType: "visa", Last4: "4242", ExpiryMonth: 11, ExpiryYear: 2026},
+		{Id: 2, UserID: 1, CardType: "amex", Last4: "0005", ExpiryMonth: 10, ExpiryYear: 2026},
+	}
+	funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+		return &userTest, nil
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		expiringRMQ.events = nil
+		var until time.Time
+		funcListExpiring = func(f, u time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+			until = u
+			return expiring, nil
+		}
+		funcMarkExpiryNotified = func(po *domain.PaymentOption) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+
+		n := NewExpiringCardsNotifier(paymentOptionRepo, repoMock, expiringRMQ, 30*24*time.Hour)
+		published, err := n.NotifyExpiring(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, published)
+		assert.EqualValues(t, now.Add(30*24*time.Hour), until)
+		event := expiringRMQ.events[0].(domain.PaymentOptionExpiring)
+		assert.EqualValues(t, userTest.Email, event.Email)
+		assert.EqualValues(t, "4242", event.Last4)
+	})
+
+	t.Run("AlreadyNotified", func(t *testing.T) {
+		expiringRMQ.events = nil
+		funcListExpiring = func(f, u time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+			return expiring, nil
+		}
+		funcMarkExpiryNotified = func(po *domain.PaymentOption) (bool, rest_errors.RestErr) {
+			return po.Id != 1, nil
+		}
+
+		n := NewExpiringCardsNotifier(paymentOptionRepo, repoMock, expiringRMQ, 30*24*time.Hour)
+		published, err := n.NotifyExpiring(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 1, published)
+		assert.Len(t, expiringRMQ.events, 1)
+	})
+
+	t.Run("InactiveOwner", func(t *testing.T) {
+		expiringRMQ.events = nil
+		funcListExpiring = func(f, u time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+			return expiring, nil
+		}
+		funcMarkExpiryNotified = func(po *domain.PaymentOption) (bool, rest_errors.RestErr) {
+			t.Fatal("cards of inactive accounts shouldn't be claimed")
+			return false, nil
+		}
+		defer func() {
+			funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+				return &userTest, nil
+			}
+		}()
+
+		n := NewExpiringCardsNotifier(paymentOptionRepo, repoMock, expiringRMQ, 30*24*time.Hour)
+		for _, status := range []string{domain.StatusDeleted, domain.StatusSuspended, domain.StatusDormant} {
+			funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+				user := userTest
+				user.Status = status
+				return &user, nil
+			}
+
+			published, err := n.NotifyExpiring(now)
+
+			assert.Nil(t, err)
+			assert.EqualValues(t, 0, published, status)
+		}
+		assert.Len(t, expiringRMQ.events, 0)
+	})
+
+	t.Run("PublishErrorReleasesClaim", func(t *testing.T) {
+		expiringRMQ.failing = true
+		defer func() { expiringRMQ.failing = false }()
+		funcListExpiring = func(f, u time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+			return expiring[:1], nil
+		}
+		funcMarkExpiryNotified = func(po *domain.PaymentOption) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		var released int64
+		funcUnmarkExpiryNotified = func(po *domain.PaymentOption) rest_errors.RestErr {
+			released = po.Id
+			return nil
+		}
+
+		n := NewExpiringCardsNotifier(paymentOptionRepo, repoMock, expiringRMQ, 30*24*time.Hour)
+		published, err := n.NotifyExpiring(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, published)
+		assert.EqualValues(t, 1, released)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		funcListExpiring = func(f, u time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+
+		n := NewExpiringCardsNotifier(paymentOptionRepo, repoMock, expiringRMQ, 30*24*time.Hour)
+		_, err := n.NotifyExpiring(now)
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+type passwordPolicy struct {
+	rules    validation.PasswordRules
+	breached ports.BreachedPasswords
+}
+
+// NewPasswordPolicy returns a policy enforcing rules. Passwords found in
+// breached are rejected too, the check is skipped when it's nil.
+func NewPasswordPolicy(rules validation.PasswordRules, breached ports.BreachedPasswords) ports.PasswordPolicy {
+	return &passwordPolicy{
+		rules:    rules,
+		breached: breached,
+	}
+}
+
+func (p *passwordPolicy) Check(password string, user *domain.User) rest_errors.RestErr {
+	fields := validation.Password(password, user, p.rules)
+
+	if p.breached != nil {
+		found, err := p.breached.Contains(password)
+		if err != nil {
+			return rest_errors.NewInternalServerError("error while trying to check password, try again later")
+		}
+		if found {
+			fields = append(fields, validation.FieldError{
+				Field:   "password",
+				Message: "appears in a data breach, choose a different one",
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return validation.NewValidationError("invalid password", fields)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// breachedMock knows a fixed set of leaked passwords
+type breachedMock struct {
+	passwords map[string]bool
+	err       error
+}
+
+func (m *breachedMock) Contains(password string) (bool, error) {
+	return m.passwords[password], m.err
+}
+
+var (
+	breachedTest = &breachedMock{passwords: map[string]bool{"123456789": true}}
+	policyTest   = NewPasswordPolicy(validation.PasswordRules{MinLength: 6, ForbidPersonalInfo: true}, breachedTest)
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	t.Run("Allowed", func(t *testing.T) {
+		err := policyTest.Check("Ulysses", &userTest)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("Violations", func(t *testing.T) {
+		err := policyTest.Check("oscar", &userTest)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid password", err.Message())
+		validationErr, ok := err.(*validation.ValidationError)
+		if assert.True(t, ok) {
+			assert.EqualValues(t, []validation.FieldError{
+				{Field: "password", Message: "must be at least 6 characters long"},
+				{Field: "password", Message: "must not contain your email address or name"},
+			}, validationErr.Fields)
+		}
+	})
+
+	t.Run("Breached", func(t *testing.T) {
+		err := policyTest.Check("123456789", &userTest)
+
+		assert.NotNil(t, err)
+		validationErr, ok := err.(*validation.ValidationError)
+		if assert.True(t, ok) {
+			assert.EqualValues(t, "appears in a data breach, choose a different one", validationErr.Fields[0].Message)
+		}
+	})
+
+	t.Run("BreachedListError", func(t *testing.T) {
+		breachedTest.err = errors.New("i/o error")
+		defer func() { breachedTest.err = nil }()
+
+		err := policyTest.Check("Ulysses", &userTest)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+
+	t.Run("WithoutBreachedList", func(t *testing.T) {
+		policy := NewPasswordPolicy(validation.PasswordRules{MinLength: 6}, nil)
+
+		assert.Nil(t, policy.Check("123456789", &userTest))
+	})
+}
+
+func TestPasswordPolicyIsEnforced(t *testing.T) {
+	t.Run("Register", func(t *testing.T) {
+		s := newTestService()
+
+		user := userTest
+		user.Password = "isaac1"
+		err := s.Register(&user)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "invalid password", err.Message())
+	})
+
+	t.Run("ResetKeepsToken", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return validToken(hash), nil
+		}
+		claimed := false
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			claimed = true
+			return true, nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+
+		s := newTestService()
+		err := s.ResetPassword("abc", "123456789")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "invalid password", err.Message())
+		assert.False(t, claimed)
+	})
+}
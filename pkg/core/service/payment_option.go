@@ -0,0 +1,282 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"go.uber.org/zap"
+)
+
+var (
+	oncePaymentOptionService     sync.Once
+	instancePaymentOptionService *paymentOptionService
+)
+
+type paymentOptionService struct {
+	repo      ports.PaymentOptionRepository
+	addresses ports.ShippingAddressRepository
+	vault     ports.CardVault
+	passwords ports.PasswordVerifier
+	log       ports.UserLogger
+}
+
+func NewPaymentOptionService(repo ports.PaymentOptionRepository, addresses ports.ShippingAddressRepository, vault ports.CardVault, passwords ports.PasswordVerifier, log ports.UserLogger) ports.PaymentOptionService {
+	oncePaymentOptionService.Do(func() {
+		instancePaymentOptionService = &paymentOptionService{
+			repo:      repo,
+			addresses: addresses,
+			vault:     vault,
+			passwords: passwords,
+			log:       log,
+		}
+	})
+	return instancePaymentOptionService
+}
+
+func (s *paymentOptionService) ListPaymentOptions(userId int64) ([]domain.PaymentOption, rest_errors.RestErr) {
+	options, err := s.repo.List(userId)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to get payment options, try again later")
+	}
+	for i := range options {
+		if err := s.resolve(&options[i]); err != nil {
+			return nil, rest_errors.NewInternalServerError("error while trying to get payment options, try again later")
+		}
+	}
+	return options, nil
+}
+
+func (s *paymentOptionService) GetPaymentOption(userId, id int64) (*domain.PaymentOption, rest_errors.RestErr) {
+	po, err := s.repo.Get(userId, id)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to get payment option, try again later")
+		}
+		return nil, err
+	}
+	if err := s.resolve(po); err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to get payment option, try again later")
+	}
+	return po, nil
+}
+
+func (s *paymentOptionService) CreatePaymentOption(po *domain.PaymentOption) rest_errors.RestErr {
+	if err := s.validatePaymentOption(po); err != nil {
+		return err
+	}
+
+	token, err := s.vault.Tokenize(po.CardNumber)
+	if err != nil {
+		return rest_errors.NewInternalServerError("error while trying to save payment option, try again later")
+	}
+	po.CardToken = token
+	po.Last4 = po.CardNumber[len(po.CardNumber)-4:]
+	po.MaskedNumber = maskCardNumber(po.Last4)
+	po.CardNumber = ""
+	po.CVV = ""
+
+	makeDefault := po.IsDefault
+	po.IsDefault = false
+	if err := s.repo.Save(po); err != nil {
+		s.vault.Delete(token)
+		return rest_errors.NewInternalServerError("error while trying to save payment option, try again later")
+	}
+
+	// The first payment option of a user always becomes its default one.
+	if !makeDefault {
+		_, err := s.repo.GetDefault(po.UserID)
+		makeDefault = err != nil && err.Status() == http.StatusNotFound
+	}
+	if makeDefault {
+		if err := s.repo.SetDefault(po.UserID, po.Id); err != nil {
+			return rest_errors.NewInternalServerError("error while trying to save payment option, try again later")
+		}
+		po.IsDefault = true
+	}
+	return nil
+}
+
+func (s *paymentOptionService) DeletePaymentOption(userId, id int64) rest_errors.RestErr {
+	po, err := s.GetPaymentOption(userId, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(userId, id); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to delete payment option, try again later")
+		}
+		return err
+	}
+	s.vault.Delete(po.CardToken)
+	return nil
+}
+
+// RevealPaymentOption returns the full card data of a payment option once the
+// owner re-entered their password. Every attempt is logged, whether it
+// succeeds or not.
+func (s *paymentOptionService) RevealPaymentOption(userId, id int64, password string) (*domain.PaymentCardDetails, rest_errors.RestErr) {
+	tags := []zap.Field{zap.Int64("user_id", userId), zap.Int64("payment_option_id", id)}
+
+	if err := s.passwords.VerifyPassword(userId, password); err != nil {
+		s.log.Info("payment option reveal denied", append(tags, zap.String("reason", err.Message()))...)
+		return nil, err
+	}
+
+	po, err := s.repo.Get(userId, id)
+	if err != nil {
+		s.log.Info("payment option reveal failed", append(tags, zap.String("reason", err.Message()))...)
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to get payment option, try again later")
+		}
+		return nil, err
+	}
+
+	number, vaultErr := s.vault.Detokenize(po.CardToken)
+	if vaultErr != nil {
+		s.log.Error("payment option reveal failed", vaultErr, tags...)
+		return nil, rest_errors.NewInternalServerError("error while trying to get payment option, try again later")
+	}
+
+	s.log.Info("payment option revealed", tags...)
+	return &domain.PaymentCardDetails{
+		PaymentOptionID: po.Id,
+		CardType:        po.CardType,
+		CardNumber:      number,
+		ExpiryMonth:     po.ExpiryMonth,
+		ExpiryYear:      po.ExpiryYear,
+		NameOnCard:      po.NameOnCard,
+	}, nil
+}
+
+func (s *paymentOptionService) GetDefaultPaymentOption(userId int64) (*domain.PaymentOption, rest_errors.RestErr) {
+	po, err := s.repo.GetDefault(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to get default payment option, try again later")
+		}
+		return nil, err
+	}
+	if err := s.resolve(po); err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to get default payment option, try again later")
+	}
+	return po, nil
+}
+
+func (s *paymentOptionService) SetDefaultPaymentOption(userId, id int64) rest_errors.RestErr {
+	if err := s.repo.SetDefault(userId, id); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to set default payment option, try again later")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *paymentOptionService) validatePaymentOption(po *domain.PaymentOption) rest_errors.RestErr {
+	formatPaymentOption(po)
+	fields := validation.PaymentCard(po, time.Now())
+
+	billingFields, err := s.validateBilling(po)
+	if err != nil {
+		return err
+	}
+	fields = append(fields, billingFields...)
+
+	if len(fields) > 0 {
+		return validation.NewValidationError("invalid payment option", fields)
+	}
+	return nil
+}
+
+// validateBilling checks that po has at most one billing address source and
+// that it is usable: referenced addresses must exist and inline ones must be
+// valid addresses.
+func (s *paymentOptionService) validateBilling(po *domain.PaymentOption) ([]validation.FieldError, rest_errors.RestErr) {
+	sources := 0
+	if po.BillingAddressID != 0 {
+		sources++
+	}
+	if po.BillingSameAsShipping {
+		sources++
+	}
+	if po.BillingAddress != nil {
+		sources++
+	}
+	if sources > 1 {
+		return []validation.FieldError{{
+			Field:   "billing_address",
+			Message: "only one of billing_address_id, billing_same_as_shipping and billing_address can be set",
+		}}, nil
+	}
+
+	if po.BillingAddress != nil {
+		po.BillingAddress.UserId = po.UserID
+		po.BillingAddress.IsDefault = false
+		formatAddress(po.BillingAddress)
+
+		fields := validation.Address(po.BillingAddress)
+		for i := range fields {
+			fields[i].Field = "billing_address." + fields[i].Field
+		}
+		return fields, nil
+	}
+
+	if err := s.attachBillingAddress(po); err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to save payment option, try again later")
+	}
+	switch {
+	case po.BillingAddressID != 0 && po.BillingAddress == nil:
+		return []validation.FieldError{{Field: "billing_address_id", Message: "address not found"}}, nil
+	case po.BillingSameAsShipping && po.BillingAddress == nil:
+		return []validation.FieldError{{Field: "billing_same_as_shipping", Message: "user has no default shipping address"}}, nil
+	}
+	return nil, nil
+}
+
+// resolve fills the fields of a stored payment option that are derived on
+// read: its masked number and its billing address.
+func (s *paymentOptionService) resolve(po *domain.PaymentOption) rest_errors.RestErr {
+	po.MaskedNumber = maskCardNumber(po.Last4)
+	return s.attachBillingAddress(po)
+}
+
+// attachBillingAddress resolves the billing address of po when it points to
+// the address book. Addresses that no longer exist are left empty.
+func (s *paymentOptionService) attachBillingAddress(po *domain.PaymentOption) rest_errors.RestErr {
+	var address *domain.ShippingAddress
+	var err rest_errors.RestErr
+	switch {
+	case po.BillingAddressID != 0:
+		address, err = s.addresses.Get(po.UserID, po.BillingAddressID)
+	case po.BillingSameAsShipping:
+		address, err = s.addresses.GetDefault(po.UserID)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	po.BillingAddress = address
+	return nil
+}
+
+func maskCardNumber(last4 string) string {
+	return "**** " + last4
+}
+
+func formatPaymentOption(po *domain.PaymentOption) {
+	po.CardNumber = strings.NewReplacer(" ", "", "-", "").Replace(po.CardNumber)
+	po.CVV = strings.TrimSpace(po.CVV)
+	po.NameOnCard = strings.TrimSpace(po.NameOnCard)
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type paymentOptionRepoMock struct{}
+
+var (
+	funcListPaymentOptions  func(int64) ([]domain.PaymentOption, rest_errors.RestErr)
+	funcGetPaymentOption    func(int64, int64) (*domain.PaymentOption, rest_errors.RestErr)
+	funcDeletePaymentOption func(int64, int64) rest_errors.RestErr
+	funcSavePaymentOption   func(*domain.PaymentOption) rest_errors.RestErr
+
+	funcGetDefaultPaymentOption func(int64) (*domain.PaymentOption, rest_errors.RestErr)
+	funcSetDefaultPaymentOption func(int64, int64) rest_errors.RestErr
+
+	funcListExpiring         func(time.Time, time.Time) ([]domain.PaymentOption, rest_errors.RestErr)
+	funcMarkExpiryNotified   func(*domain.PaymentOption) (bool, rest_errors.RestErr)
+	funcUnmarkExpiryNotified func(*domain.PaymentOption) rest_errors.RestErr
+)
+
+var (
+	paymentOptionRepo ports.PaymentOptionRepository = &paymentOptionRepoMock{}
+)
+
+func (m *paymentOptionRepoMock) List(userId int64) ([]domain.PaymentOption, rest_errors.RestErr) {
+	return funcListPaymentOptions(userId)
+}
+func (m *paymentOptionRepoMock) Get(userId, id int64) (*domain.PaymentOption, rest_errors.RestErr) {
+	return funcGetPaymentOption(userId, id)
+}
+func (m *paymentOptionRepoMock) Delete(userId, id int64) rest_errors.RestErr {
+	return funcDeletePaymentOption(userId, id)
+}
+func (m *paymentOptionRepoMock) Save(po *domain.PaymentOption) rest_errors.RestErr {
+	return funcSavePaymentOption(po)
+}
+func (m *paymentOptionRepoMock) GetDefault(userId int64) (*domain.PaymentOption, rest_errors.RestErr) {
+	return funcGetDefaultPaymentOption(userId)
+}
+func (m *paymentOptionRepoMock) SetDefault(userId, id int64) rest_errors.RestErr {
+	return funcSetDefaultPaymentOption(userId, id)
+}
+func (m *paymentOptionRepoMock) ListExpiring(from, until time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+	return funcListExpiring(from, until)
+}
+func (m *paymentOptionRepoMock) MarkExpiryNotified(po *domain.PaymentOption) (bool, rest_errors.RestErr) {
+	return funcMarkExpiryNotified(po)
+}
+func (m *paymentOptionRepoMock) UnmarkExpiryNotified(po *domain.PaymentOption) rest_errors.RestErr {
+	return funcUnmarkExpiryNotified(po)
+}
+
+type vaultMock struct {
+	cards   map[string]string
+	failing bool
+}
+
+func (v *vaultMock) Tokenize(pan string) (string, error) {
+	if v.failing {
+		return "", errors.New("vault unavailable")
+	}
+	v.cards["tok_test"] = pan
+	return "tok_test", nil
+}
+func (v *vaultMock) Detokenize(token string) (string, error) {
+	return v.cards[token], nil
+}
+func (v *vaultMock) Delete(token string) error {
+	delete(v.cards, token)
+	return nil
+}
+
+type passwordVerifierMock struct{}
+
+var (
+	funcVerifyPassword func(int64, string) rest_errors.RestErr
+)
+
+func (m *passwordVerifierMock) VerifyPassword(userId int64, password string) rest_errors.RestErr {
+	return funcVerifyPassword(userId, password)
+}
+
+type loggerMock struct {
+	errors []string
+	infos  []string
+	warns  []string
+}
+
+func (l *loggerMock) Error(msg string, err error, tags ...zap.Field) {
+	l.errors = append(l.errors, msg)
+}
+func (l *loggerMock) Info(msg string, tags ...zap.Field) {
+	l.infos = append(l.infos, msg)
+}
+func (l *loggerMock) Warn(msg string, tags ...zap.Field) {
+	l.warns = append(l.warns, msg)
+}
+
+var (
+	cardVaultMock = &vaultMock{cards: make(map[string]string)}
+	passwordsMock = &passwordVerifierMock{}
+	serviceLogger = &loggerMock{}
+)
+
+var (
+	paymentOptionTest = domain.PaymentOption{
+		UserID:      1,
+		CardType:    "amex",
+		CardNumber:  "4242 4242 4242 4242",
+		ExpiryMonth: 12,
+		ExpiryYear:  2030,
+		NameOnCard:  "Oscar Isaac",
+		CVV:         "123",
+	}
+)
+
+func TestCreatePaymentOption(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		var saved domain.PaymentOption
+		funcSavePaymentOption = func(po *domain.PaymentOption) rest_errors.RestErr {
+			saved = *po
+			po.Id = 1
+			return nil
+		}
+		funcGetDefaultPaymentOption = func(u int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("default payment option not found")
+		}
+		funcSetDefaultPaymentOption = func(u, i int64) rest_errors.RestErr {
+			return nil
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		err := s.CreatePaymentOption(&po)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "tok_test", saved.CardToken)
+		assert.EqualValues(t, "visa", saved.CardType)
+		assert.EqualValues(t, "4242", saved.Last4)
+		assert.EqualValues(t, "", saved.CardNumber)
+		assert.EqualValues(t, "", saved.CVV)
+		assert.EqualValues(t, "4242424242424242", cardVaultMock.cards["tok_test"])
+		assert.EqualValues(t, "**** 4242", po.MaskedNumber)
+		assert.True(t, po.IsDefault)
+	})
+
+	t.Run("InvalidCVV", func(t *testing.T) {
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		po.CVV = "12a"
+		err := s.CreatePaymentOption(&po)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid payment option", err.Message())
+		fields := err.(*validation.ValidationError).Fields
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "cvv", fields[0].Field)
+	})
+
+	t.Run("BillingSameAsShipping", func(t *testing.T) {
+		var saved domain.PaymentOption
+		funcSavePaymentOption = func(po *domain.PaymentOption) rest_errors.RestErr {
+			saved = *po
+			return nil
+		}
+		funcGetDefaultAddress = func(u int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			address := addressTest
+			return &address, nil
+		}
+		funcGetDefaultPaymentOption = func(u int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return &domain.PaymentOption{}, nil
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		po.BillingSameAsShipping = true
+		err := s.CreatePaymentOption(&po)
+
+		assert.Nil(t, err)
+		assert.True(t, saved.BillingSameAsShipping)
+		assert.EqualValues(t, addressTest.City, po.BillingAddress.City)
+	})
+
+	t.Run("UnknownBillingAddress", func(t *testing.T) {
+		funcGetAddress = func(u, i int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("address not found")
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		po.BillingAddressID = 9
+		err := s.CreatePaymentOption(&po)
+
+		assert.NotNil(t, err)
+		fields := err.(*validation.ValidationError).Fields
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "billing_address_id", fields[0].Field)
+	})
+
+	t.Run("InvalidInlineBillingAddress", func(t *testing.T) {
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		billingAddress := addressTest
+		billingAddress.PostCode = "ABC"
+		po.BillingAddress = &billingAddress
+		err := s.CreatePaymentOption(&po)
+
+		assert.NotNil(t, err)
+		fields := err.(*validation.ValidationError).Fields
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "billing_address.post_code", fields[0].Field)
+	})
+
+	t.Run("SeveralBillingSources", func(t *testing.T) {
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		po.BillingAddressID = 1
+		po.BillingSameAsShipping = true
+		err := s.CreatePaymentOption(&po)
+
+		assert.NotNil(t, err)
+		fields := err.(*validation.ValidationError).Fields
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "billing_address", fields[0].Field)
+	})
+
+	t.Run("DbErrorReleasesToken", func(t *testing.T) {
+		funcSavePaymentOption = func(po *domain.PaymentOption) rest_errors.RestErr {
+			return rest_errors.NewInternalServerError("db error")
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		err := s.CreatePaymentOption(&po)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "error while trying to save payment option, try again later", err.Message())
+		_, stored := cardVaultMock.cards["tok_test"]
+		assert.False(t, stored)
+	})
+
+	t.Run("VaultError", func(t *testing.T) {
+		cardVaultMock.failing = true
+		defer func() { cardVaultMock.failing = false }()
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po := paymentOptionTest
+		err := s.CreatePaymentOption(&po)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestGetPaymentOption(t *testing.T) {
+	t.Run("ResolvesBillingAddress", func(t *testing.T) {
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return &domain.PaymentOption{Id: i, UserID: u, BillingAddressID: 1}, nil
+		}
+		funcGetAddress = func(u, i int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			address := addressTest
+			return &address, nil
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po, err := s.GetPaymentOption(1, 1)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, addressTest.AddressLine1, po.BillingAddress.AddressLine1)
+	})
+
+	t.Run("MasksNumber", func(t *testing.T) {
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return &domain.PaymentOption{Id: i, UserID: u, Last4: "1881"}, nil
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		po, err := s.GetPaymentOption(1, 1)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "**** 1881", po.MaskedNumber)
+	})
+}
+
+func TestRevealPaymentOption(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		cardVaultMock.cards["tok_reveal"] = "4242424242424242"
+		funcVerifyPassword = func(u int64, p string) rest_errors.RestErr {
+			return nil
+		}
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return &domain.PaymentOption{Id: i, UserID: u, CardType: "visa", CardToken: "tok_reveal", Last4: "4242"}, nil
+		}
+		serviceLogger.infos = nil
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		details, err := s.RevealPaymentOption(1, 1, "password")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "4242424242424242", details.CardNumber)
+		assert.EqualValues(t, []string{"payment option revealed"}, serviceLogger.infos)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		funcVerifyPassword = func(u int64, p string) rest_errors.RestErr {
+			return rest_errors.NewUnauthorizedError("invalid password")
+		}
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			t.Fatal("payment option must not be read before the password is verified")
+			return nil, nil
+		}
+		serviceLogger.infos = nil
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		details, err := s.RevealPaymentOption(1, 1, "wrong")
+
+		assert.Nil(t, details)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+		assert.EqualValues(t, []string{"payment option reveal denied"}, serviceLogger.infos)
+	})
+
+	t.Run("LocksAccount", func(t *testing.T) {
+		// passwords checked by the users service, as they are outside tests
+		funcVerifyPassword = newTestService().VerifyPassword
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			t.Fatal("payment option must not be read before the password is verified")
+			return nil, nil
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		assertLocksAccount(t, func(password string) rest_errors.RestErr {
+			_, err := s.RevealPaymentOption(1, 1, password)
+			return err
+		})
+	})
+}
+
+func TestDeletePaymentOption(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		cardVaultMock.cards["tok_delete"] = "4242424242424242"
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return &domain.PaymentOption{Id: i, UserID: u, CardToken: "tok_delete"}, nil
+		}
+		funcDeletePaymentOption = func(u, i int64) rest_errors.RestErr {
+			return nil
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		err := s.DeletePaymentOption(1, 1)
+
+		assert.Nil(t, err)
+		_, stored := cardVaultMock.cards["tok_delete"]
+		assert.False(t, stored)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		funcGetPaymentOption = func(u, i int64) (*domain.PaymentOption, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("payment option not found")
+		}
+
+		s := NewPaymentOptionService(paymentOptionRepo, addressRepo, cardVaultMock, passwordsMock, serviceLogger)
+		err := s.DeletePaymentOption(1, 1)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceSessionRevocationRetrier     sync.Once
+	instanceSessionRevocationRetrier *sessionRevocationRetrier
+)
+
+type sessionRevocationRetrier struct {
+	revocations ports.SessionRevocationRepository
+	sessions    ports.SessionRevoker
+	log         ports.UserLogger
+}
+
+// retryBatchSize caps how many revocations a single run retries, the rest
+// are left for the next one.
+const retryBatchSize = 100
+
+// NewSessionRevocationRetrier returns a retrier for the session revocations
+// the oauth API didn't take when they were requested.
+func NewSessionRevocationRetrier(revocations ports.SessionRevocationRepository, sessions ports.SessionRevoker, logger ports.UserLogger) ports.SessionRevocationRetrier {
+	onceSessionRevocationRetrier.Do(func() {
+		instanceSessionRevocationRetrier = &sessionRevocationRetrier{
+			revocations: revocations,
+			sessions:    sessions,
+			log:         logger,
+		}
+	})
+	return instanceSessionRevocationRetrier
+}
+
+// RetryRevocations sends the pending revocations again, oldest first, and
+// returns how many went through. The run stops at the first one the oauth
+// API doesn't take, it is most likely still unreachable.
+func (r *sessionRevocationRetrier) RetryRevocations() (int, rest_errors.RestErr) {
+	revocations, err := r.revocations.ListPending(retryBatchSize)
+	if err != nil {
+		return 0, rest_errors.NewInternalServerError("error while trying to get pending session revocations")
+	}
+
+	revoked := 0
+	for _, revocation := range revocations {
+		if err := r.sessions.RevokeSessions(revocation.UserID); err != nil {
+			r.log.Error(fmt.Sprintf("error while revoking sessions of user %d", revocation.UserID), err)
+			return revoked, rest_errors.NewInternalServerError("error while trying to revoke sessions")
+		}
+		if err := r.revocations.Done(revocation); err != nil {
+			return revoked, rest_errors.NewInternalServerError("error while trying to mark session revocation as done")
+		}
+		revoked++
+	}
+	return revoked, nil
+}
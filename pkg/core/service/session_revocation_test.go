@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// revocationsMock keeps the pending revocations in memory, one per user.
+type revocationsMock struct {
+	pending map[int64]string
+	err     rest_errors.RestErr
+}
+
+func (m *revocationsMock) Enqueue(userId int64, requestedAt string) rest_errors.RestErr {
+	if m.err != nil {
+		return m.err
+	}
+	if m.pending == nil {
+		m.pending = make(map[int64]string)
+	}
+	m.pending[userId] = requestedAt
+	return nil
+}
+
+func (m *revocationsMock) ListPending(limit int) ([]domain.SessionRevocation, rest_errors.RestErr) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	revocations := make([]domain.SessionRevocation, 0)
+	for userId, requestedAt := range m.pending {
+		revocations = append(revocations, domain.SessionRevocation{UserID: userId, RequestedAt: requestedAt})
+	}
+	return revocations, nil
+}
+
+func (m *revocationsMock) Done(r domain.SessionRevocation) rest_errors.RestErr {
+	if m.pending[r.UserID] == r.RequestedAt {
+		delete(m.pending, r.UserID)
+	}
+	return nil
+}
+
+var revocationsTest = &revocationsMock{}
+
+func TestRetryRevocations(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		revocations := &revocationsMock{pending: map[int64]string{1: "2026-10-17 12:00:00", 2: "2026-10-17 12:05:00"}}
+		sessions := &sessionRevokerMock{}
+		r := &sessionRevocationRetrier{revocations: revocations, sessions: sessions, log: serviceLogger}
+
+		revoked, err := r.RetryRevocations()
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, revoked)
+		assert.ElementsMatch(t, []int64{1, 2}, sessions.revoked)
+		assert.Empty(t, revocations.pending)
+	})
+
+	t.Run("OauthUnavailable", func(t *testing.T) {
+		revocations := &revocationsMock{pending: map[int64]string{1: "2026-10-17 12:00:00", 2: "2026-10-17 12:05:00"}}
+		sessions := &sessionRevokerMock{err: errors.New("unavailable")}
+		r := &sessionRevocationRetrier{revocations: revocations, sessions: sessions, log: serviceLogger}
+
+		revoked, err := r.RetryRevocations()
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, 0, revoked)
+		// the first failure ends the run
+		assert.Len(t, sessions.revoked, 1)
+		assert.Len(t, revocations.pending, 2)
+	})
+
+	t.Run("DBError", func(t *testing.T) {
+		revocations := &revocationsMock{err: rest_errors.NewInternalServerError("db error")}
+		r := &sessionRevocationRetrier{revocations: revocations, sessions: &sessionRevokerMock{}, log: serviceLogger}
+
+		_, err := r.RetryRevocations()
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceShippingAddressService     sync.Once
+	instanceShippingAddressService *shippingAddressService
+)
+
+type shippingAddressService struct {
+	repo ports.ShippingAddressRepository
+}
+
+func NewShippingAddressService(repo ports.ShippingAddressRepository) ports.ShippingAddressService {
+	onceShippingAddressService.Do(func() {
+		instanceShippingAddressService = &shippingAddressService{
+			repo: repo,
+		}
+	})
+	return instanceShippingAddressService
+}
+
+func (s *shippingAddressService) ListAddresses(userId int64) ([]domain.ShippingAddress, rest_errors.RestErr) {
+	addresses, err := s.repo.List(userId)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to get addresses, try again later")
+	}
+	return addresses, nil
+}
+
+func (s *shippingAddressService) GetAddress(userId, addressId int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+	address, err := s.repo.Get(userId, addressId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to get address, try again later")
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func (s *shippingAddressService) CreateAddress(address *domain.ShippingAddress) rest_errors.RestErr {
+	if err := validateAddress(address); err != nil {
+		return err
+	}
+
+	makeDefault := address.IsDefault
+	address.IsDefault = false
+	if err := s.repo.Save(address); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to save address, try again later")
+	}
+
+	// The first address of a user always becomes its default one.
+	if !makeDefault {
+		_, err := s.repo.GetDefault(address.UserId)
+		makeDefault = err != nil && err.Status() == http.StatusNotFound
+	}
+	if makeDefault {
+		if err := s.repo.SetDefault(address.UserId, address.Id); err != nil {
+			return rest_errors.NewInternalServerError("error while trying to save address, try again later")
+		}
+		address.IsDefault = true
+	}
+	return nil
+}
+
+func (s *shippingAddressService) UpdateAddress(address *domain.ShippingAddress) rest_errors.RestErr {
+	oldAddress, err := s.GetAddress(address.UserId, address.Id)
+	if err != nil {
+		return err
+	}
+
+	address.IsDefault = oldAddress.IsDefault
+	if strings.TrimSpace(address.EmailInvoice) == "" {
+		address.EmailInvoice = oldAddress.EmailInvoice
+	}
+	if strings.TrimSpace(address.FullName) == "" {
+		address.FullName = oldAddress.FullName
+	}
+	if strings.TrimSpace(address.AddressLine1) == "" {
+		address.AddressLine1 = oldAddress.AddressLine1
+	}
+	if strings.TrimSpace(address.AddressLine2) == "" {
+		address.AddressLine2 = oldAddress.AddressLine2
+	}
+	if strings.TrimSpace(address.City) == "" {
+		address.City = oldAddress.City
+	}
+	if strings.TrimSpace(address.State) == "" {
+		address.State = oldAddress.State
+	}
+	if strings.TrimSpace(address.PostCode) == "" {
+		address.PostCode = oldAddress.PostCode
+	}
+	if strings.TrimSpace(address.Country) == "" {
+		address.Country = oldAddress.Country
+	}
+
+	if err := validateAddress(address); err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(address); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to update address, try again later")
+	}
+	return nil
+}
+
+func (s *shippingAddressService) DeleteAddress(userId, addressId int64) rest_errors.RestErr {
+	if err := s.repo.Delete(userId, addressId); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to delete address, try again later")
+		}
+		return err
+	}
+	return nil
+}
+
+// maxImportRows caps the size of an address book import, so a single request
+// can't hold the database for too long.
+const maxImportRows = 1000
+
+// ImportAddresses adds every valid row of an address book to the user. Rows
+// are handled independently, so one invalid row doesn't prevent the others
+// from being imported. On a dry run rows are validated but nothing is saved.
+func (s *shippingAddressService) ImportAddresses(userId int64, rows []domain.AddressImportRow, dryRun bool) (*domain.AddressImportReport, rest_errors.RestErr) {
+	if len(rows) == 0 {
+		return nil, rest_errors.NewBadRequestError("address book has no addresses")
+	}
+	if len(rows) > maxImportRows {
+		return nil, rest_errors.NewBadRequestError(fmt.Sprintf("address book has too many addresses, at most %d can be imported at once", maxImportRows))
+	}
+
+	report := &domain.AddressImportReport{DryRun: dryRun, Total: len(rows), Rows: rows}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		row.Address.Id = 0
+		row.Address.UserId = userId
+		row.Address.IsDefault = false
+
+		if len(row.Errors) == 0 {
+			if err := validateAddress(&row.Address); err != nil {
+				row.Errors = importErrors(err)
+			}
+		}
+
+		switch {
+		case len(row.Errors) > 0:
+			row.Status = domain.ImportRowInvalid
+			report.Invalid++
+		case dryRun:
+			row.Status = domain.ImportRowValid
+		default:
+			if err := s.CreateAddress(&row.Address); err != nil {
+				row.Status = domain.ImportRowFailed
+				row.Errors = importErrors(err)
+				report.Failed++
+				continue
+			}
+			row.Status = domain.ImportRowImported
+			report.Imported++
+		}
+	}
+	return report, nil
+}
+
+func (s *shippingAddressService) GetDefaultAddress(userId int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+	address, err := s.repo.GetDefault(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to get default address, try again later")
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func (s *shippingAddressService) SetDefaultAddress(userId, addressId int64) rest_errors.RestErr {
+	if err := s.repo.SetDefault(userId, addressId); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to set default address, try again later")
+		}
+		return err
+	}
+	return nil
+}
+
+func validateAddress(a *domain.ShippingAddress) rest_errors.RestErr {
+	formatAddress(a)
+	if fields := validation.Address(a); len(fields) > 0 {
+		return validation.NewValidationError("invalid address", fields)
+	}
+	return nil
+}
+
+func importErrors(err rest_errors.RestErr) map[string]string {
+	errs := make(map[string]string)
+	if validationErr, ok := err.(*validation.ValidationError); ok {
+		for _, field := range validationErr.Fields {
+			errs[field.Field] = field.Message
+		}
+		return errs
+	}
+	errs["row"] = err.Message()
+	return errs
+}
+
+func formatAddress(a *domain.ShippingAddress) {
+	a.EmailInvoice = strings.ToLower(strings.TrimSpace(a.EmailInvoice))
+	a.FullName = strings.TrimSpace(a.FullName)
+	a.AddressLine1 = strings.TrimSpace(a.AddressLine1)
+	a.AddressLine2 = strings.TrimSpace(a.AddressLine2)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.TrimSpace(a.State)
+	a.PostCode = strings.TrimSpace(a.PostCode)
+	a.Country = strings.TrimSpace(a.Country)
+}
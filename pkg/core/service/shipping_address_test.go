@@ -0,0 +1,278 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type addressRepoMock struct{}
+
+var (
+	funcListAddresses func(int64) ([]domain.ShippingAddress, rest_errors.RestErr)
+	funcGetAddress    func(int64, int64) (*domain.ShippingAddress, rest_errors.RestErr)
+	funcDeleteAddress func(int64, int64) rest_errors.RestErr
+	funcSaveAddress   func(*domain.ShippingAddress) rest_errors.RestErr
+	funcUpdateAddress func(*domain.ShippingAddress) rest_errors.RestErr
+
+	funcGetDefaultAddress func(int64) (*domain.ShippingAddress, rest_errors.RestErr)
+	funcSetDefaultAddress func(int64, int64) rest_errors.RestErr
+)
+
+var (
+	addressRepo ports.ShippingAddressRepository = &addressRepoMock{}
+)
+
+func (m *addressRepoMock) List(userId int64) ([]domain.ShippingAddress, rest_errors.RestErr) {
+	return funcListAddresses(userId)
+}
+func (m *addressRepoMock) Get(userId, id int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+	return funcGetAddress(userId, id)
+}
+func (m *addressRepoMock) Delete(userId, id int64) rest_errors.RestErr {
+	return funcDeleteAddress(userId, id)
+}
+func (m *addressRepoMock) Save(a *domain.ShippingAddress) rest_errors.RestErr {
+	return funcSaveAddress(a)
+}
+func (m *addressRepoMock) Update(a *domain.ShippingAddress) rest_errors.RestErr {
+	return funcUpdateAddress(a)
+}
+func (m *addressRepoMock) GetDefault(userId int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+	return funcGetDefaultAddress(userId)
+}
+func (m *addressRepoMock) SetDefault(userId, id int64) rest_errors.RestErr {
+	return funcSetDefaultAddress(userId, id)
+}
+
+var (
+	addressTest = domain.ShippingAddress{
+		Id:           1,
+		UserId:       1,
+		EmailInvoice: "oscaac@gmail.com",
+		FullName:     "Oscar Isaac",
+		AddressLine1: "742 Evergreen Terrace",
+		City:         "Springfield",
+		State:        "OR",
+		PostCode:     "97403",
+		Country:      "US",
+	}
+)
+
+func TestListAddresses(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcListAddresses = func(i int64) ([]domain.ShippingAddress, rest_errors.RestErr) {
+			return []domain.ShippingAddress{addressTest}, nil
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		addresses, err := s.ListAddresses(1)
+
+		assert.Nil(t, err)
+		assert.Len(t, addresses, 1)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		funcListAddresses = func(i int64) ([]domain.ShippingAddress, rest_errors.RestErr) {
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		addresses, err := s.ListAddresses(1)
+
+		assert.Nil(t, addresses)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "error while trying to get addresses, try again later", err.Message())
+	})
+}
+
+func TestCreateAddress(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcSaveAddress = func(a *domain.ShippingAddress) rest_errors.RestErr {
+			a.Id = 2
+			return nil
+		}
+		funcGetDefaultAddress = func(u int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			address := addressTest
+			address.IsDefault = true
+			return &address, nil
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		address := addressTest
+		address.EmailInvoice = "  OSCAAC@gmail.com "
+		err := s.CreateAddress(&address)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, address.Id)
+		assert.EqualValues(t, "oscaac@gmail.com", address.EmailInvoice)
+		assert.False(t, address.IsDefault)
+	})
+
+	t.Run("FirstAddressBecomesDefault", func(t *testing.T) {
+		funcSaveAddress = func(a *domain.ShippingAddress) rest_errors.RestErr {
+			a.Id = 2
+			return nil
+		}
+		funcGetDefaultAddress = func(u int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("default address not found")
+		}
+		var defaultId int64
+		funcSetDefaultAddress = func(u, i int64) rest_errors.RestErr {
+			defaultId = i
+			return nil
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		address := addressTest
+		err := s.CreateAddress(&address)
+
+		assert.Nil(t, err)
+		assert.True(t, address.IsDefault)
+		assert.EqualValues(t, 2, defaultId)
+	})
+
+	t.Run("InvalidAddress", func(t *testing.T) {
+		s := NewShippingAddressService(addressRepo)
+		address := addressTest
+		address.City = " "
+		err := s.CreateAddress(&address)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid address", err.Message())
+		fields := err.(*validation.ValidationError).Fields
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "city", fields[0].Field)
+	})
+}
+
+func TestUpdateAddress(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcGetAddress = func(u, i int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			address := addressTest
+			return &address, nil
+		}
+		funcUpdateAddress = func(a *domain.ShippingAddress) rest_errors.RestErr {
+			return nil
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		update := domain.ShippingAddress{Id: 1, UserId: 1, City: "Shelbyville"}
+		err := s.UpdateAddress(&update)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "Shelbyville", update.City)
+		assert.EqualValues(t, addressTest.AddressLine1, update.AddressLine1)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		funcGetAddress = func(u, i int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("address not found")
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		update := domain.ShippingAddress{Id: 1, UserId: 1}
+		err := s.UpdateAddress(&update)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestDeleteAddress(t *testing.T) {
+	t.Run("DbError", func(t *testing.T) {
+		funcDeleteAddress = func(u, i int64) rest_errors.RestErr {
+			return rest_errors.NewInternalServerError("db error")
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		err := s.DeleteAddress(1, 1)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "error while trying to delete address, try again later", err.Message())
+	})
+}
+
+func TestImportAddresses(t *testing.T) {
+	rows := func() []domain.AddressImportRow {
+		invalid := addressTest
+		invalid.PostCode = "ABC"
+		return []domain.AddressImportRow{
+			{Row: 2, Address: addressTest},
+			{Row: 3, Address: invalid},
+			{Row: 4, Errors: map[string]string{"row": "expected 8 fields, got 3"}},
+		}
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		saved := 0
+		funcSaveAddress = func(a *domain.ShippingAddress) rest_errors.RestErr {
+			saved++
+			a.Id = 7
+			return nil
+		}
+		funcGetDefaultAddress = func(u int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+			return &addressTest, nil
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		report, err := s.ImportAddresses(2, rows(), false)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 1, saved)
+		assert.EqualValues(t, 3, report.Total)
+		assert.EqualValues(t, 1, report.Imported)
+		assert.EqualValues(t, 2, report.Invalid)
+		assert.EqualValues(t, domain.ImportRowImported, report.Rows[0].Status)
+		assert.EqualValues(t, 2, report.Rows[0].Address.UserId)
+		assert.EqualValues(t, 7, report.Rows[0].Address.Id)
+		assert.EqualValues(t, domain.ImportRowInvalid, report.Rows[1].Status)
+		assert.Contains(t, report.Rows[1].Errors, "post_code")
+		assert.EqualValues(t, domain.ImportRowInvalid, report.Rows[2].Status)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		funcSaveAddress = func(a *domain.ShippingAddress) rest_errors.RestErr {
+			t.Fatal("nothing must be saved on a dry run")
+			return nil
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		report, err := s.ImportAddresses(2, rows(), true)
+
+		assert.Nil(t, err)
+		assert.True(t, report.DryRun)
+		assert.EqualValues(t, 0, report.Imported)
+		assert.EqualValues(t, domain.ImportRowValid, report.Rows[0].Status)
+		assert.EqualValues(t, 2, report.Invalid)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		funcSaveAddress = func(a *domain.ShippingAddress) rest_errors.RestErr {
+			return rest_errors.NewInternalServerError("db error")
+		}
+
+		s := NewShippingAddressService(addressRepo)
+		report, err := s.ImportAddresses(2, rows()[:1], false)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 1, report.Failed)
+		assert.EqualValues(t, domain.ImportRowFailed, report.Rows[0].Status)
+		assert.EqualValues(t, "error while trying to save address, try again later", report.Rows[0].Errors["row"])
+	})
+
+	t.Run("TooManyRows", func(t *testing.T) {
+		s := NewShippingAddressService(addressRepo)
+		report, err := s.ImportAddresses(2, make([]domain.AddressImportRow, maxImportRows+1), false)
+
+		assert.Nil(t, report)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/mail"
 	"strings"
@@ -10,7 +12,7 @@ import (
 	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
 	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
 	"github.com/FacuBar/bookstore_utils-go/rest_errors"
-	"golang.org/x/crypto/bcrypt"
+	"go.uber.org/zap"
 )
 
 var (
@@ -19,24 +21,75 @@ var (
 )
 
 type usersService struct {
-	repo ports.UsersRepository
-	rmq  ports.UserRMQ
+	repo              ports.UsersRepository
+	rmq               ports.UserRMQ
+	tokens            ports.UserTokenRepository
+	mailer            ports.Mailer
+	sessions          ports.SessionRevoker
+	revocations       ports.SessionRevocationRepository
+	policy            ports.PasswordPolicy
+	attempts          ports.LoginAttemptStore
+	throttle          LoginThrottle
+	hasher            ports.PasswordHasher
+	twoFactor         ports.TwoFactorRepository
+	twoFactorSettings TwoFactorSettings
+	loginEvents       ports.LoginEventRepository
+	accessTokens      ports.AccessTokenRepository
+	log               ports.UserLogger
+	// appURL is the base URL of the frontend, links sent by email point to it
+	appURL string
+	// background tracks the work started by inBackground
+	background sync.WaitGroup
 }
 
-func NewUsersService(repo ports.UsersRepository, rmq ports.UserRMQ) ports.UsersService {
+// UsersServiceDeps holds what the users service is built from.
+type UsersServiceDeps struct {
+	Repo              ports.UsersRepository
+	RMQ               ports.UserRMQ
+	Tokens            ports.UserTokenRepository
+	Mailer            ports.Mailer
+	Sessions          ports.SessionRevoker
+	Revocations       ports.SessionRevocationRepository
+	Policy            ports.PasswordPolicy
+	Attempts          ports.LoginAttemptStore
+	Throttle          LoginThrottle
+	Hasher            ports.PasswordHasher
+	TwoFactor         ports.TwoFactorRepository
+	TwoFactorSettings TwoFactorSettings
+	LoginEvents       ports.LoginEventRepository
+	AccessTokens      ports.AccessTokenRepository
+	Log               ports.UserLogger
+	// AppURL is the base URL of the frontend, links sent by email point to it
+	AppURL string
+}
+
+func NewUsersService(deps UsersServiceDeps) ports.UsersService {
 	onceUsersService.Do(func() {
 		instanceUsersService = &usersService{
-			repo: repo,
-			rmq:  rmq,
+			repo:              deps.Repo,
+			rmq:               deps.RMQ,
+			tokens:            deps.Tokens,
+			mailer:            deps.Mailer,
+			sessions:          deps.Sessions,
+			revocations:       deps.Revocations,
+			policy:            deps.Policy,
+			attempts:          deps.Attempts,
+			throttle:          deps.Throttle,
+			hasher:            deps.Hasher,
+			twoFactor:         deps.TwoFactor,
+			twoFactorSettings: deps.TwoFactorSettings,
+			loginEvents:       deps.LoginEvents,
+			accessTokens:      deps.AccessTokens,
+			log:               deps.Log,
+			appURL:            strings.TrimRight(deps.AppURL, "/"),
 		}
 	})
 	return instanceUsersService
 }
 
 var (
-	statusActive = "active"
-	defaultRole  = "user"
-	dateLayout   = "2006-01-02 15:04:05"
+	defaultRole = "user"
+	dateLayout  = "2006-01-02 15:04:05"
 )
 
 func (s *usersService) GetUser(userId int64) (*domain.User, rest_errors.RestErr) {
@@ -55,13 +108,20 @@ func (s *usersService) Register(user *domain.User) rest_errors.RestErr {
 	if err := validate(user); err != nil {
 		return err
 	}
+	if err := s.policy.Check(user.Password, user); err != nil {
+		return err
+	}
 
 	user.DateCreated = time.Now().UTC().Format(dateLayout)
-	user.Status = statusActive
+	user.Status = domain.StatusPendingVerification
 	user.Role = defaultRole
 
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.Password), 10)
-	user.Password = string(hashedPassword)
+	hashedPassword, err := s.hasher.Hash(user.Password)
+	if err != nil {
+		s.log.Error("error while hashing password", err)
+		return rest_errors.NewInternalServerError("error while trying to register, try again later")
+	}
+	user.Password = hashedPassword
 
 	if err := s.repo.Save(user); err != nil {
 		if err.Status() != http.StatusInternalServerError {
@@ -70,29 +130,153 @@ func (s *usersService) Register(user *domain.User) rest_errors.RestErr {
 		return rest_errors.NewInternalServerError("error while trying to register, try again later")
 	}
 	s.rmq.Publish("users.event.register", user)
+
+	// the account exists at this point, if the email can't be sent the user
+	// can ask for another one
+	if token, err := s.issueToken(user.Id, domain.TokenPurposeEmailVerification, verificationTTL); err == nil {
+		s.sendVerification(user, token)
+	}
 	return nil
 }
 
-func (s *usersService) Login(email, password string) (*domain.User, rest_errors.RestErr) {
-	user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email)))
+// Login authenticates a user logging in from client. Failed logins are
+// counted per account and per address, see LoginThrottle. Users with
+// two-factor authentication get a challenge instead of the user, to complete
+// with VerifyLogin. Every attempt ends up in the login history.
+func (s *usersService) Login(email, password string, client domain.ClientInfo) (*domain.User, *domain.LoginChallenge, rest_errors.RestErr) {
+	now := time.Now().UTC()
+	if err := s.checkAttempts(email, client.IP, now); err != nil {
+		s.recordLoginFailureByEmail(email, client, err)
+		return nil, nil, err
+	}
+
+	user, err := s.authenticate(email, password)
 	if err != nil {
-		switch err.Status() {
-		case http.StatusInternalServerError:
+		if err.Status() == http.StatusBadRequest {
+			if lockErr := s.recordFailure(email, client.IP, now); lockErr != nil {
+				err = lockErr
+			}
+		}
+		s.recordLoginFailureByEmail(email, client, err)
+		return nil, nil, err
+	}
+	// the password is right, whatever the state of the account
+	s.attempts.Reset(accountAttemptsKey(email))
+
+	if !reactivatedByLogin(user.Status) {
+		if err := checkCanLogin(user); err != nil {
+			s.recordLoginFailure(user.Id, client, loginFailureReason(err))
+			return nil, nil, err
+		}
+	}
+	challenge, err := s.loginChallenge(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge != nil {
+		s.recordLoginFailure(user.Id, client, domain.LoginReasonTwoFactorPending)
+		return nil, challenge, nil
+	}
+
+	user, err = s.completeLogin(user, client)
+	return user, nil, err
+}
+
+// completeLogin finishes the login of an authenticated user.
+func (s *usersService) completeLogin(user *domain.User, client domain.ClientInfo) (*domain.User, rest_errors.RestErr) {
+	// logging in during the grace period cancels a pending deletion, the
+	// account goes back to the status it had and is checked as such below
+	if user.Status == domain.StatusDeleted {
+		restored, err := s.repo.Restore(user.Id)
+		if err != nil {
+			if err.Status() == http.StatusNotFound {
+				// purged while logging in
+				return nil, rest_errors.NewBadRequestError("invalid credentials")
+			}
 			return nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
-		case http.StatusNotFound:
-			return nil, rest_errors.NewBadRequestError("invalid credentials")
-		default:
-			return nil, err
+		}
+		s.publishStatusChange(user, restored)
+		s.rmq.Publish("users.event.delete", domain.UserDeletion{
+			UserID:    user.Id,
+			Step:      domain.DeletionCancelled,
+			Email:     user.Email,
+			FirstName: user.FirstName,
+		})
+	}
+	// and so does it for accounts that went dormant
+	if user.Status == domain.StatusDormant {
+		if err := s.repo.SetStatus(user.Id, domain.StatusDormant, domain.StatusActive, time.Now().UTC().Format(dateLayout)); err != nil {
+			if err.Status() != http.StatusNotFound {
+				return nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+			}
+			// changed by a concurrent request, its state is checked below
+			if user, err = s.repo.Get(user.Id); err != nil {
+				return nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+			}
+		} else {
+			s.publishStatusChange(user, domain.StatusActive)
 		}
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, rest_errors.NewBadRequestError("invalid credentials")
+	if err := checkCanLogin(user); err != nil {
+		s.recordLoginFailure(user.Id, client, loginFailureReason(err))
+		return nil, err
 	}
+	// the login went through, failing to track it only delays dormancy
+	s.repo.RecordLogin(user.Id, time.Now().UTC().Format(dateLayout))
+	s.recordSuccessfulLogin(user, client)
 	return user, nil
 }
 
+// VerifyPassword re-checks the password of an authenticated user before a
+// sensitive operation. A wrong password is reported as unauthorized, so
+// clients can tell it apart from an invalid request.
+func (s *usersService) VerifyPassword(userId int64, password string) rest_errors.RestErr {
+	_, err := s.checkPassword(userId, password)
+	return err
+}
+
+// checkPassword returns the user with the given id if password is theirs and
+// their account allows them to log in. Wrong passwords count as failed logins
+// of the account, and a locked account can't be checked, so a stolen session
+// doesn't allow guessing the password.
+func (s *usersService) checkPassword(userId int64, password string) (*domain.User, rest_errors.RestErr) {
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to verify password, try again later")
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.checkAttempts(user.Email, "", now); err != nil {
+		return nil, err
+	}
+	authenticated, err := s.authenticate(user.Email, password)
+	if err != nil {
+		if err.Status() == http.StatusBadRequest {
+			if lockErr := s.recordFailure(user.Email, "", now); lockErr != nil {
+				return nil, lockErr
+			}
+			return nil, rest_errors.NewUnauthorizedError("invalid password")
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to verify password, try again later")
+	}
+	s.attempts.Reset(accountAttemptsKey(user.Email))
+	if err := checkCanLogin(authenticated); err != nil {
+		return nil, err
+	}
+	return authenticated, nil
+}
+
+// Update changes the profile of a user. Only admins may set a password this
+// way, users change theirs with ChangePassword.
 func (s *usersService) Update(user *domain.User, isAdmin bool) rest_errors.RestErr {
+	if !isAdmin && user.Password != "" {
+		return rest_errors.NewBadRequestError("the password can only be changed through PUT /users/{user_id}/password")
+	}
+
 	oldUser, err := s.repo.Get(user.Id)
 	if err != nil {
 		if err.Status() == http.StatusInternalServerError {
@@ -107,36 +291,228 @@ func (s *usersService) Update(user *domain.User, isAdmin bool) rest_errors.RestE
 	if strings.TrimSpace(user.LastName) == "" {
 		user.LastName = oldUser.LastName
 	}
-	if strings.TrimSpace(user.Email) == "" {
-		user.Email = oldUser.Email
+	// the email only changes once the new address confirms it
+	newEmail := strings.ToLower(strings.TrimSpace(user.Email))
+	user.Email = oldUser.Email
+	if newEmail == strings.ToLower(oldUser.Email) {
+		newEmail = ""
+	}
+	if newEmail != "" {
+		if err := s.checkNewEmail(newEmail); err != nil {
+			return err
+		}
 	}
 	if user.Status == "" {
 		user.Status = oldUser.Status
 	}
+	if user.Status != oldUser.Status {
+		if err := checkStatusChange(oldUser.Status, user.Status); err != nil {
+			return err
+		}
+	}
 	if user.Role == "" {
 		user.Role = oldUser.Role
 	}
-	if user.Password == "" {
-		user.Password = oldUser.Password
-	} else {
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.Password), 10)
-		user.Password = string(hashedPassword)
+	newPassword := user.Password
+	user.Password = ""
+	if newPassword != "" {
+		if err := s.policy.Check(newPassword, user); err != nil {
+			return err
+		}
+	}
+
+	// the email change is staged before saving anything, so the update
+	// either goes through as a whole or leaves the user as it was
+	if newEmail != "" {
+		if err := s.stageEmailChange(user, newEmail); err != nil {
+			return err
+		}
 	}
 
 	user.LastModified = time.Now().UTC().Format(dateLayout)
 
+	if err := s.saveUpdate(user, isAdmin); err != nil {
+		if newEmail != "" {
+			s.dropEmailChange(user.Id)
+		}
+		return err
+	}
+	if newPassword != "" {
+		if err := s.setPassword(user, newPassword); err != nil {
+			return rest_errors.NewInternalServerError("error while trying to update user, try again later")
+		}
+		s.publishSecurityEvent(user, domain.SecurityEventPasswordChanged)
+	}
+	s.rmq.Publish("users.event.update", user)
+	if user.Status != oldUser.Status {
+		s.publishStatusChange(oldUser, user.Status)
+	}
+	if newEmail != "" {
+		s.announceEmailChange(user, newEmail)
+	}
+	return nil
+}
+
+func (s *usersService) saveUpdate(user *domain.User, isAdmin bool) rest_errors.RestErr {
 	if isAdmin {
 		if err := s.repo.UpdateAdmin(user); err != nil {
+			if err.Status() != http.StatusInternalServerError {
+				return err
+			}
 			return rest_errors.NewInternalServerError("error while trying to update user, try again later")
 		}
 	}
 	if err := s.repo.Update(user); err != nil {
+		if err.Status() != http.StatusInternalServerError {
+			return err
+		}
 		return rest_errors.NewInternalServerError("error while trying to update user, try again later")
 	}
-	s.rmq.Publish("users.event.update", user)
 	return nil
 }
 
+// Delete soft deletes a user. Its data is kept during a grace period, in
+// which logging in again cancels the deletion, and purged afterwards by the
+// DeletedUsersPurger. Deleting an already deleted user is a no-op.
+func (s *usersService) Delete(userId int64) rest_errors.RestErr {
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to delete user, try again later")
+		}
+		return err
+	}
+	if user.Status == domain.StatusDeleted {
+		return nil
+	}
+	if !domain.CanTransition(user.Status, domain.StatusDeleted) {
+		return rest_errors.NewNotFoundError("user not found")
+	}
+
+	// a deleted account keeps no access, logging in again is the only way
+	// back during the grace period
+	revocation, err := s.requestSessionRevocation(userId)
+	if err != nil {
+		return rest_errors.NewInternalServerError("error while trying to delete user, try again later")
+	}
+	deletedAt := revocation.RequestedAt
+	if err := s.repo.Delete(userId, deletedAt); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to delete user, try again later")
+	}
+	s.revokeSessions(revocation)
+	s.publishStatusChange(user, domain.StatusDeleted)
+	s.rmq.Publish("users.event.delete", domain.UserDeletion{
+		UserID:    user.Id,
+		Step:      domain.DeletionRequested,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		DeletedAt: deletedAt,
+	})
+	return nil
+}
+
+// publishStatusChange announces that user moved from its current state to
+// status and updates it.
+func (s *usersService) publishStatusChange(user *domain.User, status string) {
+	s.rmq.Publish("users.event.status_changed", domain.UserStatusChanged{
+		UserID:    user.Id,
+		From:      user.Status,
+		To:        status,
+		ChangedAt: time.Now().UTC().Format(dateLayout),
+	})
+	user.Status = status
+}
+
+// checkStatusChange validates a state change requested through an update.
+// Deletion has its own flow, so it can't be entered nor left this way.
+func checkStatusChange(from, to string) rest_errors.RestErr {
+	if !domain.IsValidStatus(to) {
+		return rest_errors.NewBadRequestError("invalid status")
+	}
+	if to == domain.StatusDeleted || to == domain.StatusPurged {
+		return rest_errors.NewBadRequestError("users can only be deleted through DELETE /users/{user_id}")
+	}
+	if from == domain.StatusDeleted {
+		return rest_errors.NewRestError("deleted users are only restored by logging in again", http.StatusConflict, "conflict")
+	}
+	if !domain.CanTransition(from, to) {
+		return rest_errors.NewRestError(fmt.Sprintf("status can't change from %s to %s", from, to), http.StatusConflict, "conflict")
+	}
+	return nil
+}
+
+// checkCanLogin rejects users whose state doesn't allow them to log in, with
+// an error telling them why.
+func checkCanLogin(user *domain.User) rest_errors.RestErr {
+	switch user.Status {
+	case domain.StatusActive:
+		return nil
+	case domain.StatusPendingVerification:
+		return rest_errors.NewRestError("email address is not verified yet", http.StatusForbidden, "account_pending_verification")
+	case domain.StatusSuspended:
+		return rest_errors.NewRestError("account is suspended", http.StatusForbidden, "account_suspended")
+	case domain.StatusInactive:
+		return rest_errors.NewRestError("account is inactive", http.StatusForbidden, "account_inactive")
+	default:
+		return rest_errors.NewBadRequestError("invalid credentials")
+	}
+}
+
+// reactivatedByLogin reports whether a login brings an account in status
+// back, see completeLogin. Deleted accounts return to the status they had
+// before, dormant ones become active.
+func reactivatedByLogin(status string) bool {
+	return status == domain.StatusDeleted || status == domain.StatusDormant
+}
+
+// authenticate returns the user matching the given credentials. Passwords
+// hashed with outdated parameters are hashed again on the way.
+func (s *usersService) authenticate(email, password string) (*domain.User, rest_errors.RestErr) {
+	user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		switch err.Status() {
+		case http.StatusInternalServerError:
+			return nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+		case http.StatusNotFound:
+			return nil, rest_errors.NewBadRequestError("invalid credentials")
+		default:
+			return nil, err
+		}
+	}
+
+	match, rehash, hashErr := s.hasher.Verify(password, user.Password)
+	if hashErr != nil {
+		if !errors.Is(hashErr, ports.ErrUnknownHashFormat) {
+			s.log.Error("error while verifying password", hashErr)
+			return nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+		}
+		// no password matches it, like the empty one of purged users
+		s.log.Warn("password hash in an unknown format", zap.Int64("user_id", user.Id))
+		match = false
+	}
+	if !match {
+		return nil, rest_errors.NewBadRequestError("invalid credentials")
+	}
+	if rehash {
+		s.rehashPassword(user, password)
+	}
+	return user, nil
+}
+
+// rehashPassword replaces the stored hash of user with a new one of
+// password. It's best effort, the old hash keeps working if it fails.
+func (s *usersService) rehashPassword(user *domain.User, password string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		s.log.Error("error while rehashing password", err)
+		return
+	}
+	if err := s.repo.UpdatePassword(user.Id, hashedPassword, time.Now().UTC().Format(dateLayout)); err != nil {
+		return
+	}
+	user.Password = hashedPassword
+}
+
 // func (s *usersService) Logout() *rest_errors.RestErr {
 // 	return nil
 // }
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	// accessTokenSize is the number of random bytes of a personal access
+	// token, after its prefix.
+	accessTokenSize = 32
+	// accessTokenHintLength is how many characters of the random part are
+	// kept to tell tokens apart.
+	accessTokenHintLength = 4
+
+	accessTokenDefaultDays = 90
+	accessTokenMaxDays     = 365
+	accessTokenNameMaxLen  = 64
+	maxAccessTokens        = 20
+
+	// accessTokenTouchInterval limits how often the last use of a token is
+	// written, scripts may send many requests in a row.
+	accessTokenTouchInterval = time.Minute
+)
+
+func (s *usersService) CreateAccessToken(userId int64, name string, scopes []string, expiresInDays int) (*domain.NewAccessToken, rest_errors.RestErr) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > accessTokenNameMaxLen {
+		return nil, rest_errors.NewBadRequestError(fmt.Sprintf("name must have between 1 and %d characters", accessTokenNameMaxLen))
+	}
+	scopes, err := checkScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if expiresInDays == 0 {
+		expiresInDays = accessTokenDefaultDays
+	}
+	if expiresInDays < 0 || expiresInDays > accessTokenMaxDays {
+		return nil, rest_errors.NewBadRequestError(fmt.Sprintf("expires_in_days must be between 1 and %d", accessTokenMaxDays))
+	}
+
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to create access token, try again later")
+		}
+		return nil, err
+	}
+	tokens, err := s.accessTokens.List(userId)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to create access token, try again later")
+	}
+	if len(tokens) >= maxAccessTokens {
+		return nil, rest_errors.NewRestError(fmt.Sprintf("a user can't have more than %d access tokens, revoke one first", maxAccessTokens), http.StatusConflict, "conflict")
+	}
+
+	b := make([]byte, accessTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		s.log.Error("error while generating access token", err)
+		return nil, rest_errors.NewInternalServerError("error while trying to create access token, try again later")
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	token := domain.AccessTokenPrefix + secret
+
+	now := time.Now().UTC()
+	created := domain.NewAccessToken{
+		AccessToken: domain.AccessToken{
+			UserID:      userId,
+			Name:        name,
+			Hint:        domain.AccessTokenPrefix + secret[:accessTokenHintLength],
+			Scopes:      scopes,
+			TokenHash:   hashUserToken(token),
+			ExpiresAt:   now.AddDate(0, 0, expiresInDays).Format(dateLayout),
+			DateCreated: now.Format(dateLayout),
+		},
+		Token: token,
+	}
+	if err := s.accessTokens.Save(&created.AccessToken); err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to create access token, try again later")
+	}
+	s.publishSecurityEvent(user, domain.SecurityEventAccessTokenCreated)
+	return &created, nil
+}
+
+func (s *usersService) ListAccessTokens(userId int64) ([]domain.AccessToken, rest_errors.RestErr) {
+	if _, err := s.repo.Get(userId); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to list access tokens, try again later")
+		}
+		return nil, err
+	}
+
+	tokens, err := s.accessTokens.List(userId)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to list access tokens, try again later")
+	}
+	return tokens, nil
+}
+
+func (s *usersService) RevokeAccessToken(userId int64, tokenId int64) rest_errors.RestErr {
+	if err := s.accessTokens.Revoke(userId, tokenId, time.Now().UTC().Format(dateLayout)); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to revoke access token, try again later")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *usersService) AuthenticateAccessToken(token string) (*domain.AccessToken, rest_errors.RestErr) {
+	if !strings.HasPrefix(token, domain.AccessTokenPrefix) {
+		return nil, errInvalidAccessToken()
+	}
+
+	t, err := s.accessTokens.GetByHash(hashUserToken(token))
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, errInvalidAccessToken()
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to check access token, try again later")
+	}
+
+	now := time.Now().UTC()
+	expiresAt, parseErr := time.Parse(dateLayout, t.ExpiresAt)
+	if parseErr != nil {
+		s.log.Error("invalid access token expiry", parseErr)
+		return nil, rest_errors.NewInternalServerError("error while trying to check access token, try again later")
+	}
+	if !now.Before(expiresAt) {
+		return nil, errInvalidAccessToken()
+	}
+
+	// tokens stop working with the account, without being revoked
+	user, err := s.repo.Get(t.UserID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, errInvalidAccessToken()
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to check access token, try again later")
+	}
+	if checkCanLogin(user) != nil {
+		return nil, errInvalidAccessToken()
+	}
+
+	if lastUsedAt, parseErr := time.Parse(dateLayout, t.LastUsedAt); parseErr != nil || now.Sub(lastUsedAt) >= accessTokenTouchInterval {
+		if err := s.accessTokens.Touch(t.Id, now.Format(dateLayout)); err != nil {
+			s.log.Error(fmt.Sprintf("error while recording use of access token %d", t.Id), err)
+		} else {
+			t.LastUsedAt = now.Format(dateLayout)
+		}
+		// scripts using a token keep the account in use as much as logins
+		// do, failing to track it only delays dormancy
+		s.repo.RecordLogin(user.Id, now.Format(dateLayout))
+	}
+	return t, nil
+}
+
+// checkScopes validates the scopes asked for a token and drops duplicates.
+func checkScopes(scopes []string) ([]string, rest_errors.RestErr) {
+	if len(scopes) == 0 {
+		return nil, rest_errors.NewBadRequestError("at least one scope is required")
+	}
+
+	checked := make([]string, 0, len(scopes))
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		if !isAccessTokenScope(scope) {
+			return nil, rest_errors.NewBadRequestError(fmt.Sprintf("invalid scope %q", scope))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			checked = append(checked, scope)
+		}
+	}
+	return checked, nil
+}
+
+func isAccessTokenScope(scope string) bool {
+	for _, s := range domain.AccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func errInvalidAccessToken() rest_errors.RestErr {
+	return rest_errors.NewUnauthorizedError("invalid access token")
+}
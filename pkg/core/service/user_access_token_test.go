@@ -0,0 +1,231 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// accessTokensMock is an in-memory token store whose tokens tests can inspect
+type accessTokensMock struct {
+	tokens  []domain.AccessToken
+	touched int
+}
+
+func (m *accessTokensMock) Save(t *domain.AccessToken) rest_errors.RestErr {
+	t.Id = int64(len(m.tokens) + 1)
+	m.tokens = append(m.tokens, *t)
+	return nil
+}
+func (m *accessTokensMock) List(userId int64) ([]domain.AccessToken, rest_errors.RestErr) {
+	tokens := make([]domain.AccessToken, 0)
+	for i := len(m.tokens) - 1; i >= 0; i-- {
+		if m.tokens[i].UserID == userId && m.tokens[i].RevokedAt == "" {
+			tokens = append(tokens, m.tokens[i])
+		}
+	}
+	return tokens, nil
+}
+func (m *accessTokensMock) GetByHash(hash string) (*domain.AccessToken, rest_errors.RestErr) {
+	for _, t := range m.tokens {
+		if t.TokenHash == hash && t.RevokedAt == "" {
+			return &t, nil
+		}
+	}
+	return nil, rest_errors.NewNotFoundError("token not found")
+}
+func (m *accessTokensMock) Revoke(userId int64, id int64, revokedAt string) rest_errors.RestErr {
+	for i := range m.tokens {
+		if m.tokens[i].Id == id && m.tokens[i].UserID == userId && m.tokens[i].RevokedAt == "" {
+			m.tokens[i].RevokedAt = revokedAt
+			return nil
+		}
+	}
+	return rest_errors.NewNotFoundError("token not found")
+}
+func (m *accessTokensMock) RevokeAll(userId int64, revokedAt string) rest_errors.RestErr {
+	for i := range m.tokens {
+		if m.tokens[i].UserID == userId && m.tokens[i].RevokedAt == "" {
+			m.tokens[i].RevokedAt = revokedAt
+		}
+	}
+	return nil
+}
+func (m *accessTokensMock) Touch(id int64, usedAt string) rest_errors.RestErr {
+	m.touched++
+	m.tokens[id-1].LastUsedAt = usedAt
+	return nil
+}
+
+var accessTokensTest = &accessTokensMock{}
+
+func TestCreateAccessToken(t *testing.T) {
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		user.Status = domain.StatusActive
+		return &user, nil
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		accessTokensTest.tokens = nil
+		rabbitMock.published = nil
+		s := newTestService()
+
+		token, err := s.CreateAccessToken(userTest.Id, " deploy script ", []string{domain.ScopeAddressesRead, domain.ScopeAddressesRead}, 0)
+
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(token.Token, domain.AccessTokenPrefix))
+		assert.True(t, strings.HasPrefix(token.Token, token.Hint))
+		assert.EqualValues(t, "deploy script", token.Name)
+		assert.EqualValues(t, []string{domain.ScopeAddressesRead}, token.Scopes)
+		assert.Contains(t, rabbitMock.published, "users.event.security")
+
+		expiresAt, _ := time.Parse(dateLayout, token.ExpiresAt)
+		assert.WithinDuration(t, time.Now().UTC().AddDate(0, 0, accessTokenDefaultDays), expiresAt, time.Minute)
+
+		// only the hash is stored
+		if assert.Len(t, accessTokensTest.tokens, 1) {
+			assert.EqualValues(t, hashUserToken(token.Token), accessTokensTest.tokens[0].TokenHash)
+		}
+	})
+
+	t.Run("InvalidScope", func(t *testing.T) {
+		s := newTestService()
+
+		token, err := s.CreateAccessToken(userTest.Id, "deploy script", []string{"admin"}, 0)
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, `invalid scope "admin"`, err.Message())
+	})
+
+	t.Run("NoScopes", func(t *testing.T) {
+		s := newTestService()
+
+		token, err := s.CreateAccessToken(userTest.Id, "deploy script", nil, 0)
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("ExpiryTooLong", func(t *testing.T) {
+		s := newTestService()
+
+		token, err := s.CreateAccessToken(userTest.Id, "deploy script", []string{domain.ScopeUsersRead}, accessTokenMaxDays+1)
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("TooManyTokens", func(t *testing.T) {
+		accessTokensTest.tokens = nil
+		for i := 0; i < maxAccessTokens; i++ {
+			accessTokensTest.Save(&domain.AccessToken{UserID: userTest.Id})
+		}
+		s := newTestService()
+
+		token, err := s.CreateAccessToken(userTest.Id, "deploy script", []string{domain.ScopeUsersRead}, 0)
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+}
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	activeUser := func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		user.Status = domain.StatusActive
+		return &user, nil
+	}
+	funcGet = activeUser
+
+	newToken := func(t *testing.T, s ports.UsersService) *domain.NewAccessToken {
+		accessTokensTest.tokens = nil
+		accessTokensTest.touched = 0
+		token, err := s.CreateAccessToken(userTest.Id, "deploy script", []string{domain.ScopeAddressesRead}, 0)
+		assert.Nil(t, err)
+		return token
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		s := newTestService()
+		token := newToken(t, s)
+		active := 0
+		funcRecordLogin = func(id int64, loggedInAt string) rest_errors.RestErr {
+			assert.EqualValues(t, userTest.Id, id)
+			active++
+			return nil
+		}
+		defer func() { funcRecordLogin = nil }()
+
+		authenticated, err := s.AuthenticateAccessToken(token.Token)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, userTest.Id, authenticated.UserID)
+		assert.True(t, authenticated.HasScope(domain.ScopeAddressesRead))
+		assert.NotEmpty(t, authenticated.LastUsedAt)
+		// using a token keeps the account from going dormant
+		assert.EqualValues(t, 1, active)
+
+		// the last use is only written once in a while
+		s.AuthenticateAccessToken(token.Token)
+		assert.EqualValues(t, 1, accessTokensTest.touched)
+		assert.EqualValues(t, 1, active)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		s := newTestService()
+		token := newToken(t, s)
+
+		assert.Nil(t, s.RevokeAccessToken(userTest.Id, token.Id))
+		authenticated, err := s.AuthenticateAccessToken(token.Token)
+
+		assert.Nil(t, authenticated)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+
+		err = s.RevokeAccessToken(userTest.Id, token.Id)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		s := newTestService()
+		token := newToken(t, s)
+		accessTokensTest.tokens[0].ExpiresAt = time.Now().UTC().Add(-time.Minute).Format(dateLayout)
+
+		authenticated, err := s.AuthenticateAccessToken(token.Token)
+
+		assert.Nil(t, authenticated)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+	})
+
+	t.Run("SuspendedUser", func(t *testing.T) {
+		s := newTestService()
+		token := newToken(t, s)
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Status = domain.StatusSuspended
+			return &user, nil
+		}
+		defer func() { funcGet = activeUser }()
+
+		authenticated, err := s.AuthenticateAccessToken(token.Token)
+
+		assert.Nil(t, authenticated)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		s := newTestService()
+
+		authenticated, err := s.AuthenticateAccessToken("bpat_unknown")
+
+		assert.Nil(t, authenticated)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+	})
+}
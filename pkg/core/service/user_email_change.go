@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// emailChangeTTL is how long the link confirming a new email stays valid.
+const emailChangeTTL = 24 * time.Hour
+
+// checkNewEmail validates an address a user wants to change to and makes sure
+// no other account uses it.
+func (s *usersService) checkNewEmail(email string) rest_errors.RestErr {
+	if _, err := mail.ParseAddress(email); err != nil {
+		return rest_errors.NewBadRequestError("invalid email address")
+	}
+
+	_, err := s.repo.GetByEmail(email)
+	if err == nil {
+		return errEmailInUse()
+	}
+	if err.Status() != http.StatusNotFound {
+		return rest_errors.NewInternalServerError("error while trying to change email, try again later")
+	}
+	return nil
+}
+
+// stageEmailChange starts the change of the email of user to email: a link
+// to confirm it is sent to the new address, invalidating the previous ones.
+// The user keeps logging in with the current address until the change is
+// confirmed.
+func (s *usersService) stageEmailChange(user *domain.User, email string) rest_errors.RestErr {
+	if err := s.tokens.Invalidate(user.Id, domain.TokenPurposeEmailChange, time.Now().UTC().Format(dateLayout)); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to change email, try again later")
+	}
+	token, err := s.issuePayloadToken(user.Id, domain.TokenPurposeEmailChange, email, emailChangeTTL)
+	if err != nil {
+		return rest_errors.NewInternalServerError("error while trying to change email, try again later")
+	}
+
+	if err := s.mailer.Send(domain.Email{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm this is the new email address of your account by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. If you didn't ask for this change, you can ignore this email.\n",
+			user.FirstName, s.link("/confirm-email", token), int(emailChangeTTL.Hours())),
+	}); err != nil {
+		s.log.Error("error while sending email change confirmation", err)
+		s.dropEmailChange(user.Id)
+		return rest_errors.NewInternalServerError("error while trying to change email, try again later")
+	}
+	return nil
+}
+
+// dropEmailChange invalidates the link of a staged change whose update
+// didn't go through.
+func (s *usersService) dropEmailChange(userId int64) {
+	if err := s.tokens.Invalidate(userId, domain.TokenPurposeEmailChange, time.Now().UTC().Format(dateLayout)); err != nil {
+		s.log.Error(fmt.Sprintf("error while dropping email change of user %d", userId), err)
+	}
+}
+
+// announceEmailChange tells the current address of user about the staged
+// change once the update is saved.
+func (s *usersService) announceEmailChange(user *domain.User, email string) {
+	// the change can't happen without the new address, so a failure to warn
+	// the current one is only logged
+	if err := s.mailer.Send(domain.Email{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We got a request to change the email address of your account to %s. "+
+			"It will only take effect once confirmed from that address.\n\n"+
+			"If it wasn't you, change your password right away.\n",
+			user.FirstName, email),
+	}); err != nil {
+		s.log.Error("error while sending email change notice", err)
+	}
+
+	user.PendingEmail = email
+}
+
+// ConfirmEmailChange redeems an email change token and moves the user it was
+// issued for to the new address. The previous address is notified through a
+// security event.
+func (s *usersService) ConfirmEmailChange(token string) (*domain.User, rest_errors.RestErr) {
+	t, err := s.consumeToken(domain.TokenPurposeEmailChange, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.Get(t.UserID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, errInvalidToken()
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to change email, try again later")
+	}
+
+	// the address may have been taken since the change was requested
+	if err := s.repo.UpdateEmail(user.Id, t.Payload, time.Now().UTC().Format(dateLayout)); err != nil {
+		if err.Status() != http.StatusInternalServerError {
+			return nil, err
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to change email, try again later")
+	}
+
+	s.publishSecurityEvent(user, domain.SecurityEventEmailChanged)
+	user.Email = t.Payload
+	s.rmq.Publish("users.event.update", user)
+	return user, nil
+}
+
+func errEmailInUse() rest_errors.RestErr {
+	return rest_errors.NewRestError("email already in use", http.StatusConflict, "conflict")
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateEmail(t *testing.T) {
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		user.Status = domain.StatusActive
+		return &user, nil
+	}
+	funcGetByEmail = func(email string) (*domain.User, rest_errors.RestErr) {
+		return nil, rest_errors.NewNotFoundError("user not found")
+	}
+	funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+		return nil
+	}
+
+	t.Run("Staged", func(t *testing.T) {
+		var stored *domain.User
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			stored = u
+			return nil
+		}
+		var issued *domain.UserToken
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			issued = tk
+			return nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+
+		update := domain.User{Id: userTest.Id, Email: " New@Gmail.com "}
+		err := s.Update(&update, false)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, userTest.Email, stored.Email)
+		assert.EqualValues(t, "new@gmail.com", update.PendingEmail)
+		if assert.NotNil(t, issued) {
+			assert.EqualValues(t, domain.TokenPurposeEmailChange, issued.Purpose)
+			assert.EqualValues(t, "new@gmail.com", issued.Payload)
+		}
+		if assert.Len(t, outbox.sent, 2) {
+			assert.EqualValues(t, "new@gmail.com", outbox.sent[0].To)
+			assert.Contains(t, outbox.sent[0].Body, "http://localhost:3000/confirm-email?token=")
+			assert.EqualValues(t, userTest.Email, outbox.sent[1].To)
+			assert.NotContains(t, outbox.sent[1].Body, "token=")
+		}
+	})
+
+	t.Run("SameEmail", func(t *testing.T) {
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			return nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+
+		update := domain.User{Id: userTest.Id, Email: userTest.Email}
+		err := s.Update(&update, false)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "", update.PendingEmail)
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		s := newTestService()
+
+		update := domain.User{Id: userTest.Id, Email: "not an email"}
+		err := s.Update(&update, false)
+
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("MailerError", func(t *testing.T) {
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		invalidated := 0
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			invalidated++
+			return nil
+		}
+		defer func() {
+			funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+				return nil
+			}
+		}()
+		updated := false
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			updated = true
+			return nil
+		}
+		outbox.err = errors.New("connection refused")
+		defer func() { outbox.err = nil }()
+
+		s := newTestService()
+
+		update := domain.User{Id: userTest.Id, FirstName: "Oscar Jr", Email: "new@gmail.com"}
+		err := s.Update(&update, false)
+
+		// nothing was saved nor announced
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.False(t, updated)
+		// the link that couldn't be sent is dropped too
+		assert.EqualValues(t, 2, invalidated)
+	})
+
+	t.Run("UpdateErrorDropsEmailChange", func(t *testing.T) {
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		invalidated := 0
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			assert.EqualValues(t, domain.TokenPurposeEmailChange, purpose)
+			invalidated++
+			return nil
+		}
+		defer func() {
+			funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+				return nil
+			}
+		}()
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			return rest_errors.NewInternalServerError("db error")
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+
+		update := domain.User{Id: userTest.Id, Email: "new@gmail.com"}
+		err := s.Update(&update, false)
+
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.EqualValues(t, "", update.PendingEmail)
+		// once to stage the change and once to drop it
+		assert.EqualValues(t, 2, invalidated)
+		// the current address is only told about saved changes
+		assert.Len(t, outbox.sent, 1)
+	})
+
+	t.Run("EmailTaken", func(t *testing.T) {
+		funcGetByEmail = func(email string) (*domain.User, rest_errors.RestErr) {
+			return &domain.User{Id: 2, Email: email}, nil
+		}
+		defer func() {
+			funcGetByEmail = func(email string) (*domain.User, rest_errors.RestErr) {
+				return nil, rest_errors.NewNotFoundError("user not found")
+			}
+		}()
+		updated := false
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			updated = true
+			return nil
+		}
+
+		s := newTestService()
+
+		update := domain.User{Id: userTest.Id, Email: "taken@gmail.com"}
+		err := s.Update(&update, false)
+
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+		assert.EqualValues(t, "email already in use", err.Message())
+		assert.False(t, updated)
+	})
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	emailChangeToken := func(hash string) *domain.UserToken {
+		token := validToken(hash)
+		token.Purpose = domain.TokenPurposeEmailChange
+		token.Payload = "new@gmail.com"
+		return token
+	}
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		user.Email = "oscaac@gmail.com"
+		return &user, nil
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			assert.EqualValues(t, domain.TokenPurposeEmailChange, purpose)
+			return emailChangeToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		var changedTo string
+		funcUpdateEmail = func(id int64, email, modifiedAt string) rest_errors.RestErr {
+			changedTo = email
+			return nil
+		}
+		rabbitMock.published = nil
+
+		s := newTestService()
+		user, err := s.ConfirmEmailChange("abc")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "new@gmail.com", changedTo)
+		assert.EqualValues(t, "new@gmail.com", user.Email)
+		assert.Contains(t, rabbitMock.published, "users.event.security")
+		assert.Contains(t, rabbitMock.published, "users.event.update")
+	})
+
+	t.Run("UsedToken", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			token := emailChangeToken(hash)
+			token.UsedAt = "2026-10-17 12:00:00"
+			return token, nil
+		}
+
+		s := newTestService()
+		user, err := s.ConfirmEmailChange("abc")
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("TakenSinceRequested", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return emailChangeToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcUpdateEmail = func(id int64, email, modifiedAt string) rest_errors.RestErr {
+			return rest_errors.NewRestError("email already in use", http.StatusConflict, "conflict")
+		}
+
+		s := newTestService()
+		user, err := s.ConfirmEmailChange("abc")
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+}
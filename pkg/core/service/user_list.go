@@ -0,0 +1,164 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	userListDefaultLimit = 20
+	userListMaxLimit     = 100
+	// userListDateLayout is the layout of the creation dates users are
+	// filtered by.
+	userListDateLayout = "2006-01-02"
+)
+
+func errInvalidCursor() rest_errors.RestErr {
+	return rest_errors.NewBadRequestError("invalid cursor")
+}
+
+// ListUsers returns a page of the users matching filter, sorted by id unless
+// it asks otherwise. Pages are picked either with an offset or with the
+// cursor returned along the previous page, which keeps working while users
+// are added. A cursor only fits the sort it was returned for.
+func (s *usersService) ListUsers(filter domain.UserFilter, cursor string) (*domain.UserPage, rest_errors.RestErr) {
+	if filter.Status != "" && !domain.IsValidStatus(filter.Status) {
+		return nil, rest_errors.NewBadRequestError("invalid status")
+	}
+	if filter.Sort == "" {
+		filter.Sort = domain.UserSortId
+	}
+	if !domain.IsValidUserSort(filter.Sort) {
+		return nil, rest_errors.NewBadRequestError("invalid sort field")
+	}
+	if filter.Limit < 0 || filter.Limit > userListMaxLimit {
+		return nil, rest_errors.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d", userListMaxLimit))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = userListDefaultLimit
+	}
+	if filter.Offset < 0 {
+		return nil, rest_errors.NewBadRequestError("offset must be a positive number")
+	}
+
+	// prefixes are matched on decrypted users, only a bounded set of them
+	// can be searched
+	if filter.Prefix != "" && filter.CreatedFrom == "" && filter.CreatedTo == "" {
+		return nil, rest_errors.NewBadRequestError("filtering by prefix requires created_from or created_to")
+	}
+
+	var err rest_errors.RestErr
+	if filter.CreatedFrom, filter.CreatedTo, err = createdBounds(filter.CreatedFrom, filter.CreatedTo); err != nil {
+		return nil, err
+	}
+
+	if cursor != "" {
+		if filter.Offset != 0 {
+			return nil, rest_errors.NewBadRequestError("offset and cursor can't be used together")
+		}
+		after, err := decodeUserCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Sort != filter.Sort || after.Desc != filter.Desc {
+			return nil, rest_errors.NewBadRequestError("cursor doesn't match the sort")
+		}
+		filter.After = after
+	}
+
+	// one more user than asked tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	users, total, err := s.repo.List(filter)
+	if err != nil {
+		if err.Status() != http.StatusInternalServerError {
+			return nil, err
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to list users, try again later")
+	}
+
+	page := &domain.UserPage{Users: make([]domain.ListedUser, 0, limit), Total: total}
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[limit-1]
+		page.NextCursor = encodeUserCursor(domain.UserCursor{
+			Sort:  filter.Sort,
+			Desc:  filter.Desc,
+			Value: userSortValue(last, filter.Sort),
+			Id:    last.Id,
+		})
+	}
+	for _, user := range users {
+		page.Users = append(page.Users, domain.ListedUser{
+			Id:          user.Id,
+			FirstName:   user.FirstName,
+			LastName:    user.LastName,
+			Email:       user.Email,
+			Role:        user.Role,
+			Status:      user.Status,
+			DateCreated: user.DateCreated,
+			LastLoginAt: user.LastLoginAt,
+		})
+	}
+	return page, nil
+}
+
+// createdBounds turns the dates users are filtered by into the first and
+// last second of those days.
+func createdBounds(from, to string) (string, string, rest_errors.RestErr) {
+	var fromDate, toDate time.Time
+	var err error
+	if from != "" {
+		if fromDate, err = time.Parse(userListDateLayout, from); err != nil {
+			return "", "", rest_errors.NewBadRequestError("created_from must be a date like 2006-01-02")
+		}
+		from = fromDate.Format(dateLayout)
+	}
+	if to != "" {
+		if toDate, err = time.Parse(userListDateLayout, to); err != nil {
+			return "", "", rest_errors.NewBadRequestError("created_to must be a date like 2006-01-02")
+		}
+		if from != "" && toDate.Before(fromDate) {
+			return "", "", rest_errors.NewBadRequestError("created_to can't be before created_from")
+		}
+		to = toDate.Add(24*time.Hour - time.Second).Format(dateLayout)
+	}
+	return from, to, nil
+}
+
+func userSortValue(user domain.User, sort string) string {
+	switch sort {
+	case domain.UserSortDateCreated:
+		return user.DateCreated
+	case domain.UserSortStatus:
+		return user.Status
+	case domain.UserSortRole:
+		return user.Role
+	default:
+		return strconv.FormatInt(user.Id, 10)
+	}
+}
+
+func encodeUserCursor(cursor domain.UserCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeUserCursor(s string) (*domain.UserCursor, rest_errors.RestErr) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor()
+	}
+	var cursor domain.UserCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Id <= 0 {
+		return nil, errInvalidCursor()
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListUsers(t *testing.T) {
+	listed := []domain.User{
+		{Id: 1, FirstName: "Oscar", Email: "oscaac@gmail.com", DateCreated: "2021-03-01 10:00:00", Status: domain.StatusActive, Role: "user"},
+		{Id: 2, FirstName: "Isaac", Email: "isaac@gmail.com", DateCreated: "2021-03-02 10:00:00", Status: domain.StatusActive, Role: "admin"},
+		{Id: 3, FirstName: "Ana", Email: "ana@gmail.com", DateCreated: "2021-03-03 10:00:00", Status: domain.StatusDormant, Role: "user"},
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		var got domain.UserFilter
+		funcListUsers = func(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+			got = filter
+			return listed, 7, nil
+		}
+		s := newTestService()
+
+		page, err := s.ListUsers(domain.UserFilter{
+			CreatedFrom: "2021-03-01",
+			CreatedTo:   "2021-03-31",
+			Sort:        domain.UserSortDateCreated,
+			Desc:        true,
+			Limit:       2,
+		}, "")
+
+		assert.Nil(t, err)
+		// one more than asked to find out about the next page
+		assert.EqualValues(t, 3, got.Limit)
+		assert.EqualValues(t, "2021-03-01 00:00:00", got.CreatedFrom)
+		assert.EqualValues(t, "2021-03-31 23:59:59", got.CreatedTo)
+		assert.EqualValues(t, 7, page.Total)
+		assert.Len(t, page.Users, 2)
+		assert.NotEmpty(t, page.NextCursor)
+
+		cursor, err := decodeUserCursor(page.NextCursor)
+		assert.Nil(t, err)
+		assert.EqualValues(t, domain.UserCursor{Sort: domain.UserSortDateCreated, Desc: true, Value: "2021-03-02 10:00:00", Id: 2}, *cursor)
+
+		_, err = s.ListUsers(domain.UserFilter{Sort: domain.UserSortDateCreated, Desc: true, Limit: 2}, page.NextCursor)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, cursor, got.After)
+	})
+
+	t.Run("LastPage", func(t *testing.T) {
+		var got domain.UserFilter
+		funcListUsers = func(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+			got = filter
+			return listed, 3, nil
+		}
+		s := newTestService()
+
+		page, err := s.ListUsers(domain.UserFilter{}, "")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, domain.UserSortId, got.Sort)
+		assert.EqualValues(t, userListDefaultLimit+1, got.Limit)
+		assert.Len(t, page.Users, 3)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("InvalidFilter", func(t *testing.T) {
+		funcListUsers = func(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+			t.Fatal("users shouldn't be listed")
+			return nil, 0, nil
+		}
+		s := newTestService()
+		idCursor := encodeUserCursor(domain.UserCursor{Sort: domain.UserSortId, Value: "2", Id: 2})
+
+		for name, tc := range map[string]struct {
+			filter domain.UserFilter
+			cursor string
+		}{
+			"Status":         {filter: domain.UserFilter{Status: "gone"}},
+			"Sort":           {filter: domain.UserFilter{Sort: "email"}},
+			"Limit":          {filter: domain.UserFilter{Limit: userListMaxLimit + 1}},
+			"Date":           {filter: domain.UserFilter{CreatedFrom: "01/03/2021"}},
+			"DateRange":      {filter: domain.UserFilter{CreatedFrom: "2021-03-02", CreatedTo: "2021-03-01"}},
+			"Cursor":         {cursor: "not a cursor"},
+			"CursorSort":     {filter: domain.UserFilter{Sort: domain.UserSortRole}, cursor: idCursor},
+			"CursorOffset":   {filter: domain.UserFilter{Offset: 20}, cursor: idCursor},
+			"NegativeOffset": {filter: domain.UserFilter{Offset: -1}},
+			"PrefixAlone":    {filter: domain.UserFilter{Prefix: "osc", Role: "admin"}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				page, err := s.ListUsers(tc.filter, tc.cursor)
+
+				assert.Nil(t, page)
+				assert.EqualValues(t, http.StatusBadRequest, err.Status())
+			})
+		}
+	})
+
+	t.Run("TooManyForPrefix", func(t *testing.T) {
+		funcListUsers = func(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+			return nil, 0, rest_errors.NewBadRequestError("more than 5000 users match the filter, narrow it down to filter by prefix")
+		}
+		s := newTestService()
+
+		page, err := s.ListUsers(domain.UserFilter{Prefix: "osc", CreatedFrom: "2021-03-01"}, "")
+
+		assert.Nil(t, page)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		funcListUsers = func(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+			return nil, 0, rest_errors.NewInternalServerError("db error")
+		}
+		s := newTestService()
+
+		page, err := s.ListUsers(domain.UserFilter{}, "")
+
+		assert.Nil(t, page)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.EqualValues(t, "error while trying to list users, try again later", err.Message())
+	})
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	// loginDelayFrom is the number of failures after which every new attempt
+	// has to wait, twice as long as the previous one, up to loginMaxDelay.
+	loginDelayFrom = 3
+	loginBaseDelay = time.Second
+	loginMaxDelay  = 30 * time.Second
+)
+
+// LoginThrottle limits the failed logins of an account and of a client
+// address. Once either reaches its maximum within Window it's locked for
+// Lockout.
+type LoginThrottle struct {
+	MaxAccountFailures int
+	MaxAddressFailures int
+	Window             time.Duration
+	Lockout            time.Duration
+}
+
+func accountAttemptsKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func addressAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginDelay returns how long a client has to wait after its last failure
+// before trying again.
+func loginDelay(failures int) time.Duration {
+	if failures < loginDelayFrom {
+		return 0
+	}
+	delay := loginBaseDelay
+	for i := loginDelayFrom; i < failures && delay < loginMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > loginMaxDelay {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+func errAccountLocked(until time.Time) rest_errors.RestErr {
+	return rest_errors.NewRestError(
+		fmt.Sprintf("account is locked after too many failed logins until %s", until.UTC().Format(dateLayout)),
+		http.StatusLocked,
+		"account_locked",
+	)
+}
+
+func errTooManyAttempts(wait time.Duration) rest_errors.RestErr {
+	seconds := int(math.Ceil(wait.Seconds()))
+	return rest_errors.NewRestError(
+		fmt.Sprintf("too many failed logins, try again in %d seconds", seconds),
+		http.StatusTooManyRequests,
+		"too_many_requests",
+	)
+}
+
+// checkAttempts rejects a login for email from ip while either of them is
+// locked or hasn't waited long enough since its last failure.
+func (s *usersService) checkAttempts(email, ip string, now time.Time) rest_errors.RestErr {
+	keys := []string{accountAttemptsKey(email)}
+	if ip != "" {
+		keys = append(keys, addressAttemptsKey(ip))
+	}
+
+	for i, key := range keys {
+		attempts, err := s.attempts.Get(key)
+		if err != nil {
+			return rest_errors.NewInternalServerError("error while trying to login, try again later")
+		}
+		if attempts.LockedUntil.After(now) {
+			if i == 0 {
+				return errAccountLocked(attempts.LockedUntil)
+			}
+			return errTooManyAttempts(attempts.LockedUntil.Sub(now))
+		}
+		if retryAt := attempts.LastFailure.Add(loginDelay(attempts.Failures)); retryAt.After(now) {
+			return errTooManyAttempts(retryAt.Sub(now))
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failed login for email from ip and locks whichever
+// of them reached its maximum. It returns the error telling the client the
+// account got locked, if it did.
+func (s *usersService) recordFailure(email, ip string, now time.Time) rest_errors.RestErr {
+	if ip != "" {
+		key := addressAttemptsKey(ip)
+		if attempts, err := s.attempts.Fail(key, now, s.throttle.Window); err == nil && attempts.Failures >= s.throttle.MaxAddressFailures {
+			s.attempts.Lock(key, now.Add(s.throttle.Lockout))
+		}
+	}
+
+	key := accountAttemptsKey(email)
+	attempts, err := s.attempts.Fail(key, now, s.throttle.Window)
+	if err != nil || attempts.Failures < s.throttle.MaxAccountFailures {
+		return nil
+	}
+	until := now.Add(s.throttle.Lockout)
+	if err := s.attempts.Lock(key, until); err != nil {
+		return nil
+	}
+
+	// unknown emails are locked as well, so locking tells nothing about
+	// which accounts exist, but only real ones are announced
+	if user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email))); err == nil {
+		s.rmq.Publish("users.event.account_locked", domain.AccountLocked{
+			UserID:      user.Id,
+			Email:       user.Email,
+			FirstName:   user.FirstName,
+			Failures:    attempts.Failures,
+			LockedUntil: until.UTC().Format(dateLayout),
+		})
+	}
+	return errAccountLocked(until)
+}
+
+// Unlock lifts the lock of an account and forgets its failed logins.
+func (s *usersService) Unlock(userId int64) rest_errors.RestErr {
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return rest_errors.NewInternalServerError("error while trying to unlock user, try again later")
+		}
+		return err
+	}
+
+	if err := s.attempts.Reset(accountAttemptsKey(user.Email)); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to unlock user, try again later")
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	loginHistoryDefaultLimit = 20
+	loginHistoryMaxLimit     = 100
+	// maxUserAgentLength caps the user agent stored per login, clients
+	// choose what they send.
+	maxUserAgentLength = 255
+)
+
+// loginFailureReason names why a login failed from the error returned to the
+// client, empty for errors that say nothing about the attempt.
+func loginFailureReason(err rest_errors.RestErr) string {
+	switch err.Status() {
+	case http.StatusBadRequest:
+		return domain.LoginReasonInvalidCredentials
+	case http.StatusForbidden:
+		return domain.LoginReasonAccountBlocked
+	case http.StatusLocked:
+		return domain.LoginReasonAccountLocked
+	case http.StatusTooManyRequests:
+		return domain.LoginReasonTooManyAttempts
+	default:
+		return ""
+	}
+}
+
+// deviceFingerprint identifies the device of a client by its user agent and
+// its network, the /24 of IPv4 addresses and the /64 of IPv6 ones. It's
+// coarse on purpose: addresses change within a network all the time, while a
+// new browser or a new network is worth telling the user about.
+func deviceFingerprint(client domain.ClientInfo) string {
+	network := client.IP
+	if ip := net.ParseIP(client.IP); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			network = ip4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			network = ip.Mask(net.CIDRMask(64, 128)).String()
+		}
+	}
+	sum := sha256.Sum256([]byte(client.UserAgent + "\n" + network))
+	return hex.EncodeToString(sum[:])
+}
+
+func newLoginEvent(userId int64, client domain.ClientInfo) *domain.LoginEvent {
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return &domain.LoginEvent{
+		UserID:      userId,
+		IP:          client.IP,
+		UserAgent:   userAgent,
+		Fingerprint: deviceFingerprint(client),
+		CreatedAt:   time.Now().UTC().Format(dateLayout),
+	}
+}
+
+// recordLoginFailure adds a failed attempt on the account of userId to its
+// history. The history is best effort, failing to store it doesn't fail the
+// login.
+func (s *usersService) recordLoginFailure(userId int64, client domain.ClientInfo, reason string) {
+	if reason == "" {
+		return
+	}
+	event := newLoginEvent(userId, client)
+	event.Reason = reason
+	if err := s.loginEvents.Save(event); err != nil {
+		s.log.Error(fmt.Sprintf("error while recording login of user %d", userId), err)
+	}
+}
+
+// recordLoginFailureByEmail records an attempt rejected before the user was
+// known, looking the account up by email. Attempts on emails without an account
+// are recorded without one.
+func (s *usersService) recordLoginFailureByEmail(email string, client domain.ClientInfo, err rest_errors.RestErr) {
+	reason := loginFailureReason(err)
+	if reason == "" {
+		return
+	}
+	var userId int64
+	if user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email))); err == nil {
+		userId = user.Id
+	}
+	s.recordLoginFailure(userId, client, reason)
+}
+
+// recordFailedCode records a wrong code sent to complete a login challenge.
+func (s *usersService) recordFailedCode(userId int64, client domain.ClientInfo, err rest_errors.RestErr) {
+	reason := loginFailureReason(err)
+	if reason == domain.LoginReasonInvalidCredentials {
+		reason = domain.LoginReasonInvalidCode
+	}
+	s.recordLoginFailure(userId, client, reason)
+}
+
+// recordSuccessfulLogin adds a login to the history of user and, when it
+// comes from a device they never logged in from, tells them about it. The
+// first login of an account comes from a new device by definition and isn't
+// announced.
+func (s *usersService) recordSuccessfulLogin(user *domain.User, client domain.ClientInfo) {
+	event := newLoginEvent(user.Id, client)
+	event.Success = true
+	if total, device, err := s.loginEvents.DeviceLogins(user.Id, event.Fingerprint); err == nil {
+		event.NewDevice = total > 0 && device == 0
+	}
+	if err := s.loginEvents.Save(event); err != nil {
+		s.log.Error(fmt.Sprintf("error while recording login of user %d", user.Id), err)
+	}
+
+	if event.NewDevice {
+		s.rmq.Publish("users.event.new_device_login", domain.NewDeviceLogin{
+			UserID:     user.Id,
+			Email:      user.Email,
+			FirstName:  user.FirstName,
+			IP:         event.IP,
+			UserAgent:  event.UserAgent,
+			OccurredAt: event.CreatedAt,
+		})
+	}
+}
+
+// ListLogins returns up to limit login events of a user older than the event
+// with id before, a limit of 0 meaning the default one.
+func (s *usersService) ListLogins(userId int64, before int64, limit int) ([]domain.LoginEvent, rest_errors.RestErr) {
+	if limit < 0 || limit > loginHistoryMaxLimit {
+		return nil, rest_errors.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d", loginHistoryMaxLimit))
+	}
+	if limit == 0 {
+		limit = loginHistoryDefaultLimit
+	}
+
+	if _, err := s.repo.Get(userId); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, rest_errors.NewInternalServerError("error while trying to list logins, try again later")
+		}
+		return nil, err
+	}
+	events, err := s.loginEvents.List(userId, before, limit)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to list logins, try again later")
+	}
+	return events, nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// loginEventsMock is an in-memory history whose events tests can inspect
+type loginEventsMock struct {
+	events []domain.LoginEvent
+}
+
+func (m *loginEventsMock) Save(event *domain.LoginEvent) rest_errors.RestErr {
+	event.Id = int64(len(m.events) + 1)
+	m.events = append(m.events, *event)
+	return nil
+}
+func (m *loginEventsMock) List(userId int64, before int64, limit int) ([]domain.LoginEvent, rest_errors.RestErr) {
+	events := make([]domain.LoginEvent, 0)
+	for i := len(m.events) - 1; i >= 0 && len(events) < limit; i-- {
+		e := m.events[i]
+		if e.UserID == userId && (before == 0 || e.Id < before) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+func (m *loginEventsMock) DeviceLogins(userId int64, fingerprint string) (int, int, rest_errors.RestErr) {
+	var total, device int
+	for _, e := range m.events {
+		if e.UserID == userId && e.Success {
+			total++
+			if e.Fingerprint == fingerprint {
+				device++
+			}
+		}
+	}
+	return total, device, nil
+}
+func (m *loginEventsMock) Prune(before time.Time) (int64, rest_errors.RestErr) {
+	return 0, nil
+}
+
+func (m *loginEventsMock) last() domain.LoginEvent {
+	return m.events[len(m.events)-1]
+}
+
+var loginEventsTest = &loginEventsMock{}
+
+var (
+	laptop = domain.ClientInfo{IP: "203.0.113.7", UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0"}
+	phone  = domain.ClientInfo{IP: "198.51.100.20", UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) Safari/604.1"}
+)
+
+func TestDeviceFingerprint(t *testing.T) {
+	sameNetwork := laptop
+	sameNetwork.IP = "203.0.113.200"
+	assert.EqualValues(t, deviceFingerprint(laptop), deviceFingerprint(sameNetwork))
+
+	otherNetwork := laptop
+	otherNetwork.IP = "203.0.114.7"
+	assert.NotEqualValues(t, deviceFingerprint(laptop), deviceFingerprint(otherNetwork))
+
+	otherBrowser := laptop
+	otherBrowser.UserAgent = "Mozilla/5.0 (X11; Linux x86_64) Chrome/130.0"
+	assert.NotEqualValues(t, deviceFingerprint(laptop), deviceFingerprint(otherBrowser))
+
+	v6 := domain.ClientInfo{IP: "2001:db8:1:2::10", UserAgent: laptop.UserAgent}
+	v6Neighbour := domain.ClientInfo{IP: "2001:db8:1:2:abcd::1", UserAgent: laptop.UserAgent}
+	assert.EqualValues(t, deviceFingerprint(v6), deviceFingerprint(v6Neighbour))
+}
+
+func TestLoginHistory(t *testing.T) {
+	funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+		if s != "oscaac@gmail.com" {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+		user := userTest
+		user.Status = domain.StatusActive
+		user.Password = "$2a$10$jRL.gYiodDnwcOBErnDfuu5044h40PM3ZOAOzit6O4RIL9wG24xJ6" //password
+		return &user, nil
+	}
+
+	t.Run("FailedLogin", func(t *testing.T) {
+		attemptsTest.clear()
+		loginEventsTest.events = nil
+		s := newTestService()
+
+		s.Login("oscaac@gmail.com", "notthepassword", laptop)
+		s.Login("someone@gmail.com", "notthepassword", laptop)
+
+		if assert.Len(t, loginEventsTest.events, 2) {
+			event := loginEventsTest.events[0]
+			assert.EqualValues(t, userTest.Id, event.UserID)
+			assert.False(t, event.Success)
+			assert.EqualValues(t, domain.LoginReasonInvalidCredentials, event.Reason)
+			assert.EqualValues(t, laptop.IP, event.IP)
+			assert.EqualValues(t, laptop.UserAgent, event.UserAgent)
+
+			assert.EqualValues(t, 0, loginEventsTest.events[1].UserID)
+		}
+	})
+
+	t.Run("LockedAccount", func(t *testing.T) {
+		attemptsTest.clear()
+		loginEventsTest.events = nil
+		s := newTestService()
+
+		for i := 0; i < throttleTest.MaxAccountFailures; i++ {
+			s.Login("oscaac@gmail.com", "notthepassword", laptop)
+		}
+
+		assert.EqualValues(t, domain.LoginReasonAccountLocked, loginEventsTest.last().Reason)
+	})
+
+	t.Run("NewDevice", func(t *testing.T) {
+		attemptsTest.clear()
+		loginEventsTest.events = nil
+		rabbitMock.published = nil
+		s := newTestService()
+
+		// the first login of an account isn't announced
+		_, _, err := s.Login("oscaac@gmail.com", "password", laptop)
+		assert.Nil(t, err)
+		assert.True(t, loginEventsTest.last().Success)
+		assert.False(t, loginEventsTest.last().NewDevice)
+
+		_, _, err = s.Login("oscaac@gmail.com", "password", laptop)
+		assert.Nil(t, err)
+		assert.False(t, loginEventsTest.last().NewDevice)
+		assert.NotContains(t, rabbitMock.published, "users.event.new_device_login")
+
+		_, _, err = s.Login("oscaac@gmail.com", "password", phone)
+		assert.Nil(t, err)
+		assert.True(t, loginEventsTest.last().NewDevice)
+		assert.Contains(t, rabbitMock.published, "users.event.new_device_login")
+	})
+
+	t.Run("TwoFactorPending", func(t *testing.T) {
+		attemptsTest.clear()
+		loginEventsTest.events = nil
+		funcGetTwoFactor = enabledTwoFactor
+		funcSaveToken = func(t *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		defer func() {
+			funcGetTwoFactor = nil
+		}()
+		s := newTestService()
+
+		_, challenge, err := s.Login("oscaac@gmail.com", "password", laptop)
+
+		assert.Nil(t, err)
+		assert.NotNil(t, challenge)
+		assert.False(t, loginEventsTest.last().Success)
+		assert.EqualValues(t, domain.LoginReasonTwoFactorPending, loginEventsTest.last().Reason)
+	})
+}
+
+func TestListLogins(t *testing.T) {
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	loginEventsTest.events = nil
+	for i := 0; i < 30; i++ {
+		loginEventsTest.Save(&domain.LoginEvent{UserID: userTest.Id, Success: true})
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		s := newTestService()
+
+		events, err := s.ListLogins(userTest.Id, 0, 0)
+
+		assert.Nil(t, err)
+		assert.Len(t, events, loginHistoryDefaultLimit)
+		assert.EqualValues(t, 30, events[0].Id)
+
+		events, err = s.ListLogins(userTest.Id, events[len(events)-1].Id, 0)
+
+		assert.Nil(t, err)
+		assert.Len(t, events, 10)
+	})
+
+	t.Run("LimitTooHigh", func(t *testing.T) {
+		s := newTestService()
+
+		events, err := s.ListLogins(userTest.Id, 0, loginHistoryMaxLimit+1)
+
+		assert.Nil(t, events)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("UserNotFound", func(t *testing.T) {
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+		s := newTestService()
+
+		events, err := s.ListLogins(userTest.Id, 0, 0)
+
+		assert.Nil(t, events)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// attemptsMock is an in-memory store whose records tests can inspect
+type attemptsMock struct {
+	records map[string]domain.LoginAttempts
+	err     rest_errors.RestErr
+}
+
+func (m *attemptsMock) Get(key string) (*domain.LoginAttempts, rest_errors.RestErr) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	attempts := m.records[key]
+	return &attempts, nil
+}
+func (m *attemptsMock) Fail(key string, failedAt time.Time, window time.Duration) (*domain.LoginAttempts, rest_errors.RestErr) {
+	attempts := m.records[key]
+	if attempts.LastFailure.Before(failedAt.Add(-window)) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailure = failedAt
+	m.records[key] = attempts
+	return &attempts, nil
+}
+func (m *attemptsMock) Lock(key string, until time.Time) rest_errors.RestErr {
+	attempts := m.records[key]
+	attempts.Failures = 0
+	attempts.LockedUntil = until
+	m.records[key] = attempts
+	return nil
+}
+func (m *attemptsMock) Reset(key string) rest_errors.RestErr {
+	delete(m.records, key)
+	return nil
+}
+
+func (m *attemptsMock) clear() {
+	m.records = make(map[string]domain.LoginAttempts)
+	m.err = nil
+}
+
+var (
+	attemptsTest = &attemptsMock{records: make(map[string]domain.LoginAttempts)}
+	throttleTest = LoginThrottle{
+		MaxAccountFailures: 3,
+		MaxAddressFailures: 5,
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+	}
+)
+
+func TestLoginDelay(t *testing.T) {
+	assert.EqualValues(t, 0, loginDelay(0))
+	assert.EqualValues(t, 0, loginDelay(2))
+	assert.EqualValues(t, time.Second, loginDelay(3))
+	assert.EqualValues(t, 4*time.Second, loginDelay(5))
+	assert.EqualValues(t, loginMaxDelay, loginDelay(20))
+}
+
+func TestLoginThrottling(t *testing.T) {
+	funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+		return &userTest, nil
+	}
+
+	t.Run("LocksAccount", func(t *testing.T) {
+		attemptsTest.clear()
+		rabbitMock.published = nil
+		s := newTestService()
+
+		for i := 0; i < 2; i++ {
+			_, _, err := s.Login("oscaac@gmail.com", "notthepassword", domain.ClientInfo{})
+			assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		}
+		_, _, err := s.Login("OSCAAC@gmail.com", "notthepassword", domain.ClientInfo{})
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusLocked, err.Status())
+		assert.Contains(t, err.Message(), "account is locked after too many failed logins")
+		assert.Contains(t, rabbitMock.published, "users.event.account_locked")
+
+		// the right password doesn't help while locked
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusLocked, err.Status())
+	})
+
+	t.Run("ProgressiveDelay", func(t *testing.T) {
+		attemptsTest.clear()
+		attemptsTest.records[addressAttemptsKey("203.0.113.7")] = domain.LoginAttempts{
+			Failures:    4,
+			LastFailure: time.Now().UTC(),
+		}
+		s := newTestService()
+
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{IP: "203.0.113.7"})
+
+		assert.Nil(t, user)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusTooManyRequests, err.Status())
+		assert.EqualValues(t, "too many failed logins, try again in 2 seconds", err.Message())
+	})
+
+	t.Run("LocksAddress", func(t *testing.T) {
+		attemptsTest.clear()
+		attemptsTest.records[addressAttemptsKey("203.0.113.7")] = domain.LoginAttempts{
+			Failures:    4,
+			LastFailure: time.Now().UTC().Add(-time.Minute),
+		}
+		s := newTestService()
+
+		_, _, err := s.Login("someone@gmail.com", "notthepassword", domain.ClientInfo{IP: "203.0.113.7"})
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+
+		_, _, err = s.Login("oscaac@gmail.com", "password", domain.ClientInfo{IP: "203.0.113.7"})
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusTooManyRequests, err.Status())
+	})
+
+	t.Run("SuccessResetsAccount", func(t *testing.T) {
+		attemptsTest.clear()
+		s := newTestService()
+
+		s.Login("oscaac@gmail.com", "notthepassword", domain.ClientInfo{})
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.NotNil(t, user)
+		assert.NotContains(t, attemptsTest.records, accountAttemptsKey("oscaac@gmail.com"))
+	})
+
+	t.Run("StoreError", func(t *testing.T) {
+		attemptsTest.clear()
+		attemptsTest.err = rest_errors.NewInternalServerError("db error")
+		defer attemptsTest.clear()
+		s := newTestService()
+
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestUnlock(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		attemptsTest.clear()
+		attemptsTest.records[accountAttemptsKey(userTest.Email)] = domain.LoginAttempts{
+			LockedUntil: time.Now().UTC().Add(time.Hour),
+		}
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			return &userTest, nil
+		}
+		s := newTestService()
+
+		err := s.Unlock(userTest.Id)
+
+		assert.Nil(t, err)
+		assert.Empty(t, attemptsTest.records)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+		s := newTestService()
+
+		err := s.Unlock(userTest.Id)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
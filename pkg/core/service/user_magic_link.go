@@ -0,0 +1,144 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	// magicLinkTTL is how long a login link stays valid.
+	magicLinkTTL = 15 * time.Minute
+	// magicLinkMaxRequests is the number of login links an email can ask
+	// for, every new one within magicLinkWindow of the previous one counting
+	// towards it.
+	magicLinkMaxRequests = 5
+	magicLinkWindow      = time.Hour
+)
+
+func magicLinkAttemptsKey(email string) string {
+	return "magic_link:" + email
+}
+
+func errTooManyMagicLinks() rest_errors.RestErr {
+	return rest_errors.NewRestError("too many login links requested, try again later", http.StatusTooManyRequests, "too_many_requests")
+}
+
+// RequestMagicLink emails a single-use login link to the user registered
+// with email, invalidating the previous ones. It returns a nonce the client
+// must keep and send back along with the link, so the link only works in
+// the browser that asked for it. The result is the same whether the email is
+// registered or not, so callers can't tell which accounts exist: the link is
+// issued and sent in the background, and failing to do so is only logged.
+func (s *usersService) RequestMagicLink(email string) (string, rest_errors.RestErr) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if _, err := mail.ParseAddress(email); err != nil {
+		return "", rest_errors.NewBadRequestError("invalid email address")
+	}
+
+	// every request counts, registered email or not
+	attempts, err := s.attempts.Fail(magicLinkAttemptsKey(email), time.Now().UTC(), magicLinkWindow)
+	if err != nil {
+		return "", rest_errors.NewInternalServerError("error while trying to send login link, try again later")
+	}
+	if attempts.Failures > magicLinkMaxRequests {
+		return "", errTooManyMagicLinks()
+	}
+
+	b := make([]byte, userTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		s.log.Error("error while generating login link nonce", err)
+		return "", rest_errors.NewInternalServerError("error while trying to send login link, try again later")
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	user, err := s.repo.GetByEmail(email)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nonce, nil
+		}
+		return "", rest_errors.NewInternalServerError("error while trying to send login link, try again later")
+	}
+
+	s.inBackground(func() { s.sendMagicLink(user, nonce) })
+	return nonce, nil
+}
+
+// sendMagicLink emails user a login link bound to nonce, invalidating the
+// previous ones.
+func (s *usersService) sendMagicLink(user *domain.User, nonce string) {
+	if err := s.tokens.Invalidate(user.Id, domain.TokenPurposeMagicLink, time.Now().UTC().Format(dateLayout)); err != nil {
+		s.log.Error("error while invalidating login links", err)
+		return
+	}
+	token, err := s.issuePayloadToken(user.Id, domain.TokenPurposeMagicLink, hashUserToken(nonce), magicLinkTTL)
+	if err != nil {
+		s.log.Error("error while issuing login link", err)
+		return
+	}
+
+	if err := s.mailer.Send(domain.Email{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Open the link below to log in to your account:\n\n%s\n\n"+
+			"The link can only be used once, from the browser you asked for it in, and expires in %d minutes. "+
+			"If you didn't ask for it, you can ignore this email.\n",
+			user.FirstName, s.link("/magic-login", token), int(magicLinkTTL.Minutes())),
+	}); err != nil {
+		s.log.Error("error while sending login link", err)
+	}
+}
+
+// LoginWithMagicLink redeems a login link sent by RequestMagicLink, along
+// with the nonce returned to the client that asked for it, and logs the user
+// in the same way Login does for a right password.
+func (s *usersService) LoginWithMagicLink(token, nonce string, client domain.ClientInfo) (*domain.User, *domain.LoginChallenge, rest_errors.RestErr) {
+	t, err := s.lookupToken(domain.TokenPurposeMagicLink, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a link opened in another browser isn't used up, the one that asked
+	// for it can still redeem it
+	if subtle.ConstantTimeCompare([]byte(hashUserToken(nonce)), []byte(t.Payload)) != 1 {
+		return nil, nil, errInvalidToken()
+	}
+	if err := s.claimToken(t); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repo.Get(t.UserID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, nil, errInvalidToken()
+		}
+		return nil, nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+	}
+	s.attempts.Reset(accountAttemptsKey(user.Email))
+
+	if !reactivatedByLogin(user.Status) {
+		if err := checkCanLogin(user); err != nil {
+			s.recordLoginFailure(user.Id, client, loginFailureReason(err))
+			return nil, nil, err
+		}
+	}
+	challenge, err := s.loginChallenge(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge != nil {
+		s.recordLoginFailure(user.Id, client, domain.LoginReasonTwoFactorPending)
+		return nil, challenge, nil
+	}
+
+	user, err = s.completeLogin(user, client)
+	return user, nil, err
+}
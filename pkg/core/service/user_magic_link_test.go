@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMagicLink(t *testing.T) {
+	funcGetByEmail = func(email string) (*domain.User, rest_errors.RestErr) {
+		if email != userTest.Email {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+		user := userTest
+		return &user, nil
+	}
+	funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+		return nil
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		attemptsTest.clear()
+		var issued *domain.UserToken
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			issued = tk
+			return nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		nonce, err := s.RequestMagicLink(" " + strings.ToUpper(userTest.Email))
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, nonce)
+		if assert.NotNil(t, issued) {
+			assert.EqualValues(t, domain.TokenPurposeMagicLink, issued.Purpose)
+			// only a hash of the nonce is kept
+			assert.EqualValues(t, hashUserToken(nonce), issued.Payload)
+		}
+		if assert.Len(t, outbox.sent, 1) {
+			assert.EqualValues(t, userTest.Email, outbox.sent[0].To)
+			assert.Contains(t, outbox.sent[0].Body, "http://localhost:3000/magic-login?token=")
+		}
+	})
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		attemptsTest.clear()
+		outbox.sent = nil
+
+		s := newTestService()
+		nonce, err := s.RequestMagicLink("nobody@gmail.com")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, nonce)
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("MailerError", func(t *testing.T) {
+		attemptsTest.clear()
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		outbox.err = errors.New("connection refused")
+		defer func() { outbox.err = nil }()
+		serviceLogger.errors = nil
+
+		s := newTestService()
+		nonce, err := s.RequestMagicLink(userTest.Email)
+		waitInBackground(s)
+
+		// answered as for an unknown email
+		assert.Nil(t, err)
+		assert.NotEmpty(t, nonce)
+		assert.Contains(t, serviceLogger.errors, "error while sending login link")
+	})
+
+	t.Run("TooManyRequests", func(t *testing.T) {
+		attemptsTest.clear()
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+
+		s := newTestService()
+		for i := 0; i < magicLinkMaxRequests; i++ {
+			_, err := s.RequestMagicLink(userTest.Email)
+			waitInBackground(s)
+			assert.Nil(t, err)
+		}
+		outbox.sent = nil
+		nonce, err := s.RequestMagicLink(userTest.Email)
+		waitInBackground(s)
+
+		assert.EqualValues(t, "", nonce)
+		assert.EqualValues(t, http.StatusTooManyRequests, err.Status())
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		s := newTestService()
+		_, err := s.RequestMagicLink("not an email")
+
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+}
+
+func TestLoginWithMagicLink(t *testing.T) {
+	magicLinkToken := func(hash string) *domain.UserToken {
+		token := validToken(hash)
+		token.Purpose = domain.TokenPurposeMagicLink
+		token.Payload = hashUserToken("nonce")
+		return token
+	}
+	funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+		assert.EqualValues(t, domain.TokenPurposeMagicLink, purpose)
+		return magicLinkToken(hash), nil
+	}
+	activeUser := func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		user.Status = domain.StatusActive
+		return &user, nil
+	}
+	funcGet = activeUser
+
+	t.Run("NoError", func(t *testing.T) {
+		claimed := false
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			claimed = true
+			return true, nil
+		}
+		loginEventsTest.events = nil
+
+		s := newTestService()
+		user, challenge, err := s.LoginWithMagicLink("abc", "nonce", laptop)
+
+		assert.Nil(t, err)
+		assert.Nil(t, challenge)
+		assert.EqualValues(t, userTest.Id, user.Id)
+		assert.True(t, claimed)
+		if assert.Len(t, loginEventsTest.events, 1) {
+			assert.True(t, loginEventsTest.last().Success)
+		}
+	})
+
+	t.Run("OtherBrowser", func(t *testing.T) {
+		claimed := false
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			claimed = true
+			return true, nil
+		}
+
+		s := newTestService()
+		user, _, err := s.LoginWithMagicLink("abc", "other", laptop)
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		// the link can still be used from the right browser
+		assert.False(t, claimed)
+	})
+
+	t.Run("UsedToken", func(t *testing.T) {
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return false, nil
+		}
+
+		s := newTestService()
+		user, _, err := s.LoginWithMagicLink("abc", "nonce", laptop)
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("SuspendedUser", func(t *testing.T) {
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Status = domain.StatusSuspended
+			return &user, nil
+		}
+		defer func() { funcGet = activeUser }()
+
+		s := newTestService()
+		user, _, err := s.LoginWithMagicLink("abc", "nonce", laptop)
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusForbidden, err.Status())
+	})
+
+	t.Run("TwoFactor", func(t *testing.T) {
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcGetTwoFactor = func(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+			return &domain.TwoFactor{UserID: userId, Secret: "JBSWY3DPEHPK3PXP", EnabledAt: "2026-10-01 12:00:00"}, nil
+		}
+		defer func() { funcGetTwoFactor = nil }()
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+
+		s := newTestService()
+		user, challenge, err := s.LoginWithMagicLink("abc", "nonce", laptop)
+
+		assert.Nil(t, err)
+		assert.Nil(t, user)
+		assert.NotNil(t, challenge)
+	})
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// passwordResetTTL is how long a password reset link stays valid.
+const passwordResetTTL = time.Hour
+
+// ForgotPassword emails a password reset link to the user registered with
+// email. The result is the same whether the email is registered or not, so
+// callers can't tell which accounts exist: the link is issued and sent in
+// the background, and failing to do so is only logged.
+func (s *usersService) ForgotPassword(email string) rest_errors.RestErr {
+	user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil
+		}
+		return rest_errors.NewInternalServerError("error while trying to send password reset email, try again later")
+	}
+
+	s.inBackground(func() { s.sendPasswordReset(user) })
+	return nil
+}
+
+// sendPasswordReset emails user a new password reset link, unless one was
+// sent moments ago.
+func (s *usersService) sendPasswordReset(user *domain.User) {
+	token, err := s.reissueToken(user.Id, domain.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		s.log.Error("error while issuing password reset token", err)
+		return
+	}
+	if token == "" {
+		return
+	}
+
+	if err := s.mailer.Send(domain.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We received a request to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\n"+
+			"The link expires in %d minutes. If you didn't ask for it, you can ignore this email, your password won't change.\n",
+			user.FirstName, s.link("/reset-password", token), int(passwordResetTTL.Minutes())),
+	}); err != nil {
+		s.log.Error("error while sending password reset email", err)
+	}
+}
+
+// ResetPassword redeems a password reset token and sets a new password. The
+// sessions opened with the old password are revoked.
+func (s *usersService) ResetPassword(token, password string) rest_errors.RestErr {
+	if password == "" {
+		return rest_errors.NewBadRequestError("invalid password")
+	}
+
+	t, err := s.lookupToken(domain.TokenPurposePasswordReset, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.Get(t.UserID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return errInvalidToken()
+		}
+		return rest_errors.NewInternalServerError("error while trying to reset password, try again later")
+	}
+	// the token is only used once the password is accepted, so a rejected one
+	// can be fixed without asking for another email
+	if err := s.policy.Check(password, user); err != nil {
+		return err
+	}
+	if err := s.claimToken(t); err != nil {
+		return err
+	}
+
+	if err := s.setPassword(user, password); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to reset password, try again later")
+	}
+	now := user.LastModified
+	// other links sent before this one must not work anymore
+	if err := s.tokens.Invalidate(user.Id, domain.TokenPurposePasswordReset, now); err != nil {
+		s.log.Error("error while invalidating password reset tokens", err)
+	}
+
+	// the link proves the user owns the address as much as a verification
+	// link does
+	if user.Status == domain.StatusPendingVerification {
+		if err := s.repo.SetStatus(user.Id, user.Status, domain.StatusActive, now); err == nil {
+			s.publishStatusChange(user, domain.StatusActive)
+		}
+	}
+
+	s.rmq.Publish("users.event.password_reset", domain.PasswordReset{
+		UserID:    user.Id,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		ResetAt:   now,
+	})
+	return nil
+}
+
+// ChangePassword sets a new password for a user that proves they know the
+// current one. Every session of the user is ended and they are notified.
+func (s *usersService) ChangePassword(userId int64, currentPassword, password string) rest_errors.RestErr {
+	if password == "" {
+		return rest_errors.NewBadRequestError("invalid password")
+	}
+
+	user, err := s.checkPassword(userId, currentPassword)
+	if err != nil {
+		return err
+	}
+	if password == currentPassword {
+		return rest_errors.NewBadRequestError("the new password must be different from the current one")
+	}
+	if err := s.policy.Check(password, user); err != nil {
+		return err
+	}
+
+	if err := s.setPassword(user, password); err != nil {
+		return rest_errors.NewInternalServerError("error while trying to change password, try again later")
+	}
+	s.publishSecurityEvent(user, domain.SecurityEventPasswordChanged)
+	return nil
+}
+
+// setPassword hashes and stores a new password for user and ends their
+// sessions. Their access tokens are revoked too, whoever had the old
+// password may have created some.
+func (s *usersService) setPassword(user *domain.User, password string) rest_errors.RestErr {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		s.log.Error("error while hashing password", err)
+		return rest_errors.NewInternalServerError("error while hashing password")
+	}
+
+	revocation, revocationErr := s.requestSessionRevocation(user.Id)
+	if revocationErr != nil {
+		return revocationErr
+	}
+	user.LastModified = revocation.RequestedAt
+	if err := s.repo.UpdatePassword(user.Id, hashedPassword, user.LastModified); err != nil {
+		return err
+	}
+	s.revokeSessions(revocation)
+	s.revokeAccessTokens(user.Id)
+	return nil
+}
+
+// publishSecurityEvent notifies user of a change to the way they log in.
+func (s *usersService) publishSecurityEvent(user *domain.User, kind string) {
+	s.rmq.Publish("users.event.security", domain.SecurityEvent{
+		UserID:     user.Id,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		Type:       kind,
+		OccurredAt: time.Now().UTC().Format(dateLayout),
+	})
+}
+
+// revokeAccessTokens revokes the access tokens of a user after a credential
+// reset. The reset is already stored, so a failure is logged instead of
+// returned.
+func (s *usersService) revokeAccessTokens(userId int64) {
+	if err := s.accessTokens.RevokeAll(userId, time.Now().UTC().Format(dateLayout)); err != nil {
+		s.log.Error(fmt.Sprintf("error while revoking access tokens of user %d", userId), err)
+	}
+}
+
+// requestSessionRevocation records that the sessions of a user must end. It
+// is called before storing the credential change or deletion calling for it,
+// which must not go ahead when it fails: once recorded, a revocation the
+// oauth API doesn't take is retried until it does.
+func (s *usersService) requestSessionRevocation(userId int64) (domain.SessionRevocation, rest_errors.RestErr) {
+	revocation := domain.SessionRevocation{
+		UserID:      userId,
+		RequestedAt: time.Now().UTC().Format(dateLayout),
+	}
+	if err := s.revocations.Enqueue(revocation.UserID, revocation.RequestedAt); err != nil {
+		return revocation, rest_errors.NewInternalServerError("error while trying to end the sessions of the user, try again later")
+	}
+	return revocation, nil
+}
+
+// revokeSessions ends the sessions of a user once the change that requested
+// it is stored. When the oauth API can't be reached the revocation is left
+// recorded for the SessionRevocationRetrier.
+func (s *usersService) revokeSessions(revocation domain.SessionRevocation) {
+	if err := s.sessions.RevokeSessions(revocation.UserID); err != nil {
+		s.log.Error(fmt.Sprintf("error while revoking sessions of user %d, retrying later", revocation.UserID), err)
+		return
+	}
+	s.revocations.Done(revocation)
+}
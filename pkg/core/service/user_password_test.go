@@ -0,0 +1,276 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type sessionRevokerMock struct {
+	revoked []int64
+	err     error
+}
+
+func (m *sessionRevokerMock) RevokeSessions(userId int64) error {
+	m.revoked = append(m.revoked, userId)
+	return m.err
+}
+
+var sessionsMock = &sessionRevokerMock{}
+
+func TestForgotPassword(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcGetLatestToken = func(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			return nil
+		}
+		var saved *domain.UserToken
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			saved = tk
+			return nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		err := s.ForgotPassword("oscaac@gmail.com")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, domain.TokenPurposePasswordReset, saved.Purpose)
+		assert.Len(t, outbox.sent, 1)
+		assert.Contains(t, outbox.sent[0].Body, "http://localhost:3000/reset-password?token=")
+	})
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		err := s.ForgotPassword("nobody@gmail.com")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("MailerError", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcGetLatestToken = func(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		outbox.err = errors.New("connection refused")
+		defer func() { outbox.err = nil }()
+		serviceLogger.errors = nil
+
+		s := newTestService()
+		err := s.ForgotPassword("oscaac@gmail.com")
+		waitInBackground(s)
+
+		// answered as for an unknown email
+		assert.Nil(t, err)
+		assert.Contains(t, serviceLogger.errors, "error while sending password reset email")
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			assert.EqualValues(t, domain.TokenPurposePasswordReset, purpose)
+			return validToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			return nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		var hashed string
+		funcUpdatePassword = func(id int64, password, modifiedAt string) rest_errors.RestErr {
+			hashed = password
+			return nil
+		}
+		sessionsMock.revoked = nil
+		accessTokensTest.tokens = []domain.AccessToken{{Id: 1, UserID: 1}}
+
+		s := newTestService()
+		err := s.ResetPassword("abc", "newpassword")
+
+		assert.Nil(t, err)
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(hashed), []byte("newpassword")))
+		assert.EqualValues(t, []int64{1}, sessionsMock.revoked)
+		assert.NotContains(t, revocationsTest.pending, int64(1))
+		assert.NotEmpty(t, accessTokensTest.tokens[0].RevokedAt)
+	})
+
+	t.Run("RevokeError", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return validToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			return nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcUpdatePassword = func(id int64, password, modifiedAt string) rest_errors.RestErr {
+			return nil
+		}
+		sessionsMock.err = errors.New("unavailable")
+		defer func() { sessionsMock.err = nil }()
+		revocationsTest.pending = nil
+
+		s := newTestService()
+		err := s.ResetPassword("abc", "newpassword")
+
+		assert.Nil(t, err)
+		// left for the retrier
+		assert.Contains(t, revocationsTest.pending, int64(1))
+	})
+
+	t.Run("RevocationNotRecorded", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return validToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		updated := false
+		funcUpdatePassword = func(id int64, password, modifiedAt string) rest_errors.RestErr {
+			updated = true
+			return nil
+		}
+		revocationsTest.err = rest_errors.NewInternalServerError("db error")
+		defer func() { revocationsTest.err = nil }()
+
+		s := newTestService()
+		err := s.ResetPassword("abc", "newpassword")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.False(t, updated)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+
+		s := newTestService()
+		err := s.ResetPassword("abc", "newpassword")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "invalid or expired token", err.Message())
+	})
+
+	t.Run("EmptyPassword", func(t *testing.T) {
+		s := newTestService()
+		err := s.ResetPassword("abc", "")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "invalid password", err.Message())
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		var hashed string
+		funcUpdatePassword = func(id int64, password, modifiedAt string) rest_errors.RestErr {
+			hashed = password
+			return nil
+		}
+		sessionsMock.revoked = nil
+		accessTokensTest.tokens = []domain.AccessToken{{Id: 1, UserID: 1}, {Id: 2, UserID: 2}}
+
+		s := newTestService()
+		err := s.ChangePassword(1, "password", "Ulysses")
+
+		assert.Nil(t, err)
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(hashed), []byte("Ulysses")))
+		assert.EqualValues(t, []int64{1}, sessionsMock.revoked)
+		assert.NotEmpty(t, accessTokensTest.tokens[0].RevokedAt)
+		// tokens of other users are left alone
+		assert.Empty(t, accessTokensTest.tokens[1].RevokedAt)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+
+		s := newTestService()
+		err := s.ChangePassword(1, "wrong", "Ulysses")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+		assert.EqualValues(t, "invalid password", err.Message())
+	})
+
+	t.Run("LocksAccount", func(t *testing.T) {
+		s := newTestService()
+
+		assertLocksAccount(t, func(password string) rest_errors.RestErr {
+			return s.ChangePassword(1, password, "Ulysses")
+		})
+	})
+
+	t.Run("SamePassword", func(t *testing.T) {
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+
+		s := newTestService()
+		err := s.ChangePassword(1, "password", "password")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceDeletedUsersPurger     sync.Once
+	instanceDeletedUsersPurger *deletedUsersPurger
+)
+
+type deletedUsersPurger struct {
+	users          ports.UsersRepository
+	paymentOptions ports.PaymentOptionRepository
+	vault          ports.CardVault
+	rmq            ports.UserRMQ
+	grace          time.Duration
+}
+
+// purgeBatchSize caps how many users a single run purges, the rest are left
+// for the next one.
+const purgeBatchSize = 100
+
+// NewDeletedUsersPurger returns a purger for the users deleted more than
+// grace ago.
+func NewDeletedUsersPurger(users ports.UsersRepository, paymentOptions ports.PaymentOptionRepository, vault ports.CardVault, rmq ports.UserRMQ, grace time.Duration) ports.DeletedUsersPurger {
+	onceDeletedUsersPurger.Do(func() {
+		instanceDeletedUsersPurger = &deletedUsersPurger{
+			users:          users,
+			paymentOptions: paymentOptions,
+			vault:          vault,
+			rmq:            rmq,
+			grace:          grace,
+		}
+	})
+	return instanceDeletedUsersPurger
+}
+
+// PurgeDeleted purges the users whose grace period is over and returns how
+// many were purged. Their addresses and payment options are removed, the card
+// numbers dropped from the vault and the user row anonymized. Users restored
+// by a login in the meantime are skipped.
+func (p *deletedUsersPurger) PurgeDeleted(now time.Time) (int, rest_errors.RestErr) {
+	users, err := p.users.ListDeleted(now.Add(-p.grace).UTC().Format(dateLayout), purgeBatchSize)
+	if err != nil {
+		return 0, rest_errors.NewInternalServerError("error while trying to get deleted users")
+	}
+
+	purged := 0
+	for _, user := range users {
+		options, err := p.paymentOptions.List(user.Id)
+		if err != nil {
+			return purged, rest_errors.NewInternalServerError("error while trying to get payment options of deleted user")
+		}
+
+		if err := p.users.Purge(user.Id, now.UTC().Format(dateLayout)); err != nil {
+			if err.Status() == http.StatusInternalServerError {
+				return purged, rest_errors.NewInternalServerError("error while trying to purge deleted user")
+			}
+			continue
+		}
+
+		// the rows referencing these tokens are gone, a token that can't be
+		// dropped now is unreachable but holds no link to the user anymore
+		for _, po := range options {
+			p.vault.Delete(po.CardToken)
+		}
+
+		p.rmq.Publish("users.event.status_changed", domain.UserStatusChanged{
+			UserID:    user.Id,
+			From:      domain.StatusDeleted,
+			To:        domain.StatusPurged,
+			ChangedAt: now.UTC().Format(dateLayout),
+		})
+		p.rmq.Publish("users.event.delete", domain.UserDeletion{
+			UserID:    user.Id,
+			Step:      domain.DeletionPurged,
+			DeletedAt: user.DeletedAt,
+		})
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeDeleted(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+	deleted := []domain.User{
+		{Id: 1, Status: "deleted", DeletedAt: "2026-09-01 10:00:00"},
+		{Id: 2, Status: "deleted", DeletedAt: "2026-09-02 10:00:00"},
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		expiringRMQ.events = nil
+		cardVaultMock.cards["tok_purge"] = "4242424242424242"
+		var before string
+		funcListDeleted = func(b string, l int) ([]domain.User, rest_errors.RestErr) {
+			before = b
+			return deleted, nil
+		}
+		funcListPaymentOptions = func(u int64) ([]domain.PaymentOption, rest_errors.RestErr) {
+			if u == 1 {
+				return []domain.PaymentOption{{Id: 1, UserID: 1, CardToken: "tok_purge"}}, nil
+			}
+			return []domain.PaymentOption{}, nil
+		}
+		funcPurge = func(i int64, p string) rest_errors.RestErr {
+			// user 2 logged in again before being purged
+			if i == 2 {
+				return rest_errors.NewRestError("user is not deleted", http.StatusConflict, "conflict")
+			}
+			return nil
+		}
+
+		p := NewDeletedUsersPurger(repoMock, paymentOptionRepo, cardVaultMock, expiringRMQ, 30*24*time.Hour)
+		purged, err := p.PurgeDeleted(now)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 1, purged)
+		assert.EqualValues(t, "2026-09-17 12:00:00", before)
+		_, stored := cardVaultMock.cards["tok_purge"]
+		assert.False(t, stored)
+		assert.Len(t, expiringRMQ.events, 2)
+		assert.EqualValues(t, domain.StatusPurged, expiringRMQ.events[0].(domain.UserStatusChanged).To)
+		event := expiringRMQ.events[1].(domain.UserDeletion)
+		assert.EqualValues(t, domain.DeletionPurged, event.Step)
+		assert.EqualValues(t, 1, event.UserID)
+		assert.Empty(t, event.Email)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		funcListDeleted = func(b string, l int) ([]domain.User, rest_errors.RestErr) {
+			return deleted, nil
+		}
+		funcListPaymentOptions = func(u int64) ([]domain.PaymentOption, rest_errors.RestErr) {
+			return []domain.PaymentOption{}, nil
+		}
+		funcPurge = func(i int64, p string) rest_errors.RestErr {
+			return rest_errors.NewInternalServerError("db error")
+		}
+
+		p := NewDeletedUsersPurger(repoMock, paymentOptionRepo, cardVaultMock, expiringRMQ, 30*24*time.Hour)
+		purged, err := p.PurgeDeleted(now)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, 0, purged)
+	})
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
@@ -15,11 +17,28 @@ type userRepoMock struct{}
 
 var (
 	funcGet         func(int64) (*domain.User, rest_errors.RestErr)
-	funcDelete      func(int64) rest_errors.RestErr
+	funcDelete      func(int64, string) rest_errors.RestErr
 	funcGetByEmail  func(string) (*domain.User, rest_errors.RestErr)
 	funcSave        func(*domain.User) rest_errors.RestErr
 	funcUpdate      func(*domain.User) rest_errors.RestErr
 	funcUpdateAdmin func(*domain.User) rest_errors.RestErr
+	funcSetStatus   func(int64, string, string, string) rest_errors.RestErr
+
+	funcUpdatePassword func(int64, string, string) rest_errors.RestErr
+	funcUpdateEmail    func(int64, string, string) rest_errors.RestErr
+
+	funcRestore     func(int64) (string, rest_errors.RestErr)
+	funcListDeleted func(string, int) ([]domain.User, rest_errors.RestErr)
+	funcPurge       func(int64, string) rest_errors.RestErr
+
+	funcRecordLogin          func(int64, string) rest_errors.RestErr
+	funcListInactive         func(string, int) ([]domain.User, rest_errors.RestErr)
+	funcListDormancyWarned   func(string, int) ([]domain.User, rest_errors.RestErr)
+	funcMarkDormancyWarned   func(int64, string) (bool, rest_errors.RestErr)
+	funcUnmarkDormancyWarned func(int64) rest_errors.RestErr
+	funcMarkDormant          func(int64, string) rest_errors.RestErr
+
+	funcListUsers func(domain.UserFilter) ([]domain.User, int, rest_errors.RestErr)
 )
 
 var (
@@ -29,8 +48,8 @@ var (
 func (m *userRepoMock) Get(id int64) (*domain.User, rest_errors.RestErr) {
 	return funcGet(id)
 }
-func (m *userRepoMock) Delete(id int64) rest_errors.RestErr {
-	return funcDelete(id)
+func (m *userRepoMock) Delete(id int64, deletedAt string) rest_errors.RestErr {
+	return funcDelete(id, deletedAt)
 }
 func (m *userRepoMock) GetByEmail(email string) (*domain.User, rest_errors.RestErr) {
 	return funcGetByEmail(email)
@@ -44,10 +63,57 @@ func (m *userRepoMock) Update(user *domain.User) rest_errors.RestErr {
 func (m *userRepoMock) UpdateAdmin(user *domain.User) rest_errors.RestErr {
 	return funcUpdateAdmin(user)
 }
+func (m *userRepoMock) UpdateEmail(id int64, email, modifiedAt string) rest_errors.RestErr {
+	return funcUpdateEmail(id, email, modifiedAt)
+}
+func (m *userRepoMock) UpdatePassword(id int64, password, modifiedAt string) rest_errors.RestErr {
+	return funcUpdatePassword(id, password, modifiedAt)
+}
+func (m *userRepoMock) SetStatus(id int64, from, to, modifiedAt string) rest_errors.RestErr {
+	return funcSetStatus(id, from, to, modifiedAt)
+}
+func (m *userRepoMock) Restore(id int64) (string, rest_errors.RestErr) {
+	return funcRestore(id)
+}
+func (m *userRepoMock) ListDeleted(before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	return funcListDeleted(before, limit)
+}
+func (m *userRepoMock) Purge(id int64, purgedAt string) rest_errors.RestErr {
+	return funcPurge(id, purgedAt)
+}
+func (m *userRepoMock) RecordLogin(id int64, loggedInAt string) rest_errors.RestErr {
+	// most tests log users in without caring about it
+	if funcRecordLogin == nil {
+		return nil
+	}
+	return funcRecordLogin(id, loggedInAt)
+}
+func (m *userRepoMock) ListInactive(before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	return funcListInactive(before, limit)
+}
+func (m *userRepoMock) ListDormancyWarned(before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	return funcListDormancyWarned(before, limit)
+}
+func (m *userRepoMock) MarkDormancyWarned(id int64, warnedAt string) (bool, rest_errors.RestErr) {
+	return funcMarkDormancyWarned(id, warnedAt)
+}
+func (m *userRepoMock) UnmarkDormancyWarned(id int64) rest_errors.RestErr {
+	return funcUnmarkDormancyWarned(id)
+}
+func (m *userRepoMock) MarkDormant(id int64, modifiedAt string) rest_errors.RestErr {
+	return funcMarkDormant(id, modifiedAt)
+}
+func (m *userRepoMock) List(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+	return funcListUsers(filter)
+}
 
-type rmqMock struct{}
+// rmqMock keeps the routing keys it published to
+type rmqMock struct {
+	published []string
+}
 
 func (r *rmqMock) Publish(routingKey string, e interface{}) error {
+	r.published = append(r.published, routingKey)
 	return nil
 }
 
@@ -55,6 +121,59 @@ var (
 	rabbitMock = &rmqMock{}
 )
 
+// hasherMock hashes with bcrypt at its minimum cost and asks for a rehash of
+// every matching password while rehash is set. Like the real hasher, it
+// doesn't recognize anything else as a hash.
+type hasherMock struct {
+	rehash bool
+}
+
+func (h *hasherMock) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	return string(hash), err
+}
+func (h *hasherMock) Verify(password, hash string) (bool, bool, error) {
+	if !strings.HasPrefix(hash, "$2") {
+		return false, false, ports.ErrUnknownHashFormat
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false, nil
+	}
+	return true, h.rehash, nil
+}
+
+var (
+	hasherTest = &hasherMock{}
+)
+
+// newTestService returns the users service built on the mocks of this
+// package, tests tweak the mocks rather than the service.
+func newTestService() ports.UsersService {
+	return NewUsersService(UsersServiceDeps{
+		Repo:              repoMock,
+		RMQ:               rabbitMock,
+		Tokens:            tokenRepo,
+		Mailer:            outbox,
+		Sessions:          sessionsMock,
+		Revocations:       revocationsTest,
+		Policy:            policyTest,
+		Attempts:          attemptsTest,
+		Throttle:          throttleTest,
+		Hasher:            hasherTest,
+		TwoFactor:         twoFactorRepo,
+		TwoFactorSettings: twoFactorTest,
+		LoginEvents:       loginEventsTest,
+		AccessTokens:      accessTokensTest,
+		Log:               serviceLogger,
+		AppURL:            "http://localhost:3000/",
+	})
+}
+
+// waitInBackground waits for the work s started off the request path.
+func waitInBackground(s ports.UsersService) {
+	s.(*usersService).background.Wait()
+}
+
 var (
 	userTest = domain.User{
 		Id:          1,
@@ -74,7 +193,7 @@ func TestGetUser(t *testing.T) {
 			return &userTest, nil
 		}
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 		user, err := s.GetUser(1)
 
 		assert.Nil(t, err)
@@ -88,7 +207,7 @@ func TestGetUser(t *testing.T) {
 			return nil, rest_errors.NewNotFoundError("user not found")
 		}
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 		user, err := s.GetUser(1)
 
 		assert.Nil(t, user)
@@ -102,7 +221,7 @@ func TestGetUser(t *testing.T) {
 			return nil, rest_errors.NewInternalServerError("db error")
 		}
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 		user, err := s.GetUser(1)
 
 		assert.Nil(t, user)
@@ -114,7 +233,7 @@ func TestGetUser(t *testing.T) {
 
 func TestRegister(t *testing.T) {
 	t.Run("UserNotValid", func(t *testing.T) {
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		user := domain.User{Email: ""}
 		err := s.Register(&user)
@@ -127,8 +246,14 @@ func TestRegister(t *testing.T) {
 		funcSave = func(u *domain.User) rest_errors.RestErr {
 			return nil
 		}
+		var saved *domain.UserToken
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			saved = tk
+			return nil
+		}
+		outbox.sent = nil
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		user := userTest
 		user.Password = "password"
@@ -137,13 +262,42 @@ func TestRegister(t *testing.T) {
 		assert.Nil(t, err)
 		errB := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password"))
 		assert.Nil(t, errB)
+		assert.EqualValues(t, domain.StatusPendingVerification, user.Status)
+
+		assert.NotNil(t, saved)
+		assert.EqualValues(t, domain.TokenPurposeEmailVerification, saved.Purpose)
+		assert.Len(t, outbox.sent, 1)
+		assert.EqualValues(t, user.Email, outbox.sent[0].To)
+		assert.Contains(t, outbox.sent[0].Body, "http://localhost:3000/verify?token=")
+		token := outbox.sent[0].Body[strings.Index(outbox.sent[0].Body, "token=")+len("token="):]
+		token = token[:strings.Index(token, "\n")]
+		assert.EqualValues(t, hashUserToken(token), saved.TokenHash)
+	})
+
+	t.Run("MailerError", func(t *testing.T) {
+		funcSave = func(u *domain.User) rest_errors.RestErr {
+			return nil
+		}
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		outbox.err = errors.New("connection refused")
+		defer func() { outbox.err = nil }()
+
+		s := newTestService()
+
+		user := userTest
+		user.Password = "password"
+		err := s.Register(&user)
+
+		assert.Nil(t, err)
 	})
 
 	t.Run("DbError", func(t *testing.T) {
 		funcSave = func(u *domain.User) rest_errors.RestErr {
 			return rest_errors.NewInternalServerError("db error")
 		}
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		user := userTest
 		err := s.Register(&user)
@@ -158,22 +312,46 @@ func TestLogin(t *testing.T) {
 		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
 			return &userTest, nil
 		}
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
-		user, err := s.Login("oscaac@gmail.com", "password")
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
 
 		assert.Nil(t, err)
 		assert.NotNil(t, user)
 		assert.EqualValues(t, "Oscar", user.FirstName)
 	})
 
+	t.Run("RehashesPassword", func(t *testing.T) {
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		var rehashed string
+		funcUpdatePassword = func(i int64, p, m string) rest_errors.RestErr {
+			rehashed = p
+			return nil
+		}
+		hasherTest.rehash = true
+		defer func() {
+			hasherTest.rehash = false
+		}()
+		s := newTestService()
+
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.NotEqual(t, userTest.Password, rehashed)
+		assert.EqualValues(t, rehashed, user.Password)
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(rehashed), []byte("password")))
+	})
+
 	t.Run("InvalidCredentials", func(t *testing.T) {
 		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
 			return &userTest, nil
 		}
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
-		user, err := s.Login("oscaac@gmail.com", "notthepassword")
+		user, _, err := s.Login("oscaac@gmail.com", "notthepassword", domain.ClientInfo{})
 
 		assert.Nil(t, user)
 		assert.NotNil(t, err)
@@ -181,13 +359,139 @@ func TestLogin(t *testing.T) {
 		assert.EqualValues(t, http.StatusBadRequest, err.Status())
 	})
 
+	t.Run("UnknownHashFormat", func(t *testing.T) {
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			// purged users keep an empty password
+			user := userTest
+			user.Email = "deleted-1"
+			user.Password = ""
+			user.Status = domain.StatusPurged
+			return &user, nil
+		}
+		attemptsTest.clear()
+		serviceLogger.warns = nil
+		s := newTestService()
+
+		user, _, err := s.Login("deleted-1", "", domain.ClientInfo{})
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid credentials", err.Message())
+		assert.EqualValues(t, 1, attemptsTest.records[accountAttemptsKey("deleted-1")].Failures)
+		assert.Len(t, serviceLogger.warns, 1)
+	})
+
+	t.Run("CancelsDeletion", func(t *testing.T) {
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Status = "deleted"
+			return &user, nil
+		}
+		var restored int64
+		funcRestore = func(i int64) (string, rest_errors.RestErr) {
+			restored = i
+			return domain.StatusActive, nil
+		}
+		s := newTestService()
+
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, userTest.Id, restored)
+		assert.EqualValues(t, "active", user.Status)
+	})
+
+	t.Run("CancelsDeletionKeepingStatus", func(t *testing.T) {
+		tests := map[string]string{
+			domain.StatusPendingVerification: "email address is not verified yet",
+			domain.StatusSuspended:           "account is suspended",
+			domain.StatusInactive:            "account is inactive",
+		}
+		for status, message := range tests {
+			funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+				user := userTest
+				user.Status = domain.StatusDeleted
+				return &user, nil
+			}
+			funcRestore = func(i int64) (string, rest_errors.RestErr) {
+				return status, nil
+			}
+			rabbitMock.published = nil
+			s := newTestService()
+
+			user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+			assert.Nil(t, user)
+			if assert.NotNil(t, err, status) {
+				assert.EqualValues(t, http.StatusForbidden, err.Status())
+				assert.EqualValues(t, message, err.Message())
+			}
+			assert.Contains(t, rabbitMock.published, "users.event.status_changed")
+		}
+		funcRestore = func(i int64) (string, rest_errors.RestErr) {
+			return domain.StatusActive, nil
+		}
+	})
+
+	t.Run("ReactivatesDormant", func(t *testing.T) {
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Status = domain.StatusDormant
+			return &user, nil
+		}
+		var from, to string
+		funcSetStatus = func(i int64, f, t, m string) rest_errors.RestErr {
+			from, to = f, t
+			return nil
+		}
+		var loggedIn int64
+		funcRecordLogin = func(i int64, l string) rest_errors.RestErr {
+			loggedIn = i
+			return nil
+		}
+		defer func() { funcRecordLogin = nil }()
+		s := newTestService()
+
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, domain.StatusDormant, from)
+		assert.EqualValues(t, domain.StatusActive, to)
+		assert.EqualValues(t, domain.StatusActive, user.Status)
+		assert.EqualValues(t, userTest.Id, loggedIn)
+	})
+
+	t.Run("RejectedByStatus", func(t *testing.T) {
+		tests := map[string]string{
+			domain.StatusPendingVerification: "email address is not verified yet",
+			domain.StatusSuspended:           "account is suspended",
+			domain.StatusInactive:            "account is inactive",
+		}
+		for status, message := range tests {
+			funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+				user := userTest
+				user.Status = status
+				return &user, nil
+			}
+			s := newTestService()
+
+			user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+			assert.Nil(t, user)
+			if assert.NotNil(t, err, status) {
+				assert.EqualValues(t, http.StatusForbidden, err.Status())
+				assert.EqualValues(t, message, err.Message())
+			}
+		}
+	})
+
 	t.Run("UserNotFound", func(t *testing.T) {
 		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
 			return nil, rest_errors.NewNotFoundError("user not found")
 		}
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
-		user, err := s.Login("oscaac@gmail.com", "password")
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
 
 		assert.Nil(t, user)
 		assert.NotNil(t, err)
@@ -199,9 +503,9 @@ func TestLogin(t *testing.T) {
 		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
 			return nil, rest_errors.NewInternalServerError("db error")
 		}
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
-		user, err := s.Login("oscaac@gmail.com", "password")
+		user, _, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
 
 		assert.Nil(t, user)
 		assert.NotNil(t, err)
@@ -215,7 +519,6 @@ func TestUpdate(t *testing.T) {
 		Id:        1,
 		FirstName: "Llewyn",
 		LastName:  "Davis",
-		Password:  "Ulysses",
 	}
 
 	t.Run("NoError", func(t *testing.T) {
@@ -228,7 +531,7 @@ func TestUpdate(t *testing.T) {
 			return nil
 		}
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		update := userTestUpdate
 
@@ -237,9 +540,48 @@ func TestUpdate(t *testing.T) {
 		assert.Nil(t, err)
 		assert.EqualValues(t, "oscaac@gmail.com", update.Email)
 		assert.EqualValues(t, "Llewyn", update.FirstName)
+	})
 
-		errB := bcrypt.CompareHashAndPassword([]byte(update.Password), []byte("Ulysses"))
-		assert.Nil(t, errB)
+	t.Run("PasswordNotAllowed", func(t *testing.T) {
+		s := newTestService()
+
+		update := userTestUpdate
+		update.Password = "Ulysses"
+
+		err := s.Update(&update, false)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+
+	t.Run("PasswordByAdmin", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			return nil
+		}
+		funcUpdateAdmin = func(u *domain.User) rest_errors.RestErr {
+			return nil
+		}
+		var hashed string
+		funcUpdatePassword = func(id int64, password, modifiedAt string) rest_errors.RestErr {
+			hashed = password
+			return nil
+		}
+		sessionsMock.revoked = nil
+
+		s := newTestService()
+
+		update := userTestUpdate
+		update.Password = "Ulysses"
+
+		err := s.Update(&update, true)
+
+		assert.Nil(t, err)
+		assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(hashed), []byte("Ulysses")))
+		assert.EqualValues(t, []int64{1}, sessionsMock.revoked)
 	})
 
 	t.Run("SearchError", func(t *testing.T) {
@@ -247,7 +589,7 @@ func TestUpdate(t *testing.T) {
 			return nil, rest_errors.NewInternalServerError("db error")
 		}
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		update := userTestUpdate
 
@@ -263,7 +605,7 @@ func TestUpdate(t *testing.T) {
 			return nil, rest_errors.NewNotFoundError("user not found")
 		}
 
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		update := userTestUpdate
 
@@ -283,7 +625,7 @@ func TestUpdate(t *testing.T) {
 		funcUpdate = func(u *domain.User) rest_errors.RestErr {
 			return rest_errors.NewInternalServerError("db error")
 		}
-		s := NewUsersService(repoMock, rabbitMock)
+		s := newTestService()
 
 		update := userTestUpdate
 
@@ -293,6 +635,48 @@ func TestUpdate(t *testing.T) {
 		assert.EqualValues(t, "error while trying to update user, try again later", err.Message())
 		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	})
+
+	t.Run("StatusTransitions", func(t *testing.T) {
+		funcUpdate = func(u *domain.User) rest_errors.RestErr {
+			return nil
+		}
+		funcUpdateAdmin = func(u *domain.User) rest_errors.RestErr {
+			return nil
+		}
+
+		tests := []struct {
+			from, to string
+			status   int
+		}{
+			{domain.StatusActive, domain.StatusSuspended, 0},
+			{domain.StatusSuspended, domain.StatusActive, 0},
+			{domain.StatusInactive, domain.StatusSuspended, http.StatusConflict},
+			{domain.StatusPendingVerification, domain.StatusInactive, http.StatusConflict},
+			{domain.StatusActive, domain.StatusDeleted, http.StatusBadRequest},
+			{domain.StatusDeleted, domain.StatusActive, http.StatusConflict},
+			{domain.StatusActive, "banned", http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+				user := userTest
+				user.Status = tt.from
+				return &user, nil
+			}
+
+			s := newTestService()
+			update := userTestUpdate
+			update.Status = tt.to
+			err := s.Update(&update, true)
+
+			if tt.status == 0 {
+				assert.Nil(t, err, "%s to %s", tt.from, tt.to)
+				continue
+			}
+			if assert.NotNil(t, err, "%s to %s", tt.from, tt.to) {
+				assert.EqualValues(t, tt.status, err.Status(), "%s to %s", tt.from, tt.to)
+			}
+		}
+	})
 }
 
 func TestValidate(t *testing.T) {
@@ -324,3 +708,123 @@ func TestValidate(t *testing.T) {
 		assert.EqualValues(t, "invalid last name", err.Message())
 	})
 }
+
+func TestVerifyPassword(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Password = ""
+			return &user, nil
+		}
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			return &userTest, nil
+		}
+
+		s := newTestService()
+		err := s.VerifyPassword(1, "password")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			return &userTest, nil
+		}
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			return &userTest, nil
+		}
+
+		s := newTestService()
+		err := s.VerifyPassword(1, "wrong")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+	})
+
+	t.Run("LocksAccount", func(t *testing.T) {
+		s := newTestService()
+
+		assertLocksAccount(t, func(password string) rest_errors.RestErr {
+			return s.VerifyPassword(1, password)
+		})
+	})
+}
+
+// assertLocksAccount checks that wrong passwords sent to try count as failed
+// logins of the account of userTest, which then stays locked even for the
+// right password.
+func assertLocksAccount(t *testing.T, try func(password string) rest_errors.RestErr) {
+	funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	attemptsTest.clear()
+	defer attemptsTest.clear()
+
+	for i := 1; i < throttleTest.MaxAccountFailures; i++ {
+		if err := try("wrong"); assert.NotNil(t, err) {
+			assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+		}
+	}
+	if err := try("wrong"); assert.NotNil(t, err) {
+		assert.EqualValues(t, http.StatusLocked, err.Status())
+	}
+	if err := try("password"); assert.NotNil(t, err) {
+		assert.EqualValues(t, http.StatusLocked, err.Status())
+	}
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		var deletedAt string
+		funcDelete = func(i int64, d string) rest_errors.RestErr {
+			deletedAt = d
+			return nil
+		}
+
+		sessionsMock.revoked = nil
+		s := newTestService()
+		err := s.Delete(1)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, deletedAt)
+		assert.EqualValues(t, []int64{userTest.Id}, sessionsMock.revoked)
+	})
+
+	t.Run("AlreadyDeleted", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Status = "deleted"
+			return &user, nil
+		}
+		funcDelete = func(i int64, d string) rest_errors.RestErr {
+			t.Fatal("the deletion date of a deleted user must be kept")
+			return nil
+		}
+
+		s := newTestService()
+		err := s.Delete(1)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		funcGet = func(i int64) (*domain.User, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+
+		s := newTestService()
+		err := s.Delete(1)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	// userTokenSize is the number of random bytes of a token sent by email.
+	userTokenSize = 32
+	// tokenCooldown is the minimum time between two emails with a token of
+	// the same purpose for a user.
+	tokenCooldown = time.Minute
+)
+
+// hashUserToken returns the hash a token is stored and looked up by.
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueToken stores a new token of the user for purpose, valid for ttl, and
+// returns it. Only its hash is kept, the returned value must be sent to the
+// user right away.
+func (s *usersService) issueToken(userId int64, purpose string, ttl time.Duration) (string, rest_errors.RestErr) {
+	return s.issuePayloadToken(userId, purpose, "", ttl)
+}
+
+// issuePayloadToken is issueToken for a token that carries data to apply
+// when it's redeemed.
+func (s *usersService) issuePayloadToken(userId int64, purpose, payload string, ttl time.Duration) (string, rest_errors.RestErr) {
+	b := make([]byte, userTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		s.log.Error("error while generating user token", err)
+		return "", rest_errors.NewInternalServerError("error while trying to generate token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now().UTC()
+	if err := s.tokens.Save(&domain.UserToken{
+		UserID:      userId,
+		Purpose:     purpose,
+		TokenHash:   hashUserToken(token),
+		Payload:     payload,
+		ExpiresAt:   now.Add(ttl).Format(dateLayout),
+		DateCreated: now.Format(dateLayout),
+	}); err != nil {
+		return "", rest_errors.NewInternalServerError("error while trying to generate token")
+	}
+	return token, nil
+}
+
+// reissueToken invalidates the unused tokens of the user for purpose and
+// issues a new one. It returns an empty token, and no error, when the last
+// one was issued less than tokenCooldown ago, so the same inbox can't be
+// flooded.
+func (s *usersService) reissueToken(userId int64, purpose string, ttl time.Duration) (string, rest_errors.RestErr) {
+	latest, err := s.tokens.GetLatest(userId, purpose)
+	if err != nil && err.Status() != http.StatusNotFound {
+		return "", rest_errors.NewInternalServerError("error while trying to generate token")
+	}
+	if latest != nil {
+		if issuedAt, parseErr := time.Parse(dateLayout, latest.DateCreated); parseErr == nil && time.Since(issuedAt) < tokenCooldown {
+			return "", nil
+		}
+	}
+
+	if err := s.tokens.Invalidate(userId, purpose, time.Now().UTC().Format(dateLayout)); err != nil {
+		return "", rest_errors.NewInternalServerError("error while trying to generate token")
+	}
+	return s.issueToken(userId, purpose, ttl)
+}
+
+// consumeToken resolves a token sent to a user and marks it as used, so it
+// can't be redeemed twice. Unknown, used and expired tokens are all reported
+// the same way.
+func (s *usersService) consumeToken(purpose, token string) (*domain.UserToken, rest_errors.RestErr) {
+	t, err := s.lookupToken(purpose, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.claimToken(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// lookupToken resolves a token sent to a user without using it, for flows
+// that validate the rest of the request first.
+func (s *usersService) lookupToken(purpose, token string) (*domain.UserToken, rest_errors.RestErr) {
+	if token == "" {
+		return nil, errInvalidToken()
+	}
+
+	t, err := s.tokens.GetByHash(purpose, hashUserToken(token))
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, errInvalidToken()
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to check token, try again later")
+	}
+
+	expiresAt, parseErr := time.Parse(dateLayout, t.ExpiresAt)
+	if parseErr != nil {
+		s.log.Error("invalid user token expiry", parseErr)
+		return nil, rest_errors.NewInternalServerError("error while trying to check token, try again later")
+	}
+	if t.UsedAt != "" || !time.Now().UTC().Before(expiresAt) {
+		return nil, errInvalidToken()
+	}
+	return t, nil
+}
+
+// claimToken marks a token returned by lookupToken as used.
+func (s *usersService) claimToken(t *domain.UserToken) rest_errors.RestErr {
+	claimed, err := s.tokens.MarkUsed(t.Id, time.Now().UTC().Format(dateLayout))
+	if err != nil {
+		return rest_errors.NewInternalServerError("error while trying to check token, try again later")
+	}
+	if !claimed {
+		// redeemed by a concurrent request
+		return errInvalidToken()
+	}
+	return nil
+}
+
+func errInvalidToken() rest_errors.RestErr {
+	return rest_errors.NewBadRequestError("invalid or expired token")
+}
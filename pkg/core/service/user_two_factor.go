@@ -0,0 +1,380 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	// twoFactorChallengeTTL is how long a user has to send their code after
+	// logging in with their password.
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+	// recoveryCodeSize is the number of random bytes of a recovery code,
+	// shown as 10 base32 characters split in two groups.
+	recoveryCodeSize = 7
+)
+
+// TwoFactorSettings configures two-factor authentication. Issuer names the
+// service in authenticator apps. RequiredForAdmins makes every admin account
+// use it, on top of the accounts admins require it for.
+type TwoFactorSettings struct {
+	Issuer            string
+	RequiredForAdmins bool
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func errTwoFactorEnabled() rest_errors.RestErr {
+	return rest_errors.NewRestError("two-factor authentication is already enabled", http.StatusConflict, "conflict")
+}
+
+func errTwoFactorUnavailable() rest_errors.RestErr {
+	return rest_errors.NewInternalServerError("error while trying to check two-factor authentication, try again later")
+}
+
+// getTwoFactor returns the two-factor settings of a user, a zero value for
+// users that never had any.
+func (s *usersService) getTwoFactor(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+	tf, err := s.twoFactor.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return &domain.TwoFactor{UserID: userId}, nil
+		}
+		return nil, errTwoFactorUnavailable()
+	}
+	return tf, nil
+}
+
+func (s *usersService) twoFactorRequired(user *domain.User, tf *domain.TwoFactor) bool {
+	return tf.Required || (s.twoFactorSettings.RequiredForAdmins && user.Role == "admin")
+}
+
+// loginChallenge returns the challenge a user that logged in with their
+// password has to complete, or nil when the password is enough.
+func (s *usersService) loginChallenge(user *domain.User) (*domain.LoginChallenge, rest_errors.RestErr) {
+	tf, err := s.getTwoFactor(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !tf.Enabled() && !s.twoFactorRequired(user, tf) {
+		return nil, nil
+	}
+
+	token, err := s.issueToken(user.Id, domain.TokenPurposeTwoFactorLogin, twoFactorChallengeTTL)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+	}
+	return &domain.LoginChallenge{
+		Challenge: token,
+		ExpiresAt: time.Now().UTC().Add(twoFactorChallengeTTL).Format(dateLayout),
+		Enroll:    !tf.Enabled(),
+	}, nil
+}
+
+// challengeUser resolves a login challenge, without using it, to its user.
+func (s *usersService) challengeUser(challenge string) (*domain.UserToken, *domain.User, rest_errors.RestErr) {
+	t, err := s.lookupToken(domain.TokenPurposeTwoFactorLogin, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.repo.Get(t.UserID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, nil, errInvalidToken()
+		}
+		return nil, nil, rest_errors.NewInternalServerError("error while trying to login, try again later")
+	}
+	return t, user, nil
+}
+
+func (s *usersService) VerifyLogin(challenge, code string, client domain.ClientInfo) (*domain.User, rest_errors.RestErr) {
+	t, user, err := s.challengeUser(challenge)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := s.getTwoFactor(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !tf.Enabled() {
+		return nil, rest_errors.NewBadRequestError("two-factor authentication is not set up, enroll first")
+	}
+
+	if err := s.checkCode(user.Id, func(now time.Time) (bool, rest_errors.RestErr) {
+		return s.useCode(tf, code, now)
+	}); err != nil {
+		s.recordFailedCode(user.Id, client, err)
+		return nil, err
+	}
+	if err := s.claimToken(t); err != nil {
+		return nil, err
+	}
+	return s.completeLogin(user, client)
+}
+
+func (s *usersService) EnrollWithChallenge(challenge string) (*domain.TwoFactorSecret, rest_errors.RestErr) {
+	_, user, err := s.challengeUser(challenge)
+	if err != nil {
+		return nil, err
+	}
+	return s.startEnrollment(user)
+}
+
+func (s *usersService) ConfirmWithChallenge(challenge, code string, client domain.ClientInfo) (*domain.TwoFactorEnrollment, rest_errors.RestErr) {
+	t, user, err := s.challengeUser(challenge)
+	if err != nil {
+		return nil, err
+	}
+	enrollment, err := s.confirmEnrollment(user, code)
+	if err != nil {
+		s.recordFailedCode(user.Id, client, err)
+		return nil, err
+	}
+	if err := s.claimToken(t); err != nil {
+		return nil, err
+	}
+
+	enrollment.User, err = s.completeLogin(user, client)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (s *usersService) EnrollTwoFactor(userId int64) (*domain.TwoFactorSecret, rest_errors.RestErr) {
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, errTwoFactorUnavailable()
+		}
+		return nil, err
+	}
+	return s.startEnrollment(user)
+}
+
+func (s *usersService) ConfirmTwoFactor(userId int64, code string) (*domain.TwoFactorEnrollment, rest_errors.RestErr) {
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return nil, errTwoFactorUnavailable()
+		}
+		return nil, err
+	}
+	return s.confirmEnrollment(user, code)
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking the
+// password of the user. Accounts that require it can't turn it off.
+func (s *usersService) DisableTwoFactor(userId int64, password string) rest_errors.RestErr {
+	user, err := s.checkPassword(userId, password)
+	if err != nil {
+		return err
+	}
+	tf, err := s.getTwoFactor(userId)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled() {
+		return rest_errors.NewRestError("two-factor authentication is not enabled", http.StatusConflict, "conflict")
+	}
+	if s.twoFactorRequired(user, tf) {
+		return rest_errors.NewRestError("two-factor authentication is required for this account", http.StatusConflict, "conflict")
+	}
+
+	if err := s.twoFactor.Disable(userId); err != nil {
+		return errTwoFactorUnavailable()
+	}
+	s.publishSecurityEvent(user, domain.SecurityEventTwoFactorDisabled)
+	return nil
+}
+
+// ResetTwoFactor is used by admins to help users that lost both their
+// authenticator and their recovery codes. Their sessions are ended and
+// their access tokens revoked, and if
+// the account requires two-factor authentication they enroll again on their
+// next login.
+func (s *usersService) ResetTwoFactor(userId int64) rest_errors.RestErr {
+	user, err := s.repo.Get(userId)
+	if err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return errTwoFactorUnavailable()
+		}
+		return err
+	}
+
+	revocation, err := s.requestSessionRevocation(userId)
+	if err != nil {
+		return errTwoFactorUnavailable()
+	}
+	if err := s.twoFactor.Disable(userId); err != nil {
+		return errTwoFactorUnavailable()
+	}
+	s.revokeSessions(revocation)
+	s.revokeAccessTokens(userId)
+	s.publishSecurityEvent(user, domain.SecurityEventTwoFactorReset)
+	return nil
+}
+
+func (s *usersService) SetTwoFactorRequired(userId int64, required bool) rest_errors.RestErr {
+	if _, err := s.repo.Get(userId); err != nil {
+		if err.Status() == http.StatusInternalServerError {
+			return errTwoFactorUnavailable()
+		}
+		return err
+	}
+
+	if err := s.twoFactor.SetRequired(userId, required); err != nil {
+		return errTwoFactorUnavailable()
+	}
+	return nil
+}
+
+// startEnrollment generates a new secret for user, replacing any enrollment
+// that wasn't confirmed.
+func (s *usersService) startEnrollment(user *domain.User) (*domain.TwoFactorSecret, rest_errors.RestErr) {
+	tf, err := s.getTwoFactor(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled() {
+		return nil, errTwoFactorEnabled()
+	}
+
+	secret, genErr := generateTOTPSecret()
+	if genErr != nil {
+		s.log.Error("error while generating two-factor secret", genErr)
+		return nil, errTwoFactorUnavailable()
+	}
+	if err := s.twoFactor.SaveSecret(user.Id, secret); err != nil {
+		return nil, errTwoFactorUnavailable()
+	}
+	return &domain.TwoFactorSecret{
+		Secret:     secret,
+		OtpauthURI: otpauthURI(s.twoFactorSettings.Issuer, user.Email, secret),
+	}, nil
+}
+
+// confirmEnrollment enables two-factor authentication for user once they
+// send a first code of the pending secret, and returns their recovery codes.
+func (s *usersService) confirmEnrollment(user *domain.User, code string) (*domain.TwoFactorEnrollment, rest_errors.RestErr) {
+	tf, err := s.getTwoFactor(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled() {
+		return nil, errTwoFactorEnabled()
+	}
+	if tf.Secret == "" {
+		return nil, rest_errors.NewBadRequestError("two-factor enrollment not started")
+	}
+
+	var step int64
+	if err := s.checkCode(user.Id, func(now time.Time) (bool, rest_errors.RestErr) {
+		var ok bool
+		step, ok = matchTOTP(tf.Secret, strings.TrimSpace(code), now, 0)
+		return ok, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, genErr := generateRecoveryCodes()
+	if genErr != nil {
+		s.log.Error("error while generating recovery codes", genErr)
+		return nil, errTwoFactorUnavailable()
+	}
+	if err := s.twoFactor.Enable(user.Id, time.Now().UTC().Format(dateLayout), step, hashes); err != nil {
+		if err.Status() == http.StatusNotFound {
+			// disabled by a concurrent request
+			return nil, rest_errors.NewBadRequestError("two-factor enrollment not started")
+		}
+		return nil, errTwoFactorUnavailable()
+	}
+	s.publishSecurityEvent(user, domain.SecurityEventTwoFactorEnabled)
+	return &domain.TwoFactorEnrollment{RecoveryCodes: codes}, nil
+}
+
+// useCode accepts a code of the authenticator app or an unused recovery code
+// of the user, marking it as used.
+func (s *usersService) useCode(tf *domain.TwoFactor, code string, now time.Time) (bool, rest_errors.RestErr) {
+	code = strings.TrimSpace(code)
+	if _, err := strconv.Atoi(code); err == nil && len(code) == totpDigits {
+		step, ok := matchTOTP(tf.Secret, code, now, tf.LastUsedStep)
+		if !ok {
+			return false, nil
+		}
+		// a concurrent request may have used this step or a later one
+		used, err := s.twoFactor.UseStep(tf.UserID, step)
+		if err != nil {
+			return false, errTwoFactorUnavailable()
+		}
+		return used, nil
+	}
+
+	used, err := s.twoFactor.UseRecoveryCode(tf.UserID, hashUserToken(normalizeRecoveryCode(code)), now.Format(dateLayout))
+	if err != nil {
+		return false, errTwoFactorUnavailable()
+	}
+	return used, nil
+}
+
+// checkCode runs check, counting its failures like failed logins. Once a
+// user fails too often the pending login challenges are dropped, so the
+// password has to be sent again, and codes are rejected for a while.
+func (s *usersService) checkCode(userId int64, check func(now time.Time) (bool, rest_errors.RestErr)) rest_errors.RestErr {
+	now := time.Now().UTC()
+	key := "two_factor:" + strconv.FormatInt(userId, 10)
+
+	attempts, err := s.attempts.Get(key)
+	if err != nil {
+		return errTwoFactorUnavailable()
+	}
+	if attempts.LockedUntil.After(now) {
+		return errTooManyAttempts(attempts.LockedUntil.Sub(now))
+	}
+
+	ok, err := check(now)
+	if err != nil {
+		return err
+	}
+	if ok {
+		s.attempts.Reset(key)
+		return nil
+	}
+
+	if attempts, err := s.attempts.Fail(key, now, s.throttle.Window); err == nil && attempts.Failures >= s.throttle.MaxAccountFailures {
+		s.attempts.Lock(key, now.Add(s.throttle.Lockout))
+		s.tokens.Invalidate(userId, domain.TokenPurposeTwoFactorLogin, now.Format(dateLayout))
+	}
+	return rest_errors.NewBadRequestError("invalid code")
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted to be shown to
+// the user, and the hashes they are stored by.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	b := make([]byte, recoveryCodeSize)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashUserToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode undoes the formatting of a recovery code, and the
+// changes users make when typing it.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
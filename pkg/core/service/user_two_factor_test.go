@@ -0,0 +1,417 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type twoFactorRepoMock struct{}
+
+var (
+	funcGetTwoFactor         func(int64) (*domain.TwoFactor, rest_errors.RestErr)
+	funcSaveTwoFactorSecret  func(int64, string) rest_errors.RestErr
+	funcEnableTwoFactor      func(int64, string, int64, []string) rest_errors.RestErr
+	funcUseTwoFactorStep     func(int64, int64) (bool, rest_errors.RestErr)
+	funcUseRecoveryCode      func(int64, string, string) (bool, rest_errors.RestErr)
+	funcDisableTwoFactor     func(int64) rest_errors.RestErr
+	funcSetTwoFactorRequired func(int64, bool) rest_errors.RestErr
+	noTwoFactor              = func(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+		return nil, rest_errors.NewNotFoundError("two-factor authentication not found")
+	}
+)
+
+func (m *twoFactorRepoMock) Get(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+	if funcGetTwoFactor == nil {
+		return noTwoFactor(userId)
+	}
+	return funcGetTwoFactor(userId)
+}
+func (m *twoFactorRepoMock) SaveSecret(userId int64, secret string) rest_errors.RestErr {
+	return funcSaveTwoFactorSecret(userId, secret)
+}
+func (m *twoFactorRepoMock) Enable(userId int64, enabledAt string, step int64, hashes []string) rest_errors.RestErr {
+	return funcEnableTwoFactor(userId, enabledAt, step, hashes)
+}
+func (m *twoFactorRepoMock) UseStep(userId int64, step int64) (bool, rest_errors.RestErr) {
+	return funcUseTwoFactorStep(userId, step)
+}
+func (m *twoFactorRepoMock) UseRecoveryCode(userId int64, hash, usedAt string) (bool, rest_errors.RestErr) {
+	return funcUseRecoveryCode(userId, hash, usedAt)
+}
+func (m *twoFactorRepoMock) Disable(userId int64) rest_errors.RestErr {
+	return funcDisableTwoFactor(userId)
+}
+func (m *twoFactorRepoMock) SetRequired(userId int64, required bool) rest_errors.RestErr {
+	return funcSetTwoFactorRequired(userId, required)
+}
+
+var (
+	twoFactorRepo ports.TwoFactorRepository = &twoFactorRepoMock{}
+	twoFactorTest                           = TwoFactorSettings{Issuer: "Bookstore", RequiredForAdmins: true}
+)
+
+// RFC 6238 test secret, "12345678901234567890" in base32
+const totpTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func enabledTwoFactor(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+	return &domain.TwoFactor{UserID: userId, Secret: totpTestSecret, EnabledAt: "2026-10-17 12:00:00"}, nil
+}
+
+func currentCode(t *testing.T) string {
+	code, err := totpCode(totpTestSecret, totpStep(time.Now()))
+	assert.Nil(t, err)
+	return code
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range tests {
+		code, err := totpCode(totpTestSecret, totpStep(time.Unix(unix, 0)))
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, expected, code)
+	}
+
+	now := time.Unix(1111111109, 0)
+	step, ok := matchTOTP(totpTestSecret, "081804", now, 0)
+	assert.True(t, ok)
+	assert.EqualValues(t, totpStep(now), step)
+
+	_, ok = matchTOTP(totpTestSecret, "081804", now, step)
+	assert.False(t, ok, "replayed code")
+
+	uri := otpauthURI("Bookstore", "oscaac@gmail.com", totpTestSecret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Bookstore:oscaac@gmail.com?"))
+	assert.Contains(t, uri, "secret="+totpTestSecret)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+
+	assert.Nil(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	assert.Len(t, hashes, recoveryCodeCount)
+	assert.Len(t, codes[0], 11)
+	assert.EqualValues(t, hashes[0], hashUserToken(normalizeRecoveryCode(strings.ToUpper(codes[0]))))
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	funcSaveToken = func(t *domain.UserToken) rest_errors.RestErr {
+		return nil
+	}
+	defer func() {
+		funcGetTwoFactor = nil
+	}()
+
+	t.Run("Challenge", func(t *testing.T) {
+		attemptsTest.clear()
+		funcGetTwoFactor = enabledTwoFactor
+		s := newTestService()
+
+		user, challenge, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.Nil(t, user)
+		if assert.NotNil(t, challenge) {
+			assert.NotEmpty(t, challenge.Challenge)
+			assert.False(t, challenge.Enroll)
+		}
+	})
+
+	t.Run("RequiredForAdmins", func(t *testing.T) {
+		attemptsTest.clear()
+		funcGetTwoFactor = nil
+		funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			user.Role = "admin"
+			return &user, nil
+		}
+		s := newTestService()
+
+		user, challenge, err := s.Login("oscaac@gmail.com", "password", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.Nil(t, user)
+		if assert.NotNil(t, challenge) {
+			assert.True(t, challenge.Enroll)
+		}
+	})
+}
+
+func TestVerifyLogin(t *testing.T) {
+	funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+		token := validToken(hash)
+		token.Purpose = purpose
+		return token, nil
+	}
+	funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+		return true, nil
+	}
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	funcGetTwoFactor = enabledTwoFactor
+	defer func() {
+		funcGetTwoFactor = nil
+	}()
+
+	t.Run("NoError", func(t *testing.T) {
+		attemptsTest.clear()
+		funcUseTwoFactorStep = func(userId, step int64) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		s := newTestService()
+
+		user, err := s.VerifyLogin("challenge", currentCode(t), domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, userTest.Id, user.Id)
+	})
+
+	t.Run("RecoveryCode", func(t *testing.T) {
+		attemptsTest.clear()
+		var hash string
+		funcUseRecoveryCode = func(userId int64, h, usedAt string) (bool, rest_errors.RestErr) {
+			hash = h
+			return true, nil
+		}
+		s := newTestService()
+
+		user, err := s.VerifyLogin("challenge", "ABCDE-fghij", domain.ClientInfo{})
+
+		assert.Nil(t, err)
+		assert.NotNil(t, user)
+		assert.EqualValues(t, hashUserToken("abcdefghij"), hash)
+	})
+
+	t.Run("ReplayedCode", func(t *testing.T) {
+		attemptsTest.clear()
+		funcUseTwoFactorStep = func(userId, step int64) (bool, rest_errors.RestErr) {
+			return false, nil
+		}
+		s := newTestService()
+
+		user, err := s.VerifyLogin("challenge", currentCode(t), domain.ClientInfo{})
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid code", err.Message())
+	})
+
+	t.Run("TooManyWrongCodes", func(t *testing.T) {
+		attemptsTest.clear()
+		funcUseRecoveryCode = func(userId int64, h, usedAt string) (bool, rest_errors.RestErr) {
+			return false, nil
+		}
+		var invalidated string
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			invalidated = purpose
+			return nil
+		}
+		s := newTestService()
+
+		for i := 0; i < throttleTest.MaxAccountFailures; i++ {
+			_, err := s.VerifyLogin("challenge", "wrong-code", domain.ClientInfo{})
+			assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		}
+		_, err := s.VerifyLogin("challenge", currentCode(t), domain.ClientInfo{})
+
+		assert.EqualValues(t, domain.TokenPurposeTwoFactorLogin, invalidated)
+		assert.EqualValues(t, http.StatusTooManyRequests, err.Status())
+	})
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		funcGetTwoFactor = nil
+		defer func() {
+			funcGetTwoFactor = enabledTwoFactor
+		}()
+		s := newTestService()
+
+		user, err := s.VerifyLogin("challenge", "123456", domain.ClientInfo{})
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+	})
+}
+
+func TestEnrollTwoFactor(t *testing.T) {
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	defer func() {
+		funcGetTwoFactor = nil
+	}()
+
+	t.Run("NoError", func(t *testing.T) {
+		funcGetTwoFactor = nil
+		var saved string
+		funcSaveTwoFactorSecret = func(userId int64, secret string) rest_errors.RestErr {
+			saved = secret
+			return nil
+		}
+		s := newTestService()
+
+		secret, err := s.EnrollTwoFactor(userTest.Id)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, saved, secret.Secret)
+		assert.Len(t, secret.Secret, 32)
+		assert.Contains(t, secret.OtpauthURI, "issuer=Bookstore")
+	})
+
+	t.Run("AlreadyEnabled", func(t *testing.T) {
+		funcGetTwoFactor = enabledTwoFactor
+		s := newTestService()
+
+		secret, err := s.EnrollTwoFactor(userTest.Id)
+
+		assert.Nil(t, secret)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	funcGetTwoFactor = func(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+		return &domain.TwoFactor{UserID: userId, Secret: totpTestSecret}, nil
+	}
+	defer func() {
+		funcGetTwoFactor = nil
+	}()
+
+	t.Run("NoError", func(t *testing.T) {
+		attemptsTest.clear()
+		var stored []string
+		funcEnableTwoFactor = func(userId int64, enabledAt string, step int64, hashes []string) rest_errors.RestErr {
+			stored = hashes
+			return nil
+		}
+		s := newTestService()
+
+		enrollment, err := s.ConfirmTwoFactor(userTest.Id, currentCode(t))
+
+		assert.Nil(t, err)
+		assert.Len(t, enrollment.RecoveryCodes, recoveryCodeCount)
+		assert.Len(t, stored, recoveryCodeCount)
+		assert.NotContains(t, stored, enrollment.RecoveryCodes[0])
+		assert.Nil(t, enrollment.User)
+	})
+
+	t.Run("WrongCode", func(t *testing.T) {
+		attemptsTest.clear()
+		s := newTestService()
+
+		enrollment, err := s.ConfirmTwoFactor(userTest.Id, "abcdef")
+
+		assert.Nil(t, enrollment)
+		assert.EqualValues(t, "invalid code", err.Message())
+	})
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	// other tests change userTest through pointers to it
+	withPassword := func() *domain.User {
+		user := userTest
+		user.Status = domain.StatusActive
+		user.Password = "$2a$10$jRL.gYiodDnwcOBErnDfuu5044h40PM3ZOAOzit6O4RIL9wG24xJ6" //password
+		return &user
+	}
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		return withPassword(), nil
+	}
+	funcGetByEmail = func(s string) (*domain.User, rest_errors.RestErr) {
+		return withPassword(), nil
+	}
+	defer func() {
+		funcGetTwoFactor = nil
+	}()
+
+	t.Run("NoError", func(t *testing.T) {
+		funcGetTwoFactor = enabledTwoFactor
+		var disabled int64
+		funcDisableTwoFactor = func(userId int64) rest_errors.RestErr {
+			disabled = userId
+			return nil
+		}
+		s := newTestService()
+
+		err := s.DisableTwoFactor(userTest.Id, "password")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, userTest.Id, disabled)
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		funcGetTwoFactor = func(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+			tf, _ := enabledTwoFactor(userId)
+			tf.Required = true
+			return tf, nil
+		}
+		s := newTestService()
+
+		err := s.DisableTwoFactor(userTest.Id, "password")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		funcGetTwoFactor = enabledTwoFactor
+		s := newTestService()
+
+		err := s.DisableTwoFactor(userTest.Id, "notthepassword")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+	})
+
+	t.Run("LocksAccount", func(t *testing.T) {
+		funcGetTwoFactor = enabledTwoFactor
+		s := newTestService()
+
+		assertLocksAccount(t, func(password string) rest_errors.RestErr {
+			return s.DisableTwoFactor(userTest.Id, password)
+		})
+	})
+}
+
+func TestResetTwoFactor(t *testing.T) {
+	funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+		user := userTest
+		return &user, nil
+	}
+	funcDisableTwoFactor = func(userId int64) rest_errors.RestErr {
+		return nil
+	}
+	sessionsMock.revoked = nil
+	accessTokensTest.tokens = []domain.AccessToken{{Id: 1, UserID: userTest.Id}}
+	s := newTestService()
+
+	err := s.ResetTwoFactor(userTest.Id)
+
+	assert.Nil(t, err)
+	assert.Contains(t, sessionsMock.revoked, userTest.Id)
+	assert.NotEmpty(t, accessTokensTest.tokens[0].RevokedAt)
+}
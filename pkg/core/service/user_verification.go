@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// verificationTTL is how long a verification link stays valid.
+const verificationTTL = 24 * time.Hour
+
+// VerifyEmail redeems a verification token and activates the account it was
+// sent to.
+func (s *usersService) VerifyEmail(token string) (*domain.User, rest_errors.RestErr) {
+	t, err := s.consumeToken(domain.TokenPurposeEmailVerification, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.Get(t.UserID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, errInvalidToken()
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to verify email, try again later")
+	}
+	if user.Status != domain.StatusPendingVerification {
+		return nil, rest_errors.NewRestError("email address already verified", http.StatusConflict, "conflict")
+	}
+
+	if err := s.repo.SetStatus(user.Id, user.Status, domain.StatusActive, time.Now().UTC().Format(dateLayout)); err != nil {
+		if err.Status() == http.StatusNotFound {
+			// changed by a concurrent request
+			return nil, rest_errors.NewRestError("email address already verified", http.StatusConflict, "conflict")
+		}
+		return nil, rest_errors.NewInternalServerError("error while trying to verify email, try again later")
+	}
+	s.publishStatusChange(user, domain.StatusActive)
+	return user, nil
+}
+
+// ResendVerification sends a new verification email to a user that hasn't
+// verified their address yet, invalidating the previous links. Unknown and
+// already verified addresses are silently ignored, and the email is sent in
+// the background, so callers can't tell which emails are registered.
+func (s *usersService) ResendVerification(email string) rest_errors.RestErr {
+	user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil
+		}
+		return rest_errors.NewInternalServerError("error while trying to send verification email, try again later")
+	}
+	if user.Status != domain.StatusPendingVerification {
+		return nil
+	}
+
+	s.inBackground(func() {
+		token, err := s.reissueToken(user.Id, domain.TokenPurposeEmailVerification, verificationTTL)
+		if err != nil {
+			s.log.Error("error while issuing verification token", err)
+			return
+		}
+		if token != "" {
+			s.sendVerification(user, token)
+		}
+	})
+	return nil
+}
+
+// sendVerification emails user a link to verify their address. Failures are
+// only logged, the user can ask for another email.
+func (s *usersService) sendVerification(user *domain.User, token string) {
+	if err := s.mailer.Send(domain.Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %d hours. If you didn't create an account, you can ignore this email.\n",
+			user.FirstName, s.link("/verify", token), int(verificationTTL.Hours())),
+	}); err != nil {
+		s.log.Error("error while sending verification email", err)
+	}
+}
+
+// inBackground runs work off the request path. It's meant for the requests
+// that must be answered the same whether an account exists or not, neither
+// how long the work takes nor whether it fails may show in the response, so
+// work logs its own failures.
+func (s *usersService) inBackground(work func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		work()
+	}()
+}
+
+func (s *usersService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// link returns the frontend URL at path that redeems token.
+func (s *usersService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type userTokenRepoMock struct{}
+
+var (
+	funcSaveToken       func(*domain.UserToken) rest_errors.RestErr
+	funcGetTokenByHash  func(string, string) (*domain.UserToken, rest_errors.RestErr)
+	funcGetLatestToken  func(int64, string) (*domain.UserToken, rest_errors.RestErr)
+	funcMarkTokenUsed   func(int64, string) (bool, rest_errors.RestErr)
+	funcInvalidateToken func(int64, string, string) rest_errors.RestErr
+)
+
+func (m *userTokenRepoMock) Save(t *domain.UserToken) rest_errors.RestErr {
+	return funcSaveToken(t)
+}
+func (m *userTokenRepoMock) GetByHash(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+	return funcGetTokenByHash(purpose, hash)
+}
+func (m *userTokenRepoMock) GetLatest(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+	return funcGetLatestToken(userId, purpose)
+}
+func (m *userTokenRepoMock) MarkUsed(id int64, usedAt string) (bool, rest_errors.RestErr) {
+	return funcMarkTokenUsed(id, usedAt)
+}
+func (m *userTokenRepoMock) Invalidate(userId int64, purpose, usedAt string) rest_errors.RestErr {
+	return funcInvalidateToken(userId, purpose, usedAt)
+}
+
+// mailerMock keeps the emails instead of sending them
+type mailerMock struct {
+	sent []domain.Email
+	err  error
+}
+
+func (m *mailerMock) Send(e domain.Email) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, e)
+	return nil
+}
+
+var (
+	tokenRepo ports.UserTokenRepository = &userTokenRepoMock{}
+	outbox                              = &mailerMock{}
+)
+
+func validToken(hash string) *domain.UserToken {
+	now := time.Now().UTC()
+	return &domain.UserToken{
+		Id:          7,
+		UserID:      1,
+		Purpose:     domain.TokenPurposeEmailVerification,
+		TokenHash:   hash,
+		ExpiresAt:   now.Add(time.Hour).Format(dateLayout),
+		DateCreated: now.Format(dateLayout),
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	pendingUser := func() *domain.User {
+		user := userTest
+		user.Status = domain.StatusPendingVerification
+		return &user
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			assert.EqualValues(t, hashUserToken("abc"), hash)
+			return validToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			return pendingUser(), nil
+		}
+		var from, to string
+		funcSetStatus = func(id int64, f, tt, m string) rest_errors.RestErr {
+			from, to = f, tt
+			return nil
+		}
+
+		s := newTestService()
+		user, err := s.VerifyEmail("abc")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, domain.StatusActive, user.Status)
+		assert.EqualValues(t, domain.StatusPendingVerification, from)
+		assert.EqualValues(t, domain.StatusActive, to)
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+
+		s := newTestService()
+		user, err := s.VerifyEmail("abc")
+
+		assert.Nil(t, user)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid or expired token", err.Message())
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			token := validToken(hash)
+			token.ExpiresAt = time.Now().UTC().Add(-time.Minute).Format(dateLayout)
+			return token, nil
+		}
+
+		s := newTestService()
+		_, err := s.VerifyEmail("abc")
+
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid or expired token", err.Message())
+	})
+
+	t.Run("AlreadyUsed", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return validToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return false, nil
+		}
+
+		s := newTestService()
+		_, err := s.VerifyEmail("abc")
+
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.EqualValues(t, "invalid or expired token", err.Message())
+	})
+
+	t.Run("AlreadyVerified", func(t *testing.T) {
+		funcGetTokenByHash = func(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+			return validToken(hash), nil
+		}
+		funcMarkTokenUsed = func(id int64, usedAt string) (bool, rest_errors.RestErr) {
+			return true, nil
+		}
+		funcGet = func(id int64) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+
+		s := newTestService()
+		_, err := s.VerifyEmail("abc")
+
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+}
+
+func TestResendVerification(t *testing.T) {
+	pendingUser := func() *domain.User {
+		user := userTest
+		user.Status = domain.StatusPendingVerification
+		return &user
+	}
+
+	t.Run("NoError", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			return pendingUser(), nil
+		}
+		funcGetLatestToken = func(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+			token := validToken("old")
+			token.DateCreated = time.Now().UTC().Add(-time.Hour).Format(dateLayout)
+			return token, nil
+		}
+		invalidated := false
+		funcInvalidateToken = func(userId int64, purpose, usedAt string) rest_errors.RestErr {
+			invalidated = true
+			return nil
+		}
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		err := s.ResendVerification(" Oscaac@gmail.com ")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.True(t, invalidated)
+		assert.Len(t, outbox.sent, 1)
+	})
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("user not found")
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		err := s.ResendVerification("nobody@gmail.com")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("AlreadyVerified", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			user := userTest
+			return &user, nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		err := s.ResendVerification("oscaac@gmail.com")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("Cooldown", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			return pendingUser(), nil
+		}
+		funcGetLatestToken = func(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+			return validToken("recent"), nil
+		}
+		outbox.sent = nil
+
+		s := newTestService()
+		err := s.ResendVerification("oscaac@gmail.com")
+		waitInBackground(s)
+
+		assert.Nil(t, err)
+		assert.Len(t, outbox.sent, 0)
+	})
+
+	t.Run("MailerError", func(t *testing.T) {
+		funcGetByEmail = func(e string) (*domain.User, rest_errors.RestErr) {
+			return pendingUser(), nil
+		}
+		funcGetLatestToken = func(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+		funcSaveToken = func(tk *domain.UserToken) rest_errors.RestErr {
+			return nil
+		}
+		outbox.err = errors.New("connection refused")
+		defer func() { outbox.err = nil }()
+		serviceLogger.errors = nil
+
+		s := newTestService()
+		err := s.ResendVerification("oscaac@gmail.com")
+		waitInBackground(s)
+
+		// answered as for an unknown email
+		assert.Nil(t, err)
+		assert.Contains(t, serviceLogger.errors, "error while sending verification email")
+	})
+}
+
+func TestWait(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		s := newTestService().(*usersService)
+		sent := make(chan struct{})
+		release := make(chan struct{})
+		s.inBackground(func() {
+			<-release
+			close(sent)
+		})
+
+		close(release)
+		err := s.Wait(context.Background())
+
+		assert.Nil(t, err)
+		select {
+		case <-sent:
+		default:
+			t.Fatal("Wait returned before the background work was done")
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		s := newTestService().(*usersService)
+		release := make(chan struct{})
+		s.inBackground(func() { <-release })
+		defer func() {
+			close(release)
+			s.background.Wait()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := s.Wait(ctx)
+
+		assert.EqualValues(t, context.DeadlineExceeded, err)
+	})
+}
@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+)
+
+type countryRule struct {
+	// postCode is matched against the normalized post code. A nil pattern
+	// means the country doesn't use post codes, so the field is optional.
+	postCode *regexp.Regexp
+	// formatPostCode rewrites an already upper-cased post code into the
+	// canonical form used by the local postal service.
+	formatPostCode func(string) string
+
+	stateRequired bool
+	// states, when set, lists the accepted subdivision codes.
+	states map[string]bool
+}
+
+// genericPostCode is used for countries without a specific rule.
+var genericPostCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,8}[A-Z0-9]$`)
+
+var countryRules = map[string]countryRule{
+	"AR": {postCode: regexp.MustCompile(`^([A-Z]\d{4}[A-Z]{3}|\d{4})$`), stateRequired: true},
+	"AT": {postCode: regexp.MustCompile(`^\d{4}$`)},
+	"AU": {postCode: regexp.MustCompile(`^\d{4}$`), stateRequired: true, states: codes("ACT", "NSW", "NT", "QLD", "SA", "TAS", "VIC", "WA")},
+	"BE": {postCode: regexp.MustCompile(`^\d{4}$`)},
+	"BR": {postCode: regexp.MustCompile(`^\d{5}-\d{3}$`), formatPostCode: splitAfter(5, "-"), stateRequired: true, states: codes(
+		"AC", "AL", "AP", "AM", "BA", "CE", "DF", "ES", "GO", "MA", "MT", "MS", "MG", "PA",
+		"PB", "PR", "PE", "PI", "RJ", "RN", "RS", "RO", "RR", "SC", "SP", "SE", "TO")},
+	"CA": {postCode: regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] \d[ABCEGHJ-NPRSTV-Z]\d$`), formatPostCode: splitAfter(3, " "), stateRequired: true, states: codes(
+		"AB", "BC", "MB", "NB", "NL", "NS", "NT", "NU", "ON", "PE", "QC", "SK", "YT")},
+	"CH": {postCode: regexp.MustCompile(`^\d{4}$`)},
+	"CN": {postCode: regexp.MustCompile(`^\d{6}$`)},
+	"DE": {postCode: regexp.MustCompile(`^\d{5}$`)},
+	"DK": {postCode: regexp.MustCompile(`^\d{4}$`)},
+	"ES": {postCode: regexp.MustCompile(`^(0[1-9]|[1-4]\d|5[0-2])\d{3}$`)},
+	"FI": {postCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postCode: regexp.MustCompile(`^\d{5}$`)},
+	"GB": {postCode: regexp.MustCompile(`^([A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}|GIR 0AA)$`), formatPostCode: splitBeforeLast(3, " ")},
+	"IE": {postCode: regexp.MustCompile(`^([AC-FHKNPRTV-Y]\d{2}|D6W) [\dAC-FHKNPRTV-Y]{4}$`), formatPostCode: splitAfter(3, " ")},
+	"IN": {postCode: regexp.MustCompile(`^[1-9]\d{5}$`), stateRequired: true},
+	"IT": {postCode: regexp.MustCompile(`^\d{5}$`)},
+	"JP": {postCode: regexp.MustCompile(`^\d{3}-\d{4}$`), formatPostCode: splitAfter(3, "-"), stateRequired: true},
+	"MX": {postCode: regexp.MustCompile(`^\d{5}$`), stateRequired: true},
+	"NL": {postCode: regexp.MustCompile(`^[1-9]\d{3} [A-Z]{2}$`), formatPostCode: splitAfter(4, " ")},
+	"NO": {postCode: regexp.MustCompile(`^\d{4}$`)},
+	"NZ": {postCode: regexp.MustCompile(`^\d{4}$`)},
+	"PL": {postCode: regexp.MustCompile(`^\d{2}-\d{3}$`), formatPostCode: splitAfter(2, "-")},
+	"PT": {postCode: regexp.MustCompile(`^\d{4}-\d{3}$`), formatPostCode: splitAfter(4, "-")},
+	"SE": {postCode: regexp.MustCompile(`^\d{3} \d{2}$`), formatPostCode: splitAfter(3, " ")},
+	"US": {postCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), stateRequired: true, states: codes(
+		"AL", "AK", "AZ", "AR", "CA", "CO", "CT", "DE", "DC", "FL", "GA", "HI", "ID", "IL", "IN", "IA", "KS",
+		"KY", "LA", "ME", "MD", "MA", "MI", "MN", "MS", "MO", "MT", "NE", "NV", "NH", "NJ", "NM", "NY", "NC",
+		"ND", "OH", "OK", "OR", "PA", "RI", "SC", "SD", "TN", "TX", "UT", "VT", "VA", "WA", "WV", "WI", "WY",
+		"AS", "GU", "MP", "PR", "VI", "AA", "AE", "AP")},
+
+	// Countries and territories that don't use post codes at all.
+	"AE": {}, "AG": {}, "AO": {}, "BF": {}, "BI": {}, "BJ": {}, "BO": {}, "BS": {}, "BW": {}, "BZ": {},
+	"CD": {}, "CF": {}, "CG": {}, "CI": {}, "CM": {}, "DJ": {}, "DM": {}, "ER": {}, "FJ": {}, "GD": {},
+	"GH": {}, "GM": {}, "GQ": {}, "GY": {}, "HK": {}, "KI": {}, "KM": {}, "KN": {}, "KP": {}, "LY": {},
+	"ML": {}, "MO": {}, "MR": {}, "MW": {}, "NR": {}, "NU": {}, "QA": {}, "RW": {}, "SB": {}, "SC": {},
+	"SL": {}, "SR": {}, "ST": {}, "SY": {}, "TD": {}, "TG": {}, "TK": {}, "TL": {}, "TO": {}, "TV": {},
+	"UG": {}, "VU": {}, "YE": {}, "ZW": {},
+}
+
+// Address normalizes the casing of the country, state and post code of a and
+// checks every field against the rules of its country. It returns one entry
+// per invalid field, or nil when the address is valid.
+func Address(a *domain.ShippingAddress) []FieldError {
+	var fields []FieldError
+	reject := func(field, message string) {
+		fields = append(fields, FieldError{Field: field, Message: message})
+	}
+
+	if _, err := mail.ParseAddress(a.EmailInvoice); err != nil {
+		reject("email_invoice", "invalid invoice email address")
+	}
+	if a.FullName == "" {
+		reject("full_name", "invalid full name")
+	}
+	if a.AddressLine1 == "" {
+		reject("address_line1", "invalid address line")
+	}
+	if a.City == "" {
+		reject("city", "invalid city")
+	}
+
+	a.Country = strings.ToUpper(a.Country)
+	if !IsCountryCode(a.Country) {
+		reject("country", "invalid country, expected an ISO 3166-1 alpha-2 code")
+		if a.PostCode == "" {
+			reject("post_code", "invalid post code")
+		}
+		return fields
+	}
+
+	rule, known := countryRules[a.Country]
+	if !known {
+		rule = countryRule{postCode: genericPostCode}
+	}
+
+	a.PostCode = normalizePostCode(a.PostCode, rule)
+	switch {
+	case rule.postCode == nil:
+		// post code is optional, but whatever was given is kept as is
+	case a.PostCode == "":
+		reject("post_code", "invalid post code")
+	case !rule.postCode.MatchString(a.PostCode):
+		reject("post_code", "invalid post code for country "+a.Country)
+	}
+
+	if rule.states != nil {
+		a.State = strings.ToUpper(a.State)
+	}
+	switch {
+	case a.State == "" && rule.stateRequired:
+		reject("state", "state is required for country "+a.Country)
+	case a.State != "" && rule.states != nil && !rule.states[a.State]:
+		reject("state", "invalid state for country "+a.Country)
+	}
+
+	return fields
+}
+
+func normalizePostCode(postCode string, rule countryRule) string {
+	postCode = strings.ToUpper(strings.Join(strings.Fields(postCode), " "))
+	if rule.formatPostCode != nil && postCode != "" {
+		postCode = rule.formatPostCode(postCode)
+	}
+	return postCode
+}
+
+func codes(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func compact(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+// splitAfter returns a formatter that inserts sep after the first n
+// characters, e.g. "K1A0B1" -> "K1A 0B1".
+func splitAfter(n int, sep string) func(string) string {
+	return func(s string) string {
+		s = compact(s)
+		if len(s) <= n {
+			return s
+		}
+		return s[:n] + sep + s[n:]
+	}
+}
+
+// splitBeforeLast returns a formatter that inserts sep before the last n
+// characters, e.g. "SW1A1AA" -> "SW1A 1AA".
+func splitBeforeLast(n int, sep string) func(string) string {
+	return func(s string) string {
+		s = compact(s)
+		if len(s) <= n {
+			return s
+		}
+		return s[:len(s)-n] + sep + s[len(s)-n:]
+	}
+}
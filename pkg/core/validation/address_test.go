@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAddress = domain.ShippingAddress{
+	EmailInvoice: "oscaac@gmail.com",
+	FullName:     "Oscar Isaac",
+	AddressLine1: "742 Evergreen Terrace",
+	City:         "Springfield",
+	State:        "or",
+	PostCode:     "97403",
+	Country:      "us",
+}
+
+func TestAddress(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		a := testAddress
+		fields := Address(&a)
+
+		assert.Nil(t, fields)
+		assert.EqualValues(t, "US", a.Country)
+		assert.EqualValues(t, "OR", a.State)
+	})
+
+	t.Run("NormalizesPostCode", func(t *testing.T) {
+		cases := []struct {
+			country, postCode, expected string
+		}{
+			{"GB", "sw1a1aa", "SW1A 1AA"},
+			{"CA", "k1a 0b1", "K1A 0B1"},
+			{"NL", "1234ab", "1234 AB"},
+			{"BR", "01310100", "01310-100"},
+			{"IE", "d02 x285", "D02 X285"},
+		}
+		for _, c := range cases {
+			a := testAddress
+			a.Country = c.country
+			a.PostCode = c.postCode
+			a.State = ""
+			Address(&a)
+
+			assert.EqualValues(t, c.expected, a.PostCode, c.country)
+		}
+	})
+
+	t.Run("InvalidCountry", func(t *testing.T) {
+		a := testAddress
+		a.Country = "USA"
+		fields := Address(&a)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "country", fields[0].Field)
+	})
+
+	t.Run("InvalidPostCode", func(t *testing.T) {
+		a := testAddress
+		a.PostCode = "9740"
+		fields := Address(&a)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "post_code", fields[0].Field)
+		assert.EqualValues(t, "invalid post code for country US", fields[0].Message)
+	})
+
+	t.Run("PostCodeOptional", func(t *testing.T) {
+		a := testAddress
+		a.Country = "HK"
+		a.State = ""
+		a.PostCode = ""
+		fields := Address(&a)
+
+		assert.Nil(t, fields)
+	})
+
+	t.Run("StateRequired", func(t *testing.T) {
+		a := testAddress
+		a.State = ""
+		fields := Address(&a)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "state", fields[0].Field)
+	})
+
+	t.Run("InvalidState", func(t *testing.T) {
+		a := testAddress
+		a.Country = "CA"
+		a.PostCode = "K1A 0B1"
+		a.State = "TX"
+		fields := Address(&a)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "invalid state for country CA", fields[0].Message)
+	})
+
+	t.Run("ReportsEveryField", func(t *testing.T) {
+		a := domain.ShippingAddress{Country: "DE"}
+		fields := Address(&a)
+
+		assert.Len(t, fields, 5)
+	})
+}
+
+func TestValidationErrorJSON(t *testing.T) {
+	err := NewValidationError("invalid address", []FieldError{{Field: "city", Message: "invalid city"}})
+
+	bytes, jsonErr := json.Marshal(err)
+	assert.Nil(t, jsonErr)
+
+	var body map[string]interface{}
+	json.Unmarshal(bytes, &body)
+
+	assert.EqualValues(t, "invalid address", body["message"])
+	assert.EqualValues(t, 400, body["status"])
+	assert.Len(t, body["fields"], 1)
+}
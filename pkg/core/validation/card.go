@@ -0,0 +1,163 @@
+package validation
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+)
+
+// maxExpiryYears is how far in the future an expiry date is still considered
+// plausible. Issuers don't print cards valid for longer than that.
+const maxExpiryYears = 20
+
+type binRange struct {
+	from, to int
+}
+
+type cardBrand struct {
+	name    string
+	ranges  []binRange
+	lengths []int
+	cvvLen  int
+}
+
+// cardBrands is checked in order, so brands whose ranges overlap a broader
+// one (discover inside unionpay's 62) must come first.
+var cardBrands = []cardBrand{
+	{name: "amex", ranges: []binRange{{34, 34}, {37, 37}}, lengths: []int{15}, cvvLen: 4},
+	{name: "visa", ranges: []binRange{{4, 4}}, lengths: []int{13, 16, 19}, cvvLen: 3},
+	{name: "mastercard", ranges: []binRange{{51, 55}, {2221, 2720}}, lengths: []int{16}, cvvLen: 3},
+	{name: "discover", ranges: []binRange{{6011, 6011}, {644, 649}, {65, 65}, {622126, 622925}}, lengths: []int{16, 17, 18, 19}, cvvLen: 3},
+	{name: "diners", ranges: []binRange{{300, 305}, {36, 36}, {38, 39}}, lengths: []int{14, 15, 16, 17, 18, 19}, cvvLen: 3},
+	{name: "jcb", ranges: []binRange{{3528, 3589}}, lengths: []int{16, 17, 18, 19}, cvvLen: 3},
+	{name: "unionpay", ranges: []binRange{{62, 62}}, lengths: []int{16, 17, 18, 19}, cvvLen: 3},
+	{name: "maestro", ranges: []binRange{{5018, 5018}, {5020, 5020}, {5038, 5038}, {5893, 5893}, {6304, 6304}, {6759, 6759}, {6761, 6763}}, lengths: []int{12, 13, 14, 15, 16, 17, 18, 19}, cvvLen: 3},
+}
+
+// Luhn reports whether number, made only of ascii digits, has a valid check
+// digit.
+func Luhn(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// CardBrand returns the brand of a card number based on its issuer
+// identification number, or an empty string when no known brand matches.
+func CardBrand(number string) string {
+	if brand := findBrand(number); brand != nil {
+		return brand.name
+	}
+	return ""
+}
+
+func findBrand(number string) *cardBrand {
+	for i := range cardBrands {
+		for _, r := range cardBrands[i].ranges {
+			digits := len(strconv.Itoa(r.from))
+			if len(number) < digits {
+				continue
+			}
+			prefix, err := strconv.Atoi(number[:digits])
+			if err != nil {
+				return nil
+			}
+			if prefix >= r.from && prefix <= r.to {
+				return &cardBrands[i]
+			}
+		}
+	}
+	return nil
+}
+
+// PaymentCard checks the card number, cvv and expiry date of po. The card
+// type is derived from the number and overwrites whatever the client sent,
+// and two digit expiry years are expanded. now is the reference time used to
+// decide whether the card is expired.
+func PaymentCard(po *domain.PaymentOption, now time.Time) []FieldError {
+	var fields []FieldError
+	reject := func(field, message string) {
+		fields = append(fields, FieldError{Field: field, Message: message})
+	}
+
+	po.CardType = ""
+	brand := findBrand(po.CardNumber)
+	switch {
+	case len(po.CardNumber) < 12 || len(po.CardNumber) > 19 || !isDigits(po.CardNumber):
+		reject("card_number", "invalid card number")
+	case !Luhn(po.CardNumber):
+		reject("card_number", "invalid card number, check digit mismatch")
+	case brand == nil:
+		reject("card_number", "unsupported card brand")
+	case !containsInt(brand.lengths, len(po.CardNumber)):
+		reject("card_number", "invalid card number length for "+brand.name)
+	default:
+		po.CardType = brand.name
+	}
+
+	cvvLen := 3
+	if brand != nil {
+		cvvLen = brand.cvvLen
+	}
+	if len(po.CVV) != cvvLen || !isDigits(po.CVV) {
+		reject("cvv", "invalid cvv, expected "+strconv.Itoa(cvvLen)+" digits")
+	}
+
+	if po.ExpiryYear >= 0 && po.ExpiryYear < 100 {
+		po.ExpiryYear += now.Year() / 100 * 100
+	}
+	validMonth := po.ExpiryMonth >= 1 && po.ExpiryMonth <= 12
+	validYear := po.ExpiryYear >= now.Year() && po.ExpiryYear <= now.Year()+maxExpiryYears
+	if !validMonth {
+		reject("expiry_month", "invalid expiry month")
+	}
+	if !validYear {
+		reject("expiry_year", "invalid expiry year")
+	}
+	// cards are valid through the last day of their expiry month
+	if validMonth && validYear && po.ExpiryYear == now.Year() && po.ExpiryMonth < int(now.Month()) {
+		reject("expiry_month", "card is expired")
+	}
+
+	if po.NameOnCard == "" {
+		reject("name_on_card", "invalid name on card")
+	}
+
+	return fields
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testNow  = time.Date(2026, time.June, 15, 0, 0, 0, 0, time.UTC)
+	testCard = domain.PaymentOption{
+		CardNumber:  "4242424242424242",
+		ExpiryMonth: 12,
+		ExpiryYear:  2030,
+		NameOnCard:  "Oscar Isaac",
+		CVV:         "123",
+	}
+)
+
+func TestLuhn(t *testing.T) {
+	assert.True(t, Luhn("4242424242424242"))
+	assert.True(t, Luhn("378282246310005"))
+	assert.False(t, Luhn("4242424242424241"))
+	assert.False(t, Luhn("42424242a2424242"))
+}
+
+func TestCardBrand(t *testing.T) {
+	cases := map[string]string{
+		"4242424242424242": "visa",
+		"5555555555554444": "mastercard",
+		"2223003122003222": "mastercard",
+		"378282246310005":  "amex",
+		"6011111111111117": "discover",
+		"6221260000000000": "discover",
+		"6200000000000005": "unionpay",
+		"3566002020360505": "jcb",
+		"30569309025904":   "diners",
+		"9999999999999995": "",
+	}
+	for number, brand := range cases {
+		assert.EqualValues(t, brand, CardBrand(number), number)
+	}
+}
+
+func TestPaymentCard(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		po := testCard
+		po.CardType = "amex"
+		fields := PaymentCard(&po, testNow)
+
+		assert.Nil(t, fields)
+		assert.EqualValues(t, "visa", po.CardType)
+	})
+
+	t.Run("CheckDigitMismatch", func(t *testing.T) {
+		po := testCard
+		po.CardNumber = "4242424242424241"
+		fields := PaymentCard(&po, testNow)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "card_number", fields[0].Field)
+		assert.EqualValues(t, "", po.CardType)
+	})
+
+	t.Run("AmexRequiresFourDigitCVV", func(t *testing.T) {
+		po := testCard
+		po.CardNumber = "378282246310005"
+		fields := PaymentCard(&po, testNow)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "cvv", fields[0].Field)
+	})
+
+	t.Run("TwoDigitYear", func(t *testing.T) {
+		po := testCard
+		po.ExpiryYear = 28
+		fields := PaymentCard(&po, testNow)
+
+		assert.Nil(t, fields)
+		assert.EqualValues(t, 2028, po.ExpiryYear)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		po := testCard
+		po.ExpiryYear = 2026
+		po.ExpiryMonth = 5
+		fields := PaymentCard(&po, testNow)
+
+		assert.Len(t, fields, 1)
+		assert.EqualValues(t, "card is expired", fields[0].Message)
+	})
+
+	t.Run("ExpiresThisMonth", func(t *testing.T) {
+		po := testCard
+		po.ExpiryYear = 2026
+		po.ExpiryMonth = 6
+		fields := PaymentCard(&po, testNow)
+
+		assert.Nil(t, fields)
+	})
+
+	t.Run("ImpossibleExpiry", func(t *testing.T) {
+		po := testCard
+		po.ExpiryMonth = 13
+		po.ExpiryYear = 2099
+		fields := PaymentCard(&po, testNow)
+
+		assert.Len(t, fields, 2)
+		assert.EqualValues(t, "expiry_month", fields[0].Field)
+		assert.EqualValues(t, "expiry_year", fields[1].Field)
+	})
+}
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is a bad request rest error that also carries the list of
+// fields that failed validation, so clients can point at each of them.
+type ValidationError struct {
+	rest_errors.RestErr
+	Fields []FieldError
+}
+
+func NewValidationError(message string, fields []FieldError) rest_errors.RestErr {
+	return &ValidationError{
+		RestErr: rest_errors.NewRestError(message, http.StatusBadRequest, "bad_request"),
+		Fields:  fields,
+	}
+}
+
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	base, err := json.Marshal(e.RestErr)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make(map[string]interface{})
+	if err := json.Unmarshal(base, &body); err != nil {
+		return nil, err
+	}
+	body["fields"] = e.Fields
+	return json.Marshal(body)
+}
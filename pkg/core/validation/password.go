@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+)
+
+// maxPasswordBytes is the longest password bcrypt can hash, longer ones would
+// be silently truncated. It applies to every hashing scheme, so passwords keep
+// working when the configured one changes.
+const maxPasswordBytes = 72
+
+// minPersonalInfoLength is the shortest email or name part that a password
+// may not contain. Shorter ones, like a two letter name, would reject too
+// many unrelated passwords.
+const minPersonalInfoLength = 3
+
+// PasswordRules configures which passwords users may choose.
+type PasswordRules struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// ForbidPersonalInfo rejects passwords containing the email address or
+	// the name of the user.
+	ForbidPersonalInfo bool
+}
+
+// Password checks password against rules for user u. It returns one entry
+// per broken rule, all of them for the password field, or nil when the
+// password is allowed.
+func Password(password string, u *domain.User, rules PasswordRules) []FieldError {
+	var fields []FieldError
+	reject := func(message string) {
+		fields = append(fields, FieldError{Field: "password", Message: message})
+	}
+
+	if length := len([]rune(password)); length < rules.MinLength {
+		reject("must be at least " + strconv.Itoa(rules.MinLength) + " characters long")
+	}
+	if len(password) > maxPasswordBytes {
+		reject("must be at most " + strconv.Itoa(maxPasswordBytes) + " bytes long")
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if rules.RequireLower && !lower {
+		reject("must contain a lowercase letter")
+	}
+	if rules.RequireUpper && !upper {
+		reject("must contain an uppercase letter")
+	}
+	if rules.RequireDigit && !digit {
+		reject("must contain a digit")
+	}
+	if rules.RequireSymbol && !symbol {
+		reject("must contain a symbol")
+	}
+
+	if rules.ForbidPersonalInfo && u != nil && containsPersonalInfo(password, u) {
+		reject("must not contain your email address or name")
+	}
+	return fields
+}
+
+func containsPersonalInfo(password string, u *domain.User) bool {
+	password = strings.ToLower(password)
+
+	parts := []string{u.Email, u.FirstName, u.LastName}
+	if at := strings.LastIndexByte(u.Email, '@'); at > 0 {
+		parts = append(parts, u.Email[:at])
+	}
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if len([]rune(part)) >= minPersonalInfoLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testPasswordUser = domain.User{
+		FirstName: "Oscar",
+		LastName:  "Isaac",
+		Email:     "oscaac@gmail.com",
+	}
+	testPasswordRules = PasswordRules{
+		MinLength:          12,
+		RequireLower:       true,
+		RequireUpper:       true,
+		RequireDigit:       true,
+		RequireSymbol:      true,
+		ForbidPersonalInfo: true,
+	}
+)
+
+func TestPassword(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		fields := Password("Llewyn-Davis-1961", &testPasswordUser, testPasswordRules)
+
+		assert.Nil(t, fields)
+	})
+
+	t.Run("EveryRule", func(t *testing.T) {
+		fields := Password("oscar", &testPasswordUser, testPasswordRules)
+
+		messages := make([]string, 0, len(fields))
+		for _, f := range fields {
+			assert.EqualValues(t, "password", f.Field)
+			messages = append(messages, f.Message)
+		}
+		assert.EqualValues(t, []string{
+			"must be at least 12 characters long",
+			"must contain an uppercase letter",
+			"must contain a digit",
+			"must contain a symbol",
+			"must not contain your email address or name",
+		}, messages)
+	})
+
+	t.Run("CountsRunes", func(t *testing.T) {
+		fields := Password("ñandúñandú", &testPasswordUser, PasswordRules{MinLength: 10})
+
+		assert.Nil(t, fields)
+	})
+
+	t.Run("TooLongForBcrypt", func(t *testing.T) {
+		fields := Password(strings.Repeat("a", 73), &testPasswordUser, PasswordRules{})
+
+		assert.Len(t, fields, 1)
+	})
+
+	t.Run("PersonalInfo", func(t *testing.T) {
+		rules := PasswordRules{ForbidPersonalInfo: true}
+
+		assert.NotNil(t, Password("my-OSCAAC-pass", &testPasswordUser, rules))
+		assert.NotNil(t, Password("isaac1961!", &testPasswordUser, rules))
+		assert.Nil(t, Password("llewyn1961!", &testPasswordUser, rules))
+
+		short := domain.User{FirstName: "Al", Email: "al@x.io"}
+		assert.Nil(t, Password("always-alert", &short, rules))
+	})
+}
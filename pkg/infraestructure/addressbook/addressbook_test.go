@@ -0,0 +1,154 @@
+package addressbook
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAddresses = []domain.ShippingAddress{
+	{
+		EmailInvoice: "oscaac@gmail.com",
+		FullName:     "Oscar Isaac",
+		AddressLine1: "742 Evergreen Terrace",
+		City:         "Springfield",
+		State:        "OR",
+		PostCode:     "97403",
+		Country:      "US",
+		IsDefault:    true,
+	},
+	{
+		FullName:     "Frances McDormand; Warehouse",
+		AddressLine1: "=1+1 Main Street, Unit 4",
+		AddressLine2: "Dock 3",
+		City:         "Montréal",
+		State:        "QC",
+		PostCode:     "H2X 1Y4",
+		Country:      "CA",
+	},
+}
+
+func TestDecodeCSV(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		input := "\ufeffCountry,full_name,address_line1,city,state,post_code\n" +
+			"US,Oscar Isaac,742 Evergreen Terrace,Springfield,OR,97403\n" +
+			"\n" +
+			"CA,\"Frances McDormand\",\"1 Main Street, Unit 4\",Montréal,QC,H2X 1Y4\n"
+
+		rows, err := DecodeCSV(strings.NewReader(input))
+
+		assert.Nil(t, err)
+		assert.Len(t, rows, 2)
+		assert.EqualValues(t, 2, rows[0].Row)
+		assert.EqualValues(t, "US", rows[0].Address.Country)
+		assert.EqualValues(t, "742 Evergreen Terrace", rows[0].Address.AddressLine1)
+		assert.EqualValues(t, 4, rows[1].Row)
+		assert.EqualValues(t, "1 Main Street, Unit 4", rows[1].Address.AddressLine1)
+		assert.Nil(t, rows[1].Errors)
+	})
+
+	t.Run("WrongFieldCount", func(t *testing.T) {
+		rows, err := DecodeCSV(strings.NewReader("full_name,city\nOscar Isaac\n"))
+
+		assert.Nil(t, err)
+		assert.Len(t, rows, 1)
+		assert.EqualValues(t, "expected 2 fields, got 1", rows[0].Errors["row"])
+	})
+
+	t.Run("UnknownColumn", func(t *testing.T) {
+		rows, err := DecodeCSV(strings.NewReader("full_name,phone\nOscar Isaac,555\n"))
+
+		assert.Nil(t, rows)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, `unknown column "phone"`, err.Error())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, err := DecodeCSV(strings.NewReader(""))
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	err := EncodeCSV(&b, testAddresses)
+	assert.Nil(t, err)
+	assert.Contains(t, b.String(), `"'=1+1 Main Street, Unit 4"`)
+
+	rows, err := DecodeCSV(&b)
+
+	assert.Nil(t, err)
+	assert.Len(t, rows, 2)
+	for i, row := range rows {
+		expected := testAddresses[i]
+		expected.IsDefault = false
+		assert.EqualValues(t, expected, row.Address)
+	}
+}
+
+func TestDecodeVCard(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		input := "BEGIN:VCARD\r\n" +
+			"VERSION:3.0\r\n" +
+			"FN:Oscar Isaac\r\n" +
+			"EMAIL;TYPE=INTERNET:oscaac@gmail.com\r\n" +
+			"item1.ADR;TYPE=WORK:;;742 Evergreen\r\n" +
+			"  Terrace;Springfield;OR;97403;US\r\n" +
+			"ADR;TYPE=HOME:;;1 Other Street;Portland;OR;97201;US\r\n" +
+			"END:VCARD\r\n" +
+			"BEGIN:VCARD\r\n" +
+			"VERSION:4.0\r\n" +
+			"FN:Frances McDormand\r\n" +
+			"END:VCARD\r\n"
+
+		rows, err := DecodeVCard(strings.NewReader(input))
+
+		assert.Nil(t, err)
+		assert.Len(t, rows, 2)
+		assert.EqualValues(t, 1, rows[0].Row)
+		assert.EqualValues(t, "742 Evergreen Terrace", rows[0].Address.AddressLine1)
+		assert.EqualValues(t, "Springfield", rows[0].Address.City)
+		assert.EqualValues(t, "oscaac@gmail.com", rows[0].Address.EmailInvoice)
+		assert.Nil(t, rows[0].Errors)
+		assert.EqualValues(t, 2, rows[1].Row)
+		assert.EqualValues(t, "card has no ADR property", rows[1].Errors["row"])
+	})
+
+	t.Run("NotTerminated", func(t *testing.T) {
+		_, err := DecodeVCard(strings.NewReader("BEGIN:VCARD\nFN:Oscar Isaac\n"))
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NotAVCard", func(t *testing.T) {
+		_, err := DecodeVCard(strings.NewReader("full_name,city\n"))
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestVCardRoundTrip(t *testing.T) {
+	addresses := append([]domain.ShippingAddress{}, testAddresses...)
+	addresses[1].AddressLine2 = strings.Repeat("Dock é ", 20)
+
+	var b bytes.Buffer
+	err := EncodeVCard(&b, addresses)
+	assert.Nil(t, err)
+	assert.Contains(t, b.String(), "ADR;PREF=1:;;742 Evergreen Terrace;Springfield;OR;97403;US\r\n")
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		assert.LessOrEqual(t, len(line), vcardLineLength)
+	}
+
+	rows, err := DecodeVCard(&b)
+
+	assert.Nil(t, err)
+	assert.Len(t, rows, 2)
+	assert.EqualValues(t, "Frances McDormand; Warehouse", rows[1].Address.FullName)
+	assert.EqualValues(t, "=1+1 Main Street, Unit 4", rows[1].Address.AddressLine1)
+	assert.EqualValues(t, strings.TrimSpace(addresses[1].AddressLine2), rows[1].Address.AddressLine2)
+	assert.EqualValues(t, "Montréal", rows[1].Address.City)
+}
@@ -0,0 +1,122 @@
+// Package addressbook reads and writes the shipping addresses of a user in
+// the formats customers use to move address books between systems.
+package addressbook
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+)
+
+// csvColumns are the columns written on export, imports accept them in any
+// order and require a header row naming them.
+var csvColumns = []string{"email_invoice", "full_name", "address_line1", "address_line2", "city", "state", "post_code", "country"}
+
+func csvFields(a *domain.ShippingAddress) []*string {
+	return []*string{&a.EmailInvoice, &a.FullName, &a.AddressLine1, &a.AddressLine2, &a.City, &a.State, &a.PostCode, &a.Country}
+}
+
+// DecodeCSV parses an address book in CSV format. Rows are numbered after
+// their line in the file, so they can be found in a spreadsheet. Rows that
+// can't be read are returned with an error instead of failing the import,
+// only a missing or unknown header is fatal.
+func DecodeCSV(r io.Reader) ([]domain.AddressImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]int, len(header))
+	seen := make(map[int]bool)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[i] = -1
+		for j, column := range csvColumns {
+			if name == column {
+				columns[i] = j
+			}
+		}
+		if columns[i] == -1 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[columns[i]] {
+			return nil, fmt.Errorf("duplicated column %q", name)
+		}
+		seen[columns[i]] = true
+	}
+
+	var rows []domain.AddressImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := domain.AddressImportRow{Row: line}
+		if len(record) != len(header) {
+			row.Errors = map[string]string{"row": fmt.Sprintf("expected %d fields, got %d", len(header), len(record))}
+			rows = append(rows, row)
+			continue
+		}
+
+		fields := csvFields(&row.Address)
+		for i, value := range record {
+			*fields[columns[i]] = unescapeFormula(value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// EncodeCSV writes addresses with a header row of all the columns.
+func EncodeCSV(w io.Writer, addresses []domain.ShippingAddress) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+	for i := range addresses {
+		fields := csvFields(&addresses[i])
+		record := make([]string, len(fields))
+		for j, field := range fields {
+			record[j] = escapeFormula(*field)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Spreadsheets evaluate cells starting with these characters as formulas, so
+// exported values starting with one of them are prefixed with a quote, which
+// is dropped again on import.
+const formulaPrefixes = "=+-@\t\r"
+
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
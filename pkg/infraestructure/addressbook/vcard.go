@@ -0,0 +1,213 @@
+package addressbook
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+)
+
+// vCard lines longer than this many octets are folded on export (RFC 6350).
+const vcardLineLength = 75
+
+// DecodeVCard parses an address book in vCard format (versions 3.0 and 4.0).
+// Every card is a row, numbered from 1, whose address is taken from its first
+// ADR property. FN and EMAIL fill the full name and the invoice email.
+func DecodeVCard(r io.Reader) ([]domain.AddressImportRow, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []domain.AddressImportRow
+	var card *domain.AddressImportRow
+	hasAddress := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, ok := splitProperty(line)
+		if !ok {
+			if card == nil {
+				return nil, fmt.Errorf("invalid vCard line %q", line)
+			}
+			card.Errors = map[string]string{"row": fmt.Sprintf("invalid line %q", line)}
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			if card != nil {
+				return nil, fmt.Errorf("card %d is not terminated", len(rows)+1)
+			}
+			card = &domain.AddressImportRow{Row: len(rows) + 1}
+			hasAddress = false
+		case card == nil:
+			return nil, fmt.Errorf("property %s outside of a card", name)
+		case name == "END" && strings.EqualFold(value, "VCARD"):
+			if !hasAddress && card.Errors == nil {
+				card.Errors = map[string]string{"row": "card has no ADR property"}
+			}
+			rows = append(rows, *card)
+			card = nil
+		case name == "FN":
+			card.Address.FullName = unescapeText(value)
+		case name == "EMAIL" && card.Address.EmailInvoice == "":
+			card.Address.EmailInvoice = unescapeText(value)
+		case name == "ADR" && !hasAddress:
+			hasAddress = true
+			// PO box; extended address; street; locality; region; postal code; country
+			parts := splitComponents(value)
+			for len(parts) < 7 {
+				parts = append(parts, "")
+			}
+			card.Address.AddressLine2 = parts[1]
+			card.Address.AddressLine1 = parts[2]
+			card.Address.City = parts[3]
+			card.Address.State = parts[4]
+			card.Address.PostCode = parts[5]
+			card.Address.Country = parts[6]
+			if parts[0] != "" && card.Address.AddressLine2 == "" {
+				card.Address.AddressLine2 = parts[0]
+			}
+		}
+	}
+	if card != nil {
+		return nil, fmt.Errorf("card %d is not terminated", len(rows)+1)
+	}
+	return rows, nil
+}
+
+// EncodeVCard writes every address as a vCard 4.0 card.
+func EncodeVCard(w io.Writer, addresses []domain.ShippingAddress) error {
+	bw := bufio.NewWriter(w)
+	for _, a := range addresses {
+		adr := strings.Join([]string{
+			"",
+			escapeText(a.AddressLine2),
+			escapeText(a.AddressLine1),
+			escapeText(a.City),
+			escapeText(a.State),
+			escapeText(a.PostCode),
+			escapeText(a.Country),
+		}, ";")
+
+		lines := []string{"BEGIN:VCARD", "VERSION:4.0", "FN:" + escapeText(a.FullName)}
+		if a.EmailInvoice != "" {
+			lines = append(lines, "EMAIL:"+escapeText(a.EmailInvoice))
+		}
+		if a.IsDefault {
+			lines = append(lines, "ADR;PREF=1:"+adr)
+		} else {
+			lines = append(lines, "ADR:"+adr)
+		}
+		lines = append(lines, "END:VCARD")
+
+		for _, line := range lines {
+			if _, err := bw.WriteString(fold(line)); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// unfold reads the logical lines of a vCard, joining continuation lines that
+// start with a space or a tab.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		lines[0] = strings.TrimPrefix(lines[0], "\ufeff")
+	}
+	return lines, nil
+}
+
+// splitProperty returns the upper cased name of a content line, without its
+// group and parameters, and its raw value.
+func splitProperty(line string) (string, string, bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+	name := line[:i]
+	if j := strings.IndexByte(name, ';'); j >= 0 {
+		name = name[:j]
+	}
+	if j := strings.LastIndexByte(name, '.'); j >= 0 {
+		name = name[j+1:]
+	}
+	return strings.ToUpper(strings.TrimSpace(name)), line[i+1:], true
+}
+
+// splitComponents splits a structured value on the semicolons that are not
+// escaped and unescapes every component.
+func splitComponents(value string) []string {
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			current.WriteByte(value[i])
+			current.WriteByte(value[i+1])
+			i++
+		case value[i] == ';':
+			parts = append(parts, unescapeText(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(value[i])
+		}
+	}
+	return append(parts, unescapeText(current.String()))
+}
+
+var (
+	textEscaper   = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\n", `\n`)
+	textUnescaper = strings.NewReplacer(`\\`, `\`, `\,`, `,`, `\;`, `;`, `\n`, "\n", `\N`, "\n")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	return strings.TrimSpace(textUnescaper.Replace(s))
+}
+
+// fold splits line into chunks of at most vcardLineLength octets, without
+// breaking multi-byte characters, and terminates it with CRLF.
+func fold(line string) string {
+	var b strings.Builder
+	limit := vcardLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space that counts towards the limit
+		limit = vcardLineLength - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
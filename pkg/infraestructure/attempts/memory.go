@@ -0,0 +1,85 @@
+package attempts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// sweepEvery is the number of failures recorded between two sweeps of the
+// stale entries.
+const sweepEvery = 1024
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]domain.LoginAttempts
+	writes  int
+}
+
+// NewMemoryStore returns a store that keeps login attempts in memory. They
+// are lost on restart and not shared between instances, use it when a single
+// instance of the service runs.
+func NewMemoryStore() ports.LoginAttemptStore {
+	return &memoryStore{
+		entries: make(map[string]domain.LoginAttempts),
+	}
+}
+
+func (s *memoryStore) Get(key string) (*domain.LoginAttempts, rest_errors.RestErr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.entries[key]
+	return &attempts, nil
+}
+
+func (s *memoryStore) Fail(key string, failedAt time.Time, window time.Duration) (*domain.LoginAttempts, rest_errors.RestErr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	if s.writes%sweepEvery == 0 {
+		s.sweep(failedAt, window)
+	}
+
+	attempts := s.entries[key]
+	if attempts.LastFailure.Before(failedAt.Add(-window)) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailure = failedAt
+	s.entries[key] = attempts
+	return &attempts, nil
+}
+
+func (s *memoryStore) Lock(key string, until time.Time) rest_errors.RestErr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.entries[key]
+	attempts.Failures = 0
+	attempts.LockedUntil = until
+	s.entries[key] = attempts
+	return nil
+}
+
+func (s *memoryStore) Reset(key string) rest_errors.RestErr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops the entries that are neither locked nor failed within window,
+// so addresses that tried once don't stay in memory forever.
+func (s *memoryStore) sweep(now time.Time, window time.Duration) {
+	for key, attempts := range s.entries {
+		if attempts.LockedUntil.Before(now) && attempts.LastFailure.Before(now.Add(-window)) {
+			delete(s.entries, key)
+		}
+	}
+}
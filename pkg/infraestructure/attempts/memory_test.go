@@ -0,0 +1,60 @@
+package attempts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	t.Run("CountsWithinWindow", func(t *testing.T) {
+		s := NewMemoryStore()
+
+		s.Fail("ip:203.0.113.7", now, time.Minute)
+		attempts, err := s.Fail("ip:203.0.113.7", now.Add(30*time.Second), time.Minute)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, attempts.Failures)
+
+		attempts, _ = s.Fail("ip:203.0.113.7", now.Add(2*time.Minute), time.Minute)
+
+		assert.EqualValues(t, 1, attempts.Failures)
+	})
+
+	t.Run("LockClearsFailures", func(t *testing.T) {
+		s := NewMemoryStore()
+
+		s.Fail("account:oscaac@gmail.com", now, time.Minute)
+		err := s.Lock("account:oscaac@gmail.com", now.Add(time.Hour))
+		attempts, _ := s.Get("account:oscaac@gmail.com")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, attempts.Failures)
+		assert.EqualValues(t, now.Add(time.Hour), attempts.LockedUntil)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		s := NewMemoryStore()
+
+		s.Lock("account:oscaac@gmail.com", now.Add(time.Hour))
+		s.Reset("account:oscaac@gmail.com")
+		attempts, err := s.Get("account:oscaac@gmail.com")
+
+		assert.Nil(t, err)
+		assert.True(t, attempts.LockedUntil.IsZero())
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		s := NewMemoryStore().(*memoryStore)
+
+		s.Fail("ip:203.0.113.7", now, time.Minute)
+		s.Lock("account:oscaac@gmail.com", now.Add(time.Hour))
+		s.sweep(now.Add(2*time.Minute), time.Minute)
+
+		assert.NotContains(t, s.entries, "ip:203.0.113.7")
+		assert.Contains(t, s.entries, "account:oscaac@gmail.com")
+	})
+}
@@ -0,0 +1,100 @@
+package breached
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// fileList looks passwords up in a local copy of a breached passwords dump,
+// like the SHA-1 one published by Have I Been Pwned. Every line holds the
+// hex SHA-1 hash of a password, optionally followed by a colon and the
+// number of times it was seen, and lines must be sorted by hash. The file is
+// binary searched on disk, so dumps of several gigabytes don't need to fit in
+// memory.
+type fileList struct {
+	f    *os.File
+	size int64
+}
+
+func NewFileList(path string) (ports.BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileList{f: f, size: info.Size()}, nil
+}
+
+func (l *fileList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// finds the first offset whose next line holds a hash >= target, which
+	// is the only line that can match
+	var searchErr error
+	offset := sort.Search(int(l.size)+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		hash, ok, err := l.hashFrom(int64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return !ok || hash >= target
+	})
+	if searchErr != nil {
+		return false, searchErr
+	}
+
+	hash, ok, err := l.hashFrom(int64(offset))
+	if err != nil {
+		return false, err
+	}
+	return ok && hash == target, nil
+}
+
+// hashFrom returns the hash of the first line starting at or after offset,
+// and false when there's none.
+func (l *fileList) hashFrom(offset int64) (string, bool, error) {
+	start := offset
+	if offset > 0 {
+		start = offset - 1
+	}
+	r := bufio.NewReaderSize(io.NewSectionReader(l.f, start, l.size-start), 128)
+
+	if offset > 0 {
+		// skips the rest of the line offset falls in, unless it's the start
+		// of one
+		if _, err := r.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return "", false, nil
+			}
+			return "", false, err
+		}
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", false, nil
+	}
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.ToUpper(line), true, nil
+}
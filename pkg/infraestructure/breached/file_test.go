@@ -0,0 +1,83 @@
+package breached
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeList(t *testing.T, passwords []string, lowercase bool) string {
+	lines := make([]string, 0, len(passwords))
+	for i, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+		lines = append(lines, hash+":"+strings.Repeat("9", i+1))
+	}
+	sort.Strings(lines)
+	content := strings.Join(lines, "\r\n") + "\r\n"
+	if lowercase {
+		content = strings.ToLower(content)
+	}
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestContains(t *testing.T) {
+	breached := []string{"123456", "password", "qwerty", "letmein", "dragon", "monkey", "P@ssw0rd", "iloveyou"}
+
+	t.Run("Found", func(t *testing.T) {
+		l, err := NewFileList(writeList(t, breached, false))
+		assert.Nil(t, err)
+
+		for _, p := range breached {
+			found, err := l.Contains(p)
+			assert.Nil(t, err)
+			assert.True(t, found, p)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		l, err := NewFileList(writeList(t, breached, true))
+		assert.Nil(t, err)
+
+		for _, p := range []string{"Llewyn-Davis-1961", "", "1234567", "passwor"} {
+			found, err := l.Contains(p)
+			assert.Nil(t, err)
+			assert.False(t, found, p)
+		}
+	})
+
+	t.Run("Lowercase", func(t *testing.T) {
+		l, err := NewFileList(writeList(t, breached, true))
+		assert.Nil(t, err)
+
+		found, err := l.Contains("dragon")
+		assert.Nil(t, err)
+		assert.True(t, found)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		l, err := NewFileList(writeList(t, nil, false))
+		assert.Nil(t, err)
+
+		found, err := l.Contains("dragon")
+		assert.Nil(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := NewFileList(filepath.Join(t.TempDir(), "missing.txt"))
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,3 @@
+package oauthpb
+
+//go:generate protoc --go-grpc_out=. --go-grpc_opt=paths=source_relative sessions.proto
@@ -0,0 +1,107 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: sessions.proto
+
+package oauthpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// OauthServiceClient is the client API for OauthService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OauthServiceClient interface {
+	RevokeUserTokens(ctx context.Context, in *wrapperspb.Int64Value, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type oauthServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOauthServiceClient(cc grpc.ClientConnInterface) OauthServiceClient {
+	return &oauthServiceClient{cc}
+}
+
+func (c *oauthServiceClient) RevokeUserTokens(ctx context.Context, in *wrapperspb.Int64Value, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/oauth.OauthService/RevokeUserTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OauthServiceServer is the server API for OauthService service.
+// All implementations must embed UnimplementedOauthServiceServer
+// for forward compatibility
+type OauthServiceServer interface {
+	RevokeUserTokens(context.Context, *wrapperspb.Int64Value) (*emptypb.Empty, error)
+	mustEmbedUnimplementedOauthServiceServer()
+}
+
+// UnimplementedOauthServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedOauthServiceServer struct {
+}
+
+func (UnimplementedOauthServiceServer) RevokeUserTokens(context.Context, *wrapperspb.Int64Value) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeUserTokens not implemented")
+}
+func (UnimplementedOauthServiceServer) mustEmbedUnimplementedOauthServiceServer() {}
+
+// UnsafeOauthServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OauthServiceServer will
+// result in compilation errors.
+type UnsafeOauthServiceServer interface {
+	mustEmbedUnimplementedOauthServiceServer()
+}
+
+func RegisterOauthServiceServer(s grpc.ServiceRegistrar, srv OauthServiceServer) {
+	s.RegisterService(&OauthService_ServiceDesc, srv)
+}
+
+func _OauthService_RevokeUserTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.Int64Value)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OauthServiceServer).RevokeUserTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/oauth.OauthService/RevokeUserTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OauthServiceServer).RevokeUserTokens(ctx, req.(*wrapperspb.Int64Value))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// OauthService_ServiceDesc is the grpc.ServiceDesc for OauthService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OauthService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "oauth.OauthService",
+	HandlerType: (*OauthServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RevokeUserTokens",
+			Handler:    _OauthService_RevokeUserTokens_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sessions.proto",
+}
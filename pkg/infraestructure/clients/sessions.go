@@ -0,0 +1,30 @@
+package clients
+
+import (
+	"context"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/clients/oauthpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const revokeTimeout = 5 * time.Second
+
+// oauthSessionRevoker revokes sessions through the oauth API.
+type oauthSessionRevoker struct {
+	c oauthpb.OauthServiceClient
+}
+
+func NewOauthSessionRevoker(cc grpc.ClientConnInterface) ports.SessionRevoker {
+	return &oauthSessionRevoker{c: oauthpb.NewOauthServiceClient(cc)}
+}
+
+func (r *oauthSessionRevoker) RevokeSessions(userId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+	defer cancel()
+
+	_, err := r.c.RevokeUserTokens(ctx, wrapperspb.Int64(userId))
+	return err
+}
@@ -0,0 +1,150 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// Encrypted values are stored as "v1:<key id>:<base64(nonce|ciphertext)>".
+// Anything without the prefix is treated as legacy plaintext, which lets
+// rows written before encryption was enabled be read until they are
+// rotated.
+const (
+	versionPrefix = "v1:"
+	keyFileSuffix = ".key"
+)
+
+var (
+	ErrUnknownKey       = errors.New("encryption key not found in keyring")
+	ErrMalformedValue   = errors.New("malformed encrypted value")
+	errInvalidKeyLength = errors.New("encryption keys must be 32 bytes long")
+)
+
+type keyring struct {
+	active   string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring loads every "<key id>.key" file of dir as an AES-256 key, each
+// holding 32 base64 encoded bytes. New values are encrypted with activeKey,
+// while any key of the ring can decrypt. indexKeyPath holds the HMAC key of
+// the blind indexes and, unlike the others, must never change.
+func NewKeyring(dir, activeKey, indexKeyPath string) (ports.FieldCipher, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+keyFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	k := &keyring{
+		active: activeKey,
+		keys:   make(map[string]cipher.AEAD),
+	}
+	for _, file := range files {
+		key, err := readKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[strings.TrimSuffix(filepath.Base(file), keyFileSuffix)] = aead
+	}
+	if _, ok := k.keys[activeKey]; !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if k.indexKey, err = readKey(indexKeyPath); err != nil {
+		return nil, fmt.Errorf("%s: %w", indexKeyPath, err)
+	}
+	return k, nil
+}
+
+func readKey(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errInvalidKeyLength
+	}
+	return key, nil
+}
+
+func (k *keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	aead := k.keys[k.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	// the key id is authenticated too, so values can't be moved across keys
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.active))
+	return versionPrefix + k.active + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *keyring) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, versionPrefix) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, versionPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", ErrMalformedValue
+	}
+	aead, ok := k.keys[parts[0]]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedValue
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex is case insensitive, as every value indexed so far is an email.
+func (k *keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k *keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, versionPrefix+k.active+":")
+}
@@ -0,0 +1,103 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKey(t *testing.T, dir, name string, b byte) string {
+	path := filepath.Join(dir, name)
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+	if err := ioutil.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestKeyring(t *testing.T, active string) (*keyring, string) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2022-01.key", 'a')
+	writeKey(t, dir, "2022-06.key", 'b')
+	indexKey := writeKey(t, t.TempDir(), "index", 'c')
+
+	c, err := NewKeyring(dir, active, indexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*keyring), dir
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, _ := newTestKeyring(t, "2022-06")
+
+	encrypted, err := k.Encrypt("oscaac@gmail.com")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "v1:2022-06:"))
+	assert.NotContains(t, encrypted, "oscaac")
+
+	decrypted, err := k.Decrypt(encrypted)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "oscaac@gmail.com", decrypted)
+
+	again, _ := k.Encrypt("oscaac@gmail.com")
+	assert.NotEqual(t, encrypted, again)
+}
+
+func TestDecryptLegacyPlaintext(t *testing.T) {
+	k, _ := newTestKeyring(t, "2022-06")
+
+	value, err := k.Decrypt("742 Evergreen Terrace")
+	assert.Nil(t, err)
+	assert.EqualValues(t, "742 Evergreen Terrace", value)
+	assert.True(t, k.NeedsRotation("742 Evergreen Terrace"))
+}
+
+func TestRotation(t *testing.T) {
+	old, _ := newTestKeyring(t, "2022-01")
+	encrypted, _ := old.Encrypt("Oscar")
+
+	k, _ := newTestKeyring(t, "2022-06")
+	assert.True(t, k.NeedsRotation(encrypted))
+
+	decrypted, err := k.Decrypt(encrypted)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "Oscar", decrypted)
+
+	rotated, _ := k.Encrypt(decrypted)
+	assert.False(t, k.NeedsRotation(rotated))
+}
+
+func TestDecryptTampered(t *testing.T) {
+	k, _ := newTestKeyring(t, "2022-06")
+	encrypted, _ := k.Encrypt("Oscar")
+
+	// pretend the value was written with the other key
+	moved := strings.Replace(encrypted, "2022-06", "2022-01", 1)
+	_, err := k.Decrypt(moved)
+	assert.NotNil(t, err)
+
+	_, err = k.Decrypt("v1:2030-01:AAAA")
+	assert.EqualValues(t, ErrUnknownKey, err)
+}
+
+func TestBlindIndex(t *testing.T) {
+	k, _ := newTestKeyring(t, "2022-06")
+
+	assert.EqualValues(t, k.BlindIndex("oscaac@gmail.com"), k.BlindIndex(" OSCAAC@gmail.com"))
+	assert.NotEqual(t, k.BlindIndex("oscaac@gmail.com"), k.BlindIndex("other@gmail.com"))
+	assert.Len(t, k.BlindIndex("oscaac@gmail.com"), 64)
+}
+
+func TestNewKeyringUnknownActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2022-01.key", 'a')
+	indexKey := writeKey(t, dir, "index", 'c')
+
+	_, err := NewKeyring(dir, "2022-06", indexKey)
+	assert.EqualValues(t, ErrUnknownKey, err)
+}
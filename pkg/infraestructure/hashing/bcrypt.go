@@ -0,0 +1,44 @@
+package hashing
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type bcryptScheme struct {
+	cost int
+}
+
+// NewBcrypt returns the bcrypt scheme hashing with cost. Hashes made with a
+// different cost need a rehash.
+func NewBcrypt(cost int) Scheme {
+	return &bcryptScheme{cost: cost}
+}
+
+func (b *bcryptScheme) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b *bcryptScheme) Verify(password, hash string) (bool, bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, err
+	}
+	return true, cost != b.cost, nil
+}
+
+func (b *bcryptScheme) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
@@ -0,0 +1,47 @@
+package hashing
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// ErrUnknownFormat is returned when a hash wasn't made by any known scheme.
+var ErrUnknownFormat = ports.ErrUnknownHashFormat
+
+// Scheme is a hashing algorithm that can tell its hashes apart from the
+// ones of other algorithms.
+type Scheme interface {
+	ports.PasswordHasher
+	Recognizes(hash string) bool
+}
+
+type hasher struct {
+	current Scheme
+	others  []Scheme
+}
+
+// NewHasher returns a hasher that hashes new passwords with current and
+// still verifies the hashes made by others. Matching hashes of another scheme
+// are reported as needing a rehash, so they move to current over time.
+func NewHasher(current Scheme, others ...Scheme) ports.PasswordHasher {
+	return &hasher{
+		current: current,
+		others:  others,
+	}
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *hasher) Verify(password, hash string) (bool, bool, error) {
+	if h.current.Recognizes(hash) {
+		return h.current.Verify(password, hash)
+	}
+	for _, scheme := range h.others {
+		if scheme.Recognizes(hash) {
+			match, _, err := scheme.Verify(password, hash)
+			return match, match, err
+		}
+	}
+	return false, false, ErrUnknownFormat
+}
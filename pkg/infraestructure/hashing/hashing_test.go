@@ -0,0 +1,102 @@
+package hashing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var argon2Test = Argon2Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2id(t *testing.T) {
+	scheme := NewArgon2id(argon2Test)
+
+	hash, err := scheme.Hash("password")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, scheme.Recognizes(hash))
+
+	match, rehash, err := scheme.Verify("password", hash)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.False(t, rehash)
+
+	match, _, err = scheme.Verify("notthepassword", hash)
+	assert.Nil(t, err)
+	assert.False(t, match)
+
+	stronger := argon2Test
+	stronger.Iterations = 2
+	match, rehash, err = NewArgon2id(stronger).Verify("password", hash)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.True(t, rehash)
+
+	_, _, err = scheme.Verify("password", "$argon2id$v=19$m=1024$salt")
+	assert.EqualValues(t, ErrUnknownFormat, err)
+}
+
+func TestBcrypt(t *testing.T) {
+	scheme := NewBcrypt(bcrypt.MinCost)
+
+	hash, err := scheme.Hash("password")
+	assert.Nil(t, err)
+	assert.True(t, scheme.Recognizes(hash))
+
+	match, rehash, err := scheme.Verify("password", hash)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.False(t, rehash)
+
+	match, rehash, err = NewBcrypt(bcrypt.MinCost+1).Verify("password", hash)
+	assert.Nil(t, err)
+	assert.True(t, match)
+	assert.True(t, rehash)
+
+	match, _, err = scheme.Verify("notthepassword", hash)
+	assert.Nil(t, err)
+	assert.False(t, match)
+}
+
+func TestHasher(t *testing.T) {
+	h := NewHasher(NewArgon2id(argon2Test), NewBcrypt(bcrypt.MinCost))
+
+	t.Run("CurrentScheme", func(t *testing.T) {
+		hash, err := h.Hash("password")
+		assert.Nil(t, err)
+
+		match, rehash, err := h.Verify("password", hash)
+		assert.Nil(t, err)
+		assert.True(t, match)
+		assert.False(t, rehash)
+	})
+
+	t.Run("LegacyScheme", func(t *testing.T) {
+		// password
+		legacy := "$2a$10$jRL.gYiodDnwcOBErnDfuu5044h40PM3ZOAOzit6O4RIL9wG24xJ6"
+
+		match, rehash, err := h.Verify("password", legacy)
+		assert.Nil(t, err)
+		assert.True(t, match)
+		assert.True(t, rehash)
+
+		match, rehash, err = h.Verify("notthepassword", legacy)
+		assert.Nil(t, err)
+		assert.False(t, match)
+		assert.False(t, rehash)
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		_, _, err := h.Verify("password", "5f4dcc3b5aa765d61d8327deb882cf99")
+
+		assert.EqualValues(t, ErrUnknownFormat, err)
+	})
+}
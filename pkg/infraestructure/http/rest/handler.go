@@ -19,6 +19,7 @@ package rest
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
 	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
@@ -28,16 +29,59 @@ import (
 	"github.com/go-openapi/runtime/middleware"
 )
 
-func (s *Server) Handler(us ports.UsersService) *gin.Engine {
+func (s *Server) Handler(us ports.UsersService, as ports.ShippingAddressService, ps ports.PaymentOptionService) *gin.Engine {
 	router := gin.Default()
+	// client addresses key the login throttling, a header anyone can set
+	// mustn't pick them
+	if err := router.SetTrustedProxies(s.trustedProxies); err != nil {
+		panic("invalid trusted proxies")
+	}
 
 	router.POST("/users", registerUser(us))
 	router.POST("/users/login", login(us))
-	router.GET("/users/:user_id", auth.RequiresAuth(getUser(us), s.oauth.C))
+	router.POST("/users/login/magic", sendMagicLink(us))
+	router.POST("/users/login/magic/verify", loginWithMagicLink(us))
+	router.POST("/users/login/2fa", verifyLogin(us))
+	router.POST("/users/login/2fa/enroll", enrollWithChallenge(us))
+	router.POST("/users/login/2fa/enroll/confirm", confirmWithChallenge(us))
+	router.POST("/users/verify", verifyEmail(us))
+	router.POST("/users/verify/resend", resendVerification(us))
+	router.POST("/users/email/confirm", confirmEmailChange(us))
+	router.POST("/users/password/forgot", forgotPassword(us))
+	router.POST("/users/password/reset", resetPassword(us))
+	router.GET("/users", auth.RequiresAuth(listUsers(us), s.oauth.C))
+	router.GET("/users/:user_id", s.requiresAuth(us, getUser(us), domain.ScopeUsersRead))
 	router.PUT("/users/:user_id", auth.RequiresAuth(updateUser(us), s.oauth.C))
-	// router.DELETE("/users/:user_id")
+	router.DELETE("/users/:user_id", auth.RequiresAuth(deleteUser(us), s.oauth.C))
+	router.PUT("/users/:user_id/password", auth.RequiresAuth(changePassword(us), s.oauth.C))
+	router.DELETE("/users/:user_id/lock", auth.RequiresAuth(unlockUser(us), s.oauth.C))
+	router.GET("/users/:user_id/logins", s.requiresAuth(us, listLogins(us), domain.ScopeUsersRead))
+	router.POST("/users/:user_id/2fa", auth.RequiresAuth(enrollTwoFactor(us), s.oauth.C))
+	router.POST("/users/:user_id/2fa/confirm", auth.RequiresAuth(confirmTwoFactor(us), s.oauth.C))
+	router.DELETE("/users/:user_id/2fa", auth.RequiresAuth(disableTwoFactor(us), s.oauth.C))
+	router.POST("/users/:user_id/2fa/reset", auth.RequiresAuth(resetTwoFactor(us), s.oauth.C))
+	router.PUT("/users/:user_id/2fa/required", auth.RequiresAuth(setTwoFactorRequired(us), s.oauth.C))
+	router.POST("/users/:user_id/tokens", auth.RequiresAuth(createAccessToken(us), s.oauth.C))
+	router.GET("/users/:user_id/tokens", auth.RequiresAuth(listAccessTokens(us), s.oauth.C))
+	router.DELETE("/users/:user_id/tokens/:token_id", auth.RequiresAuth(revokeAccessToken(us), s.oauth.C))
+
+	router.GET("/users/:user_id/addresses", s.requiresAuth(us, listAddresses(as), domain.ScopeAddressesRead))
+	router.POST("/users/:user_id/addresses", s.requiresAuth(us, createAddress(as), domain.ScopeAddressesWrite))
+	router.GET("/users/:user_id/addresses/:address_id", s.requiresAuth(us, getAddress(as), domain.ScopeAddressesRead))
+	router.PUT("/users/:user_id/addresses/:address_id", s.requiresAuth(us, updateAddress(as), domain.ScopeAddressesWrite))
+	router.DELETE("/users/:user_id/addresses/:address_id", s.requiresAuth(us, deleteAddress(as), domain.ScopeAddressesWrite))
+	router.PUT("/users/:user_id/addresses/:address_id/default", s.requiresAuth(us, setDefaultAddress(as), domain.ScopeAddressesWrite))
+	router.POST("/users/:user_id/addressbook/import", s.requiresAuth(us, importAddresses(as), domain.ScopeAddressesWrite))
+	router.GET("/users/:user_id/addressbook/export", s.requiresAuth(us, exportAddresses(as), domain.ScopeAddressesRead))
 
-	// Paymentoptions relatedendpoints ("/users/:user_id/paymentoptions...")
+	router.GET("/users/:user_id/paymentoptions", s.requiresAuth(us, listPaymentOptions(ps), domain.ScopePaymentOptionsRead))
+	router.POST("/users/:user_id/paymentoptions", s.requiresAuth(us, createPaymentOption(ps), domain.ScopePaymentOptionsWrite))
+	router.GET("/users/:user_id/paymentoptions/:payment_option_id", s.requiresAuth(us, getPaymentOption(ps), domain.ScopePaymentOptionsRead))
+	router.DELETE("/users/:user_id/paymentoptions/:payment_option_id", s.requiresAuth(us, deletePaymentOption(ps), domain.ScopePaymentOptionsWrite))
+	router.PUT("/users/:user_id/paymentoptions/:payment_option_id/default", s.requiresAuth(us, setDefaultPaymentOption(ps), domain.ScopePaymentOptionsWrite))
+	router.POST("/users/:user_id/paymentoptions/:payment_option_id/reveal", auth.RequiresAuth(revealPaymentOption(ps), s.oauth.C))
+
+	router.GET("/users/:user_id/defaults", s.requiresAuth(us, getDefaults(as, ps), domain.ScopeAddressesRead, domain.ScopePaymentOptionsRead))
 
 	// Serving docs
 	opts := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
@@ -50,9 +94,10 @@ func (s *Server) Handler(us ports.UsersService) *gin.Engine {
 
 // swagger:route POST /users users registerUsers
 // Registers a new user into the database
+// The account is pending until the email address is verified with the link sent to it
 // responses:
 // 	200: genericUser
-// 	400: genericError
+// 	400: validationError
 // 	500: genericError
 func registerUser(s ports.UsersService) gin.HandlerFunc {
 	type request struct {
@@ -128,9 +173,19 @@ func getUser(s ports.UsersService) gin.HandlerFunc {
 
 // swagger:route POST /users/login users loginUsers
 // Validates that the email and the passwords provided are valid for a registered user
+// Users that are pending verification, suspended or inactive are rejected with a 403 telling why
+// Accounts that went dormant after a long time without logins become active again
+// Failed logins are counted per account and per client address, clients failing repeatedly have to wait
+// longer between attempts (429) and accounts failing too often are locked for a while (423)
+// Users with two-factor authentication get a challenge (202) to complete with POST /users/login/2fa
+// Every attempt is added to the login history of the account, see GET /users/{user_id}/logins
 // responses:
 // 	200: genericUser
+// 	202: loginChallenge
 // 	400: genericError
+// 	403: genericError
+// 	423: genericError
+// 	429: genericError
 // 	500: genericError
 func login(s ports.UsersService) gin.HandlerFunc {
 	type request struct {
@@ -146,21 +201,181 @@ func login(s ports.UsersService) gin.HandlerFunc {
 			return
 		}
 
-		user, err := s.Login(loginRequest.Email, loginRequest.Password)
+		user, challenge, err := s.Login(loginRequest.Email, loginRequest.Password, clientInfo(c))
 		if err != nil {
 			c.JSON(err.Status(), err)
 			return
 		}
+		if challenge != nil {
+			c.JSON(http.StatusAccepted, challenge)
+			return
+		}
 		c.JSON(http.StatusOK, user)
 	}
 }
 
-// swagger:route PUT /users/{user_id} users updateUser
-// Validates that the email and the passwords provided are valid for a registered user
+// swagger:route POST /users/verify users verifyEmail
+// Verifies the email address of a user with the token sent to it and activates the account
+// Tokens are single use and expire after 24 hours
+// responses:
+// 	200: genericUser
+// 	400: genericError
+// 	409: genericError
+// 	500: genericError
+func verifyEmail(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Token string `json:"token"`
+	}
+
+	return func(c *gin.Context) {
+		var verifyRequest request
+		if err := c.ShouldBindJSON(&verifyRequest); err != nil || verifyRequest.Token == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		user, err := s.VerifyEmail(verifyRequest.Token)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// swagger:route POST /users/email/confirm users confirmEmailChange
+// Confirms the change of the email of a user with the token sent to the new address
+// Tokens are single use and expire after 24 hours
 // responses:
 // 	200: genericUser
 // 	400: genericError
+// 	409: genericError
+// 	500: genericError
+func confirmEmailChange(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Token string `json:"token"`
+	}
+
+	return func(c *gin.Context) {
+		var confirmRequest request
+		if err := c.ShouldBindJSON(&confirmRequest); err != nil || confirmRequest.Token == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		user, err := s.ConfirmEmailChange(confirmRequest.Token)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// swagger:route POST /users/verify/resend users resendVerification
+// Sends a new verification email, invalidating the previous ones
+// The response is the same whether the email is registered or not
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	500: genericError
+func resendVerification(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+	}
+
+	return func(c *gin.Context) {
+		var resendRequest request
+		if err := c.ShouldBindJSON(&resendRequest); err != nil || resendRequest.Email == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.ResendVerification(resendRequest.Email); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route POST /users/password/forgot users forgotPassword
+// Sends a password reset link to the email address of a user
+// The response is the same whether the email is registered or not
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	500: genericError
+func forgotPassword(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+	}
+
+	return func(c *gin.Context) {
+		var forgotRequest request
+		if err := c.ShouldBindJSON(&forgotRequest); err != nil || forgotRequest.Email == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.ForgotPassword(forgotRequest.Email); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route POST /users/password/reset users resetPassword
+// Sets a new password with the token of a password reset link and logs the user out everywhere
+// Their personal access tokens are revoked too
+// Tokens are single use and expire after an hour
+// responses:
+// 	204: noContent
+// 	400: validationError
+// 	500: genericError
+func resetPassword(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Token           string `json:"token"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirm_password"`
+	}
+
+	return func(c *gin.Context) {
+		var resetRequest request
+		if err := c.ShouldBindJSON(&resetRequest); err != nil || resetRequest.Token == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if resetRequest.Password != resetRequest.ConfirmPassword {
+			restErr := rest_errors.NewBadRequestError("passwords are not equal")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.ResetPassword(resetRequest.Token, resetRequest.Password); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route PUT /users/{user_id} users updateUser
+// Updates the profile of a user
+// A new email is only applied once confirmed with the link sent to it, until then it's returned as pending_email
+// Only admins can set the password, status and role this way
+// responses:
+// 	200: genericUser
+// 	400: validationError
 //  401: genericError
+// 	409: genericError
 // 	500: genericError
 func updateUser(s ports.UsersService) gin.HandlerFunc {
 	type request struct {
@@ -223,3 +438,268 @@ func updateUser(s ports.UsersService) gin.HandlerFunc {
 		c.JSON(http.StatusOK, user)
 	}
 }
+
+// swagger:route PUT /users/{user_id}/password users changePassword
+// Changes the password of a user, which must send the current one
+// Every session of the user is ended, so they have to log in again, and their personal access tokens are revoked
+// Wrong passwords count as failed logins, so they end up locking the account (423)
+// Only accessible by the owner
+// responses:
+// 	204: noContent
+// 	400: validationError
+// 	401: genericError
+// 	403: genericError
+// 	423: genericError
+// 	500: genericError
+func changePassword(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirm_password"`
+	}
+
+	return func(c *gin.Context) {
+		// admins don't know the current password, they set it through an update
+		userId, ok := authorizeOwner(c)
+		if !ok {
+			return
+		}
+
+		var passwordRequest request
+		if err := c.ShouldBindJSON(&passwordRequest); err != nil || passwordRequest.CurrentPassword == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if passwordRequest.Password != passwordRequest.ConfirmPassword {
+			restErr := rest_errors.NewBadRequestError("passwords are not equal")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.ChangePassword(userId, passwordRequest.CurrentPassword, passwordRequest.Password); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route DELETE /users/{user_id}/lock users unlockUser
+// Unlocks an account locked after too many failed logins and clears its failed logins
+// Only accessible by an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func unlockUser(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeAdmin(c)
+		if !ok {
+			return
+		}
+
+		if err := s.Unlock(userId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route GET /users/{user_id}/logins users listLogins
+// Lists the login attempts on an account, newest first, with the address and the user agent of the client
+// Pages are requested with limit (20 by default, at most 100) and before, the id of the last event of the previous page
+// Only accessible by the owner or an admin
+// responses:
+// 	200: loginEventList
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func listLogins(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		var before int64
+		if param := c.Query("before"); param != "" {
+			var parseErr error
+			if before, parseErr = strconv.ParseInt(param, 10, 64); parseErr != nil || before <= 0 {
+				restErr := rest_errors.NewBadRequestError("before must be a login event id")
+				c.JSON(restErr.Status(), restErr)
+				return
+			}
+		}
+		var limit int
+		if param := c.Query("limit"); param != "" {
+			var parseErr error
+			if limit, parseErr = strconv.Atoi(param); parseErr != nil || limit <= 0 {
+				restErr := rest_errors.NewBadRequestError("limit must be a positive number")
+				c.JSON(restErr.Status(), restErr)
+				return
+			}
+		}
+
+		events, err := s.ListLogins(userId, before, limit)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// swagger:route GET /users users listUsers
+// Lists users matching the given status, role, creation dates and prefix of the email or the name, along with how many match in total
+// Sorts on id, date_created, status or role, prefixed with - for descending order
+// Pages are requested with limit (20 by default, at most 100) and either offset or cursor, the next_cursor of the previous page
+// Filtering by prefix requires created_from or created_to and fails when more than 5000 users match the other filters
+// Purged users are only listed when asking for their status
+// Only accessible by an admin
+// responses:
+// 	200: userPage
+// 	400: genericError
+// 	401: genericError
+// 	500: genericError
+func listUsers(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+
+		filter := domain.UserFilter{
+			Status:      c.Query("status"),
+			Role:        c.Query("role"),
+			CreatedFrom: c.Query("created_from"),
+			CreatedTo:   c.Query("created_to"),
+			Prefix:      c.Query("prefix"),
+			Sort:        strings.TrimPrefix(c.Query("sort"), "-"),
+			Desc:        strings.HasPrefix(c.Query("sort"), "-"),
+		}
+		if param := c.Query("limit"); param != "" {
+			var parseErr error
+			if filter.Limit, parseErr = strconv.Atoi(param); parseErr != nil || filter.Limit <= 0 {
+				restErr := rest_errors.NewBadRequestError("limit must be a positive number")
+				c.JSON(restErr.Status(), restErr)
+				return
+			}
+		}
+		if param := c.Query("offset"); param != "" {
+			var parseErr error
+			if filter.Offset, parseErr = strconv.Atoi(param); parseErr != nil || filter.Offset < 0 {
+				restErr := rest_errors.NewBadRequestError("offset must be a positive number")
+				c.JSON(restErr.Status(), restErr)
+				return
+			}
+		}
+
+		page, err := s.ListUsers(filter, c.Query("cursor"))
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// swagger:route DELETE /users/{user_id} users deleteUser
+// Deletes a user, its data is purged after a grace period in which logging in again cancels the deletion
+// Its sessions are revoked right away
+// Only accessible by the owner or an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func deleteUser(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		if err := s.Delete(userId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// authorizeUser parses the user_id path param and checks that the
+// authenticated user is either its owner or an admin. On failure the error
+// response is already written and false is returned.
+func authorizeUser(c *gin.Context) (int64, bool) {
+	userId, userErr := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if userErr != nil {
+		restErr := rest_errors.NewBadRequestError("user id not valid")
+		c.JSON(restErr.Status(), restErr)
+		return 0, false
+	}
+
+	authorizedUser := c.MustGet("user_payload").(auth.UserPayload)
+	if authorizedUser.Id != userId && authorizedUser.Role != "admin" {
+		restErr := rest_errors.NewUnauthorizedError("you don't have the permissions to perform this action")
+		c.JSON(restErr.Status(), restErr)
+		return 0, false
+	}
+	return userId, true
+}
+
+// clientInfo describes the client of a request, for the login history.
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// authorizeOwner parses the user_id path param and checks that the
+// authenticated user is its owner, for actions not even admins can take on
+// behalf of a user.
+func authorizeOwner(c *gin.Context) (int64, bool) {
+	userId, ok := authorizeUser(c)
+	if !ok {
+		return 0, false
+	}
+	if c.MustGet("user_payload").(auth.UserPayload).Id != userId {
+		restErr := rest_errors.NewUnauthorizedError("you don't have the permissions to perform this action")
+		c.JSON(restErr.Status(), restErr)
+		return 0, false
+	}
+	return userId, true
+}
+
+// authorizeAdmin parses the user_id path param and checks that the
+// authenticated user is an admin, like authorizeUser does.
+func authorizeAdmin(c *gin.Context) (int64, bool) {
+	userId, userErr := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if userErr != nil {
+		restErr := rest_errors.NewBadRequestError("user id not valid")
+		c.JSON(restErr.Status(), restErr)
+		return 0, false
+	}
+
+	if !requireAdmin(c) {
+		return 0, false
+	}
+	return userId, true
+}
+
+// requireAdmin checks that the authenticated user is an admin, answering
+// the request when they aren't.
+func requireAdmin(c *gin.Context) bool {
+	if c.MustGet("user_payload").(auth.UserPayload).Role != "admin" {
+		restErr := rest_errors.NewUnauthorizedError("you don't have the permissions to perform this action")
+		c.JSON(restErr.Status(), restErr)
+		return false
+	}
+	return true
+}
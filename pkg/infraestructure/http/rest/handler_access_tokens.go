@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/auth"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/gin-gonic/gin"
+)
+
+// accessTokenRole is the role requests authenticated with a personal
+// access token act with, whatever the role of the user.
+const accessTokenRole = "user"
+
+// requiresAuth is auth.RequiresAuth that also accepts personal access tokens
+// granted every one of scopes. Tokens act as their user, never as an admin,
+// and routes that manage credentials don't accept them at all.
+func (s *Server) requiresAuth(us ports.UsersService, h gin.HandlerFunc, scopes ...string) gin.HandlerFunc {
+	oauth := auth.RequiresAuth(h, s.oauth.C)
+
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, domain.AccessTokenPrefix) {
+			oauth(c)
+			return
+		}
+
+		accessToken, err := us.AuthenticateAccessToken(token)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		for _, scope := range scopes {
+			if !accessToken.HasScope(scope) {
+				restErr := rest_errors.NewRestError(fmt.Sprintf("access token is missing the %s scope", scope), http.StatusForbidden, "insufficient_scope")
+				c.JSON(restErr.Status(), restErr)
+				return
+			}
+		}
+
+		c.Set("user_payload", auth.UserPayload{Id: accessToken.UserID, Role: accessTokenRole})
+		h(c)
+	}
+}
+
+// swagger:route POST /users/{user_id}/tokens accessTokens createAccessToken
+// Creates a personal access token for scripts and integrations, sent as a bearer token
+// The token is only returned this once, it can't be read again
+// Only accessible by the owner, and not with an access token
+// responses:
+// 	201: newAccessToken
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	409: genericError
+// 	500: genericError
+func createAccessToken(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	return func(c *gin.Context) {
+		userId, ok := authorizeOwner(c)
+		if !ok {
+			return
+		}
+
+		var createRequest request
+		if err := c.ShouldBindJSON(&createRequest); err != nil {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		token, err := s.CreateAccessToken(userId, createRequest.Name, createRequest.Scopes, createRequest.ExpiresInDays)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusCreated, token)
+	}
+}
+
+// swagger:route GET /users/{user_id}/tokens accessTokens listAccessTokens
+// Lists the personal access tokens of a user that weren't revoked, expired ones included
+// responses:
+// 	200: accessTokenList
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func listAccessTokens(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		tokens, err := s.ListAccessTokens(userId)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// swagger:route DELETE /users/{user_id}/tokens/{token_id} accessTokens revokeAccessToken
+// Revokes a personal access token, requests sent with it are rejected right away
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func revokeAccessToken(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		tokenId, parseErr := strconv.ParseInt(c.Param("token_id"), 10, 64)
+		if parseErr != nil {
+			restErr := rest_errors.NewBadRequestError("token id not valid")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.RevokeAccessToken(userId, tokenId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
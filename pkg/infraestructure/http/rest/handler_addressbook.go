@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_users-api/pkg/infraestructure/addressbook"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAddressBookSize limits the size of an uploaded address book in bytes.
+const maxAddressBookSize = 1 << 20
+
+type addressBookFormat struct {
+	contentType string
+	extension   string
+	decode      func(io.Reader) ([]domain.AddressImportRow, error)
+	encode      func(io.Writer, []domain.ShippingAddress) error
+}
+
+var addressBookFormats = map[string]addressBookFormat{
+	"csv": {
+		contentType: "text/csv",
+		extension:   "csv",
+		decode:      addressbook.DecodeCSV,
+		encode:      addressbook.EncodeCSV,
+	},
+	"vcard": {
+		contentType: "text/vcard",
+		extension:   "vcf",
+		decode:      addressbook.DecodeVCard,
+		encode:      addressbook.EncodeVCard,
+	},
+}
+
+// parseAddressBookFormat resolves the format query param, falling back to
+// the content type of the request body and then to csv.
+func parseAddressBookFormat(c *gin.Context) (addressBookFormat, bool) {
+	name := c.Query("format")
+	if name == "" {
+		switch mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); mediaType {
+		case "text/vcard", "text/x-vcard":
+			name = "vcard"
+		default:
+			name = "csv"
+		}
+	}
+
+	format, ok := addressBookFormats[name]
+	if !ok {
+		restErr := rest_errors.NewBadRequestError("format not valid, expected csv or vcard")
+		c.JSON(restErr.Status(), restErr)
+		return addressBookFormat{}, false
+	}
+	return format, true
+}
+
+// swagger:route POST /users/{user_id}/addressbook/import addresses importAddresses
+// Imports the shipping addresses of a csv or vcard address book sent as request body
+// Each row is validated on its own and reported back, use dry_run to only validate them
+// Only accessible by the owner or an admin
+// responses:
+// 	200: addressImportReport
+// 	400: genericError
+// 	401: genericError
+// 	500: genericError
+func importAddresses(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		format, ok := parseAddressBookFormat(c)
+		if !ok {
+			return
+		}
+		dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+		if err != nil {
+			restErr := rest_errors.NewBadRequestError("dry_run not valid")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		rows, err := format.decode(http.MaxBytesReader(c.Writer, c.Request.Body, maxAddressBookSize))
+		if err != nil {
+			restErr := rest_errors.NewBadRequestError("invalid address book: " + err.Error())
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		report, restErr := s.ImportAddresses(userId, rows, dryRun)
+		if restErr != nil {
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// swagger:route GET /users/{user_id}/addressbook/export addresses exportAddresses
+// Exports the shipping addresses of a user as a csv or vcard address book
+// Only accessible by the owner or an admin
+// responses:
+// 	200: addressBook
+// 	400: genericError
+// 	401: genericError
+// 	500: genericError
+func exportAddresses(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		format, ok := parseAddressBookFormat(c)
+		if !ok {
+			return
+		}
+
+		addresses, restErr := s.ListAddresses(userId)
+		if restErr != nil {
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		var b bytes.Buffer
+		if err := format.encode(&b, addresses); err != nil {
+			restErr := rest_errors.NewInternalServerError("error while trying to export addresses, try again later")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=addresses."+format.extension)
+		c.Data(http.StatusOK, format.contentType+"; charset=utf-8", b.Bytes())
+	}
+}
@@ -0,0 +1,226 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/gin-gonic/gin"
+)
+
+type addressRequest struct {
+	EmailInvoice string `json:"email_invoice"`
+	FullName     string `json:"full_name"`
+	AddressLine1 string `json:"address_line1"`
+	AddressLine2 string `json:"address_line2"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	PostCode     string `json:"post_code"`
+	Country      string `json:"country"`
+	IsDefault    bool   `json:"is_default"`
+}
+
+func (r addressRequest) toDomain(userId int64) domain.ShippingAddress {
+	return domain.ShippingAddress{
+		UserId:       userId,
+		EmailInvoice: r.EmailInvoice,
+		FullName:     r.FullName,
+		AddressLine1: r.AddressLine1,
+		AddressLine2: r.AddressLine2,
+		City:         r.City,
+		State:        r.State,
+		PostCode:     r.PostCode,
+		Country:      r.Country,
+		IsDefault:    r.IsDefault,
+	}
+}
+
+func parseAddressId(c *gin.Context) (int64, bool) {
+	addressId, err := strconv.ParseInt(c.Param("address_id"), 10, 64)
+	if err != nil {
+		restErr := rest_errors.NewBadRequestError("address id not valid")
+		c.JSON(restErr.Status(), restErr)
+		return 0, false
+	}
+	return addressId, true
+}
+
+// swagger:route GET /users/{user_id}/addresses addresses listAddresses
+// Lists the shipping addresses of a user
+// Only accessible by the owner or an admin
+// responses:
+// 	200: addressList
+// 	400: genericError
+// 	401: genericError
+// 	500: genericError
+func listAddresses(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		addresses, err := s.ListAddresses(userId)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, addresses)
+	}
+}
+
+// swagger:route GET /users/{user_id}/addresses/{address_id} addresses getAddress
+// Returns a single shipping address of a user
+// Only accessible by the owner or an admin
+// responses:
+// 	200: genericAddress
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func getAddress(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		addressId, ok := parseAddressId(c)
+		if !ok {
+			return
+		}
+
+		address, err := s.GetAddress(userId, addressId)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, address)
+	}
+}
+
+// swagger:route POST /users/{user_id}/addresses addresses createAddress
+// Adds a shipping address to the address book of a user
+// Only accessible by the owner or an admin
+// responses:
+// 	201: genericAddress
+// 	400: validationError
+// 	401: genericError
+// 	500: genericError
+func createAddress(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		var addressReq addressRequest
+		if err := c.ShouldBindJSON(&addressReq); err != nil {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		address := addressReq.toDomain(userId)
+		if err := s.CreateAddress(&address); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusCreated, address)
+	}
+}
+
+// swagger:route PUT /users/{user_id}/addresses/{address_id} addresses updateAddress
+// Updates a shipping address, empty fields keep their current value
+// Only accessible by the owner or an admin
+// responses:
+// 	200: genericAddress
+// 	400: validationError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func updateAddress(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		addressId, ok := parseAddressId(c)
+		if !ok {
+			return
+		}
+
+		var addressReq addressRequest
+		if err := c.ShouldBindJSON(&addressReq); err != nil {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		address := addressReq.toDomain(userId)
+		address.Id = addressId
+		if err := s.UpdateAddress(&address); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, address)
+	}
+}
+
+// swagger:route DELETE /users/{user_id}/addresses/{address_id} addresses deleteAddress
+// Removes a shipping address from the address book of a user
+// Only accessible by the owner or an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	409: genericError
+// 	500: genericError
+func deleteAddress(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		addressId, ok := parseAddressId(c)
+		if !ok {
+			return
+		}
+
+		if err := s.DeleteAddress(userId, addressId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route PUT /users/{user_id}/addresses/{address_id}/default addresses setDefaultAddress
+// Marks a shipping address as the default one of the user
+// Only accessible by the owner or an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func setDefaultAddress(s ports.ShippingAddressService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		addressId, ok := parseAddressId(c)
+		if !ok {
+			return
+		}
+
+		if err := s.SetDefaultAddress(userId, addressId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
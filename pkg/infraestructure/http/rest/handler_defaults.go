@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/gin-gonic/gin"
+)
+
+type defaultsResponse struct {
+	ShippingAddress *domain.ShippingAddress `json:"shipping_address"`
+	PaymentOption   *domain.PaymentOption   `json:"payment_option"`
+}
+
+// swagger:route GET /users/{user_id}/defaults defaults getDefaults
+// Returns the default shipping address and payment option of a user, null when not set
+// Only accessible by the owner or an admin
+// responses:
+// 	200: genericDefaults
+// 	400: genericError
+// 	401: genericError
+// 	500: genericError
+func getDefaults(as ports.ShippingAddressService, ps ports.PaymentOptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		var response defaultsResponse
+
+		address, err := as.GetDefaultAddress(userId)
+		if err != nil && err.Status() != http.StatusNotFound {
+			c.JSON(err.Status(), err)
+			return
+		}
+		response.ShippingAddress = address
+
+		po, err := ps.GetDefaultPaymentOption(userId)
+		if err != nil && err.Status() != http.StatusNotFound {
+			c.JSON(err.Status(), err)
+			return
+		}
+		response.PaymentOption = po
+
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -2,6 +2,7 @@ package rest
 
 import (
 	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/validation"
 	"github.com/FacuBar/bookstore_utils-go/rest_errors"
 )
 
@@ -17,7 +18,18 @@ type genericErrorResponse struct {
 	Body rest_errors.RestErr
 }
 
-// swagger:parameters listUser
+// swagger:response validationError
+type validationErrorResponse struct {
+	// in: body
+	Body struct {
+		Message string                  `json:"message"`
+		Status  int                     `json:"status"`
+		Error   string                  `json:"error"`
+		Fields  []validation.FieldError `json:"fields"`
+	}
+}
+
+// swagger:parameters listUser deleteUser changePassword unlockUser listLogins enrollTwoFactor confirmTwoFactor disableTwoFactor resetTwoFactor setTwoFactorRequired createAccessToken listAccessTokens revokeAccessToken
 type userIDParamsWrapper struct {
 	// in: path
 	// required: true
@@ -25,7 +37,7 @@ type userIDParamsWrapper struct {
 	ID int `json:"user_id"`
 }
 
-// swagger:parameters listUser updateUser
+// swagger:parameters listUser updateUser deleteUser changePassword unlockUser listLogins enrollTwoFactor confirmTwoFactor disableTwoFactor resetTwoFactor setTwoFactorRequired createAccessToken listAccessTokens revokeAccessToken listUsers
 type authHeaderWrapper struct {
 	// in: header
 	// example: "Bearer {auth_token}"
@@ -71,14 +83,108 @@ type requestLoginWrapper struct {
 	Body requestLoginUser
 }
 
+type requestVerifyEmail struct {
+	// Token received in the verification email
+	// required : true
+	Token string `json:"token"`
+}
+
+// swagger:parameters verifyEmail
+type requestVerifyEmailWrapper struct {
+	// in: body
+	Body requestVerifyEmail
+}
+
+type requestMagicLink struct {
+	// example : user1@email.com
+	// required : true
+	Email string `json:"email"`
+}
+
+// swagger:parameters sendMagicLink
+type requestMagicLinkWrapper struct {
+	// in: body
+	Body requestMagicLink
+}
+
+type requestLoginWithMagicLink struct {
+	// Token received in the login link
+	// required : true
+	Token string `json:"token"`
+}
+
+// swagger:parameters loginWithMagicLink
+type requestLoginWithMagicLinkWrapper struct {
+	// in: body
+	Body requestLoginWithMagicLink
+}
+
+type requestConfirmEmailChange struct {
+	// Token received at the new address
+	// required : true
+	Token string `json:"token"`
+}
+
+// swagger:parameters confirmEmailChange
+type requestConfirmEmailChangeWrapper struct {
+	// in: body
+	Body requestConfirmEmailChange
+}
+
+type requestResendVerification struct {
+	// example : user1@email.com
+	// required : true
+	Email string `json:"email"`
+}
+
+// swagger:parameters resendVerification
+type requestResendVerificationWrapper struct {
+	// in: body
+	Body requestResendVerification
+}
+
+type requestForgotPassword struct {
+	// example : user1@email.com
+	// required : true
+	Email string `json:"email"`
+}
+
+// swagger:parameters forgotPassword
+type requestForgotPasswordWrapper struct {
+	// in: body
+	Body requestForgotPassword
+}
+
+type requestResetPassword struct {
+	// Token received in the password reset email
+	// required : true
+	Token string `json:"token"`
+	// required : true
+	// example : somepassword
+	Password string `json:"password"`
+	// required : true
+	// example : somepassword
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+// swagger:parameters resetPassword
+type requestResetPasswordWrapper struct {
+	// in: body
+	Body requestResetPassword
+}
+
 type requestUpdateUser struct {
-	FirstName       string `json:"first_name"`
-	LastName        string `json:"last_name"`
-	Email           string `json:"email"`
-	Password        string `json:"password"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	// Only admins, users change it through PUT /users/{user_id}/password
+	Password string `json:"password"`
+	// Only admins
 	ConfirmPassword string `json:"confirm_password"`
-	Status          string `json:"status"`
-	Role            string `json:"role"`
+	// Only admins
+	Status string `json:"status"`
+	// Only admins
+	Role string `json:"role"`
 }
 
 // swagger:parameters updateUser
@@ -86,3 +192,417 @@ type requestUpdateWrapper struct {
 	// in: body
 	Body requestUpdateUser
 }
+
+type requestChangePassword struct {
+	// required : true
+	// example : somepassword
+	CurrentPassword string `json:"current_password"`
+	// required : true
+	// example : someotherpassword
+	Password string `json:"password"`
+	// required : true
+	// example : someotherpassword
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+// swagger:parameters changePassword
+type requestChangePasswordWrapper struct {
+	// in: body
+	Body requestChangePassword
+}
+
+// swagger:response loginChallenge
+type loginChallengeResponse struct {
+	// in: body
+	Body domain.LoginChallenge
+}
+
+// swagger:response twoFactorSecret
+type twoFactorSecretResponse struct {
+	// in: body
+	Body domain.TwoFactorSecret
+}
+
+// swagger:response twoFactorEnrollment
+type twoFactorEnrollmentResponse struct {
+	// in: body
+	Body domain.TwoFactorEnrollment
+}
+
+type requestVerifyLogin struct {
+	// Challenge returned by POST /users/login
+	// required : true
+	Challenge string `json:"challenge"`
+	// Code of the authenticator app or a recovery code
+	// required : true
+	// example : 123456
+	Code string `json:"code"`
+}
+
+// swagger:parameters verifyLogin confirmWithChallenge
+type requestVerifyLoginWrapper struct {
+	// in: body
+	Body requestVerifyLogin
+}
+
+type requestEnrollWithChallenge struct {
+	// Challenge returned by POST /users/login
+	// required : true
+	Challenge string `json:"challenge"`
+}
+
+// swagger:parameters enrollWithChallenge
+type requestEnrollWithChallengeWrapper struct {
+	// in: body
+	Body requestEnrollWithChallenge
+}
+
+type requestConfirmTwoFactor struct {
+	// Code of the authenticator app
+	// required : true
+	// example : 123456
+	Code string `json:"code"`
+}
+
+// swagger:parameters confirmTwoFactor
+type requestConfirmTwoFactorWrapper struct {
+	// in: body
+	Body requestConfirmTwoFactor
+}
+
+type requestDisableTwoFactor struct {
+	// required : true
+	// example : somepassword
+	Password string `json:"password"`
+}
+
+// swagger:parameters disableTwoFactor
+type requestDisableTwoFactorWrapper struct {
+	// in: body
+	Body requestDisableTwoFactor
+}
+
+type requestSetTwoFactorRequired struct {
+	// required : true
+	Required bool `json:"required"`
+}
+
+// swagger:parameters setTwoFactorRequired
+type requestSetTwoFactorRequiredWrapper struct {
+	// in: body
+	Body requestSetTwoFactorRequired
+}
+
+// swagger:response loginEventList
+type loginEventListResponse struct {
+	// in: body
+	Body []domain.LoginEvent
+}
+
+// swagger:parameters listLogins
+type listLoginsParamsWrapper struct {
+	// Maximum number of events, 20 by default
+	// in: query
+	// minimum : 1
+	// maximum : 100
+	Limit int `json:"limit"`
+	// Id of the last event of the previous page
+	// in: query
+	// minimum : 1
+	Before int64 `json:"before"`
+}
+
+// swagger:response userPage
+type userPageResponse struct {
+	// in: body
+	Body domain.UserPage
+}
+
+// swagger:parameters listUsers
+type listUsersParamsWrapper struct {
+	// in: query
+	// enum: pending_verification,active,suspended,inactive,dormant,deleted,purged
+	Status string `json:"status"`
+	// in: query
+	// example: admin
+	Role string `json:"role"`
+	// First day of creation, included
+	// in: query
+	// example: 2021-01-01
+	CreatedFrom string `json:"created_from"`
+	// Last day of creation, included
+	// in: query
+	// example: 2021-12-31
+	CreatedTo string `json:"created_to"`
+	// Start of the email, first name or last name, ignoring case, only
+	// along with created_from or created_to
+	// in: query
+	// example: osc
+	Prefix string `json:"prefix"`
+	// Field to sort on, prefixed with - for descending order, id by default
+	// in: query
+	// enum: id,-id,date_created,-date_created,status,-status,role,-role
+	Sort string `json:"sort"`
+	// Maximum number of users, 20 by default
+	// in: query
+	// minimum : 1
+	// maximum : 100
+	Limit int `json:"limit"`
+	// Number of users to skip, can't be used along a cursor
+	// in: query
+	// minimum : 0
+	Offset int `json:"offset"`
+	// next_cursor of the previous page
+	// in: query
+	Cursor string `json:"cursor"`
+}
+
+// swagger:response newAccessToken
+type newAccessTokenResponse struct {
+	// in: body
+	Body domain.NewAccessToken
+}
+
+// swagger:response accessTokenList
+type accessTokenListResponse struct {
+	// in: body
+	Body []domain.AccessToken
+}
+
+type requestCreateAccessToken struct {
+	// required : true
+	// example : deploy script
+	Name string `json:"name"`
+	// Any of users:read, addresses:read, addresses:write, payment_options:read and payment_options:write
+	// required : true
+	// example : ["addresses:read"]
+	Scopes []string `json:"scopes"`
+	// Days until the token expires, 90 by default
+	// minimum : 1
+	// maximum : 365
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+// swagger:parameters createAccessToken
+type requestCreateAccessTokenWrapper struct {
+	// in: body
+	Body requestCreateAccessToken
+}
+
+// swagger:parameters revokeAccessToken
+type accessTokenIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	ID int `json:"token_id"`
+}
+
+// swagger:response genericAddress
+type genericAddressResponse struct {
+	// in: body
+	Body domain.ShippingAddress
+}
+
+// swagger:response addressList
+type addressListResponse struct {
+	// in: body
+	Body []domain.ShippingAddress
+}
+
+// swagger:response noContent
+type noContentResponse struct{}
+
+// swagger:parameters listAddresses getAddress createAddress updateAddress deleteAddress
+type addressUserIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	ID int `json:"user_id"`
+	// in: header
+	// example: "Bearer {auth_token}"
+	Authorization string `json:"Authorization"`
+}
+
+// swagger:response addressImportReport
+type addressImportReportResponse struct {
+	// in: body
+	Body domain.AddressImportReport
+}
+
+// swagger:response addressBook
+type addressBookResponse struct {
+	// Address book in the requested format
+	// in: body
+	Body string
+}
+
+// swagger:parameters importAddresses exportAddresses
+type addressBookParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	ID int `json:"user_id"`
+	// in: header
+	// example: "Bearer {auth_token}"
+	Authorization string `json:"Authorization"`
+	// Defaults to the content type of the body, or csv
+	// in: query
+	// enum: csv,vcard
+	Format string `json:"format"`
+}
+
+// swagger:parameters importAddresses
+type requestAddressBookWrapper struct {
+	// Validate the address book without importing it
+	// in: query
+	DryRun bool `json:"dry_run"`
+	// CSV with a header row naming the columns email_invoice, full_name, address_line1,
+	// address_line2, city, state, post_code and country, or vCards with FN, EMAIL and ADR
+	// in: body
+	Body string
+}
+
+// swagger:parameters getAddress updateAddress deleteAddress
+type addressIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	AddressID int `json:"address_id"`
+}
+
+type requestAddress struct {
+	// example : oscaac@email.com
+	EmailInvoice string `json:"email_invoice"`
+	// example : Oscar Isaac
+	FullName string `json:"full_name"`
+	// example : 742 Evergreen Terrace
+	AddressLine1 string `json:"address_line1"`
+	AddressLine2 string `json:"address_line2"`
+	// example : Springfield
+	City  string `json:"city"`
+	State string `json:"state"`
+	// example : 97403
+	PostCode string `json:"post_code"`
+	// example : US
+	Country string `json:"country"`
+}
+
+// swagger:parameters createAddress updateAddress
+type requestAddressWrapper struct {
+	// in: body
+	Body requestAddress
+}
+
+// swagger:response genericPaymentOption
+type genericPaymentOptionResponse struct {
+	// in: body
+	Body domain.PaymentOption
+}
+
+// swagger:response paymentOptionList
+type paymentOptionListResponse struct {
+	// in: body
+	Body []domain.PaymentOption
+}
+
+// swagger:parameters listPaymentOptions getPaymentOption createPaymentOption deletePaymentOption revealPaymentOption
+type paymentOptionUserIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	ID int `json:"user_id"`
+	// in: header
+	// example: "Bearer {auth_token}"
+	Authorization string `json:"Authorization"`
+}
+
+// swagger:parameters getPaymentOption deletePaymentOption revealPaymentOption
+type paymentOptionIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	PaymentOptionID int `json:"payment_option_id"`
+}
+
+type requestPaymentOption struct {
+	// required : true
+	// example : 4242424242424242
+	CardNumber string `json:"card_number"`
+	// required : true
+	// example : 12
+	ExpiryMonth int `json:"expiry_month"`
+	// required : true
+	// example : 2030
+	ExpiryYear int `json:"expiry_year"`
+	// required : true
+	// example : Oscar Isaac
+	NameOnCard string `json:"name_on_card"`
+	// required : true
+	// example : 123
+	CVV string `json:"cvv"`
+	// Id of an address book entry to use as billing address
+	// example : 1
+	BillingAddressID int64 `json:"billing_address_id"`
+	// Use the default shipping address of the user as billing address
+	BillingSameAsShipping bool `json:"billing_same_as_shipping"`
+	// Billing address stored only for this payment option
+	BillingAddress *requestAddress `json:"billing_address"`
+}
+
+// swagger:parameters createPaymentOption
+type requestPaymentOptionWrapper struct {
+	// in: body
+	Body requestPaymentOption
+}
+
+// swagger:response paymentCardDetails
+type paymentCardDetailsResponse struct {
+	// in: body
+	Body domain.PaymentCardDetails
+}
+
+type requestRevealPaymentOption struct {
+	// Current password of the user
+	// required : true
+	// example : somepassword
+	Password string `json:"password"`
+}
+
+// swagger:parameters revealPaymentOption
+type requestRevealPaymentOptionWrapper struct {
+	// in: body
+	Body requestRevealPaymentOption
+}
+
+// swagger:response genericDefaults
+type genericDefaultsResponse struct {
+	// in: body
+	Body defaultsResponse
+}
+
+// swagger:parameters setDefaultAddress setDefaultPaymentOption getDefaults
+type defaultsUserIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	ID int `json:"user_id"`
+	// in: header
+	// example: "Bearer {auth_token}"
+	Authorization string `json:"Authorization"`
+}
+
+// swagger:parameters setDefaultAddress
+type defaultAddressIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	AddressID int `json:"address_id"`
+}
+
+// swagger:parameters setDefaultPaymentOption
+type defaultPaymentOptionIDParamsWrapper struct {
+	// in: path
+	// required: true
+	// minimum : 1
+	PaymentOptionID int `json:"payment_option_id"`
+}
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// magicLinkCookie holds the nonce tying a login link to the browser that
+	// asked for it. It's only sent back to the magic link routes.
+	magicLinkCookie     = "magic_link_nonce"
+	magicLinkCookiePath = "/users/login/magic"
+)
+
+// setMagicLinkCookie stores nonce in the browser of the client, a negative
+// maxAge deleting it.
+func setMagicLinkCookie(c *gin.Context, nonce string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    nonce,
+		Path:     magicLinkCookiePath,
+		MaxAge:   maxAge,
+		Secure:   c.Request.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// swagger:route POST /users/login/magic users sendMagicLink
+// Sends a single-use login link to the email address of a user, as an alternative to the password
+// The link only works in the browser that asked for it, which gets a cookie to send back with it
+// Each email can only ask for a few links an hour (429)
+// The response is the same whether the email is registered or not
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	429: genericError
+// 	500: genericError
+func sendMagicLink(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+	}
+
+	return func(c *gin.Context) {
+		var magicLinkRequest request
+		if err := c.ShouldBindJSON(&magicLinkRequest); err != nil || magicLinkRequest.Email == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		nonce, err := s.RequestMagicLink(magicLinkRequest.Email)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		setMagicLinkCookie(c, nonce, 0)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route POST /users/login/magic/verify users loginWithMagicLink
+// Logs a user in with the token of a login link, from the browser that asked for it
+// Links are single use and expire after 15 minutes
+// Users with two-factor authentication get a challenge (202) to complete with POST /users/login/2fa
+// responses:
+// 	200: genericUser
+// 	202: loginChallenge
+// 	400: genericError
+// 	403: genericError
+// 	500: genericError
+func loginWithMagicLink(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Token string `json:"token"`
+	}
+
+	return func(c *gin.Context) {
+		var loginRequest request
+		if err := c.ShouldBindJSON(&loginRequest); err != nil || loginRequest.Token == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+		nonce, cookieErr := c.Cookie(magicLinkCookie)
+		if cookieErr != nil || nonce == "" {
+			restErr := rest_errors.NewBadRequestError("invalid or expired token")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		user, challenge, err := s.LoginWithMagicLink(loginRequest.Token, nonce, clientInfo(c))
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		setMagicLinkCookie(c, "", -1)
+		if challenge != nil {
+			c.JSON(http.StatusAccepted, challenge)
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
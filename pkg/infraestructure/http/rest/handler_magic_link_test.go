@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMagicLink(t *testing.T) {
+	funcRequestMagicLink = func(email string) (string, rest_errors.RestErr) {
+		return "nonce", nil
+	}
+	funcLoginMagicLink = func(token, nonce string, client domain.ClientInfo) (*domain.User, *domain.LoginChallenge, rest_errors.RestErr) {
+		if token != "abc" || nonce != "nonce" {
+			return nil, nil, rest_errors.NewBadRequestError("invalid or expired token")
+		}
+		return &domain.User{Id: 1}, nil, nil
+	}
+
+	server := newTestServer()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/users/login/magic", strings.NewReader(`{"email":"oscaac@gmail.com"}`))
+	server.srv.Handler.ServeHTTP(w, req)
+
+	assert.EqualValues(t, http.StatusNoContent, w.Code)
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	assert.EqualValues(t, magicLinkCookie, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	t.Run("NoError", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users/login/magic/verify", strings.NewReader(`{"token":"abc"}`))
+		req.AddCookie(cookies[0])
+		server.srv.Handler.ServeHTTP(w, req)
+
+		assert.EqualValues(t, http.StatusOK, w.Code)
+		// the nonce is dropped once used
+		if assert.Len(t, w.Result().Cookies(), 1) {
+			assert.True(t, w.Result().Cookies()[0].MaxAge < 0)
+		}
+	})
+
+	t.Run("NoCookie", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users/login/magic/verify", strings.NewReader(`{"token":"abc"}`))
+		server.srv.Handler.ServeHTTP(w, req)
+
+		assert.EqualValues(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,234 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/gin-gonic/gin"
+)
+
+func parsePaymentOptionId(c *gin.Context) (int64, bool) {
+	poId, err := strconv.ParseInt(c.Param("payment_option_id"), 10, 64)
+	if err != nil {
+		restErr := rest_errors.NewBadRequestError("payment option id not valid")
+		c.JSON(restErr.Status(), restErr)
+		return 0, false
+	}
+	return poId, true
+}
+
+// swagger:route GET /users/{user_id}/paymentoptions paymentOptions listPaymentOptions
+// Lists the payment options of a user, card numbers are never returned
+// Only accessible by the owner or an admin
+// responses:
+// 	200: paymentOptionList
+// 	400: genericError
+// 	401: genericError
+// 	500: genericError
+func listPaymentOptions(s ports.PaymentOptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		options, err := s.ListPaymentOptions(userId)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, options)
+	}
+}
+
+// swagger:route GET /users/{user_id}/paymentoptions/{payment_option_id} paymentOptions getPaymentOption
+// Returns a single payment option of a user
+// Only accessible by the owner or an admin
+// responses:
+// 	200: genericPaymentOption
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func getPaymentOption(s ports.PaymentOptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		poId, ok := parsePaymentOptionId(c)
+		if !ok {
+			return
+		}
+
+		po, err := s.GetPaymentOption(userId, poId)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, po)
+	}
+}
+
+// swagger:route POST /users/{user_id}/paymentoptions paymentOptions createPaymentOption
+// Stores a new card, the number is exchanged for a vault token and the cvv is discarded
+// Only accessible by the owner or an admin
+// responses:
+// 	201: genericPaymentOption
+// 	400: validationError
+// 	401: genericError
+// 	500: genericError
+func createPaymentOption(s ports.PaymentOptionService) gin.HandlerFunc {
+	type request struct {
+		CardNumber  string `json:"card_number"`
+		ExpiryMonth int    `json:"expiry_month"`
+		ExpiryYear  int    `json:"expiry_year"`
+		NameOnCard  string `json:"name_on_card"`
+		CVV         string `json:"cvv"`
+		IsDefault   bool   `json:"is_default"`
+
+		BillingAddressID      int64           `json:"billing_address_id"`
+		BillingSameAsShipping bool            `json:"billing_same_as_shipping"`
+		BillingAddress        *addressRequest `json:"billing_address"`
+	}
+
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+
+		var poRequest request
+		if err := c.ShouldBindJSON(&poRequest); err != nil {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		po := domain.PaymentOption{
+			UserID:      userId,
+			CardNumber:  poRequest.CardNumber,
+			ExpiryMonth: poRequest.ExpiryMonth,
+			ExpiryYear:  poRequest.ExpiryYear,
+			NameOnCard:  poRequest.NameOnCard,
+			CVV:         poRequest.CVV,
+			IsDefault:   poRequest.IsDefault,
+
+			BillingAddressID:      poRequest.BillingAddressID,
+			BillingSameAsShipping: poRequest.BillingSameAsShipping,
+		}
+		if poRequest.BillingAddress != nil {
+			billingAddress := poRequest.BillingAddress.toDomain(userId)
+			po.BillingAddress = &billingAddress
+		}
+
+		if err := s.CreatePaymentOption(&po); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusCreated, po)
+	}
+}
+
+// swagger:route POST /users/{user_id}/paymentoptions/{payment_option_id}/reveal paymentOptions revealPaymentOption
+// Returns the full card number of a payment option after the user re-enters their password
+// Wrong passwords count as failed logins, so they end up locking the account (423)
+// Only accessible by the owner, every attempt is logged
+// responses:
+// 	200: paymentCardDetails
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	423: genericError
+// 	500: genericError
+func revealPaymentOption(s ports.PaymentOptionService) gin.HandlerFunc {
+	type request struct {
+		Password string `json:"password"`
+	}
+
+	return func(c *gin.Context) {
+		// admins can manage payment options but never see full card numbers
+		userId, ok := authorizeOwner(c)
+		if !ok {
+			return
+		}
+		poId, ok := parsePaymentOptionId(c)
+		if !ok {
+			return
+		}
+
+		var revealRequest request
+		if err := c.ShouldBindJSON(&revealRequest); err != nil || revealRequest.Password == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		details, err := s.RevealPaymentOption(userId, poId, revealRequest.Password)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, details)
+	}
+}
+
+// swagger:route DELETE /users/{user_id}/paymentoptions/{payment_option_id} paymentOptions deletePaymentOption
+// Removes a payment option and its vault token
+// Only accessible by the owner or an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func deletePaymentOption(s ports.PaymentOptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		poId, ok := parsePaymentOptionId(c)
+		if !ok {
+			return
+		}
+
+		if err := s.DeletePaymentOption(userId, poId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route PUT /users/{user_id}/paymentoptions/{payment_option_id}/default paymentOptions setDefaultPaymentOption
+// Marks a payment option as the default one of the user
+// Only accessible by the owner or an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func setDefaultPaymentOption(s ports.PaymentOptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeUser(c)
+		if !ok {
+			return
+		}
+		poId, ok := parsePaymentOptionId(c)
+		if !ok {
+			return
+		}
+
+		if err := s.SetDefaultPaymentOption(userId, poId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,269 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+	"github.com/gin-gonic/gin"
+)
+
+// swagger:route POST /users/login/2fa users verifyLogin
+// Completes a login challenge with a code of the authenticator app or a recovery code
+// Challenges are single use and expire after 5 minutes, too many wrong codes drop them
+// responses:
+// 	200: genericUser
+// 	400: genericError
+// 	403: genericError
+// 	429: genericError
+// 	500: genericError
+func verifyLogin(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+
+	return func(c *gin.Context) {
+		var verifyRequest request
+		if err := c.ShouldBindJSON(&verifyRequest); err != nil || verifyRequest.Challenge == "" || verifyRequest.Code == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		user, err := s.VerifyLogin(verifyRequest.Challenge, verifyRequest.Code, clientInfo(c))
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// swagger:route POST /users/login/2fa/enroll users enrollWithChallenge
+// Starts the two-factor enrollment of a user whose account requires it, with the challenge of their login
+// responses:
+// 	200: twoFactorSecret
+// 	400: genericError
+// 	409: genericError
+// 	500: genericError
+func enrollWithChallenge(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Challenge string `json:"challenge"`
+	}
+
+	return func(c *gin.Context) {
+		var enrollRequest request
+		if err := c.ShouldBindJSON(&enrollRequest); err != nil || enrollRequest.Challenge == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		secret, err := s.EnrollWithChallenge(enrollRequest.Challenge)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, secret)
+	}
+}
+
+// swagger:route POST /users/login/2fa/enroll/confirm users confirmWithChallenge
+// Confirms a two-factor enrollment started while logging in with a first code, which completes the login
+// The recovery codes are only returned this once
+// responses:
+// 	200: twoFactorEnrollment
+// 	400: genericError
+// 	403: genericError
+// 	409: genericError
+// 	429: genericError
+// 	500: genericError
+func confirmWithChallenge(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+
+	return func(c *gin.Context) {
+		var confirmRequest request
+		if err := c.ShouldBindJSON(&confirmRequest); err != nil || confirmRequest.Challenge == "" || confirmRequest.Code == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		enrollment, err := s.ConfirmWithChallenge(confirmRequest.Challenge, confirmRequest.Code, clientInfo(c))
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// swagger:route POST /users/{user_id}/2fa users enrollTwoFactor
+// Starts the two-factor enrollment of a user, returning the secret to add to an authenticator app
+// Only accessible by the owner
+// responses:
+// 	200: twoFactorSecret
+// 	400: genericError
+// 	401: genericError
+// 	409: genericError
+// 	500: genericError
+func enrollTwoFactor(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeOwner(c)
+		if !ok {
+			return
+		}
+
+		secret, err := s.EnrollTwoFactor(userId)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, secret)
+	}
+}
+
+// swagger:route POST /users/{user_id}/2fa/confirm users confirmTwoFactor
+// Enables two-factor authentication once the user sends a first code of the new secret
+// The recovery codes are only returned this once
+// Only accessible by the owner
+// responses:
+// 	200: twoFactorEnrollment
+// 	400: genericError
+// 	401: genericError
+// 	409: genericError
+// 	429: genericError
+// 	500: genericError
+func confirmTwoFactor(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Code string `json:"code"`
+	}
+
+	return func(c *gin.Context) {
+		userId, ok := authorizeOwner(c)
+		if !ok {
+			return
+		}
+
+		var confirmRequest request
+		if err := c.ShouldBindJSON(&confirmRequest); err != nil || confirmRequest.Code == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		enrollment, err := s.ConfirmTwoFactor(userId, confirmRequest.Code)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// swagger:route DELETE /users/{user_id}/2fa users disableTwoFactor
+// Disables two-factor authentication, the user must send their password
+// Accounts that require two-factor authentication can't disable it
+// Wrong passwords count as failed logins, so they end up locking the account (423)
+// Only accessible by the owner
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	409: genericError
+// 	423: genericError
+// 	500: genericError
+func disableTwoFactor(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Password string `json:"password"`
+	}
+
+	return func(c *gin.Context) {
+		userId, ok := authorizeOwner(c)
+		if !ok {
+			return
+		}
+
+		var disableRequest request
+		if err := c.ShouldBindJSON(&disableRequest); err != nil || disableRequest.Password == "" {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.DisableTwoFactor(userId, disableRequest.Password); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route POST /users/{user_id}/2fa/reset users resetTwoFactor
+// Removes the two-factor authentication of a user that lost access to it and ends their sessions
+// Their personal access tokens are revoked too
+// If the account requires it the user enrolls again on their next login
+// Only accessible by an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func resetTwoFactor(s ports.UsersService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := authorizeAdmin(c)
+		if !ok {
+			return
+		}
+
+		if err := s.ResetTwoFactor(userId); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// swagger:route PUT /users/{user_id}/2fa/required users setTwoFactorRequired
+// Sets whether a user must use two-factor authentication, users that haven't set it up enroll on their next login
+// Only accessible by an admin
+// responses:
+// 	204: noContent
+// 	400: genericError
+// 	401: genericError
+// 	404: genericError
+// 	500: genericError
+func setTwoFactorRequired(s ports.UsersService) gin.HandlerFunc {
+	type request struct {
+		Required *bool `json:"required"`
+	}
+
+	return func(c *gin.Context) {
+		userId, ok := authorizeAdmin(c)
+		if !ok {
+			return
+		}
+
+		var requiredRequest request
+		if err := c.ShouldBindJSON(&requiredRequest); err != nil || requiredRequest.Required == nil {
+			restErr := rest_errors.NewBadRequestError("invalid request body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+
+		if err := s.SetTwoFactorRequired(userId, *requiredRequest.Required); err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
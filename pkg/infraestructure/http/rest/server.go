@@ -20,44 +20,100 @@ type Server struct {
 	srv      *http.Server
 	oauth    *auth.Client
 	rabbitmq *clients.RabbitMQ
+	users    ports.UsersService
+	// trustedProxies are the only peers whose X-Forwarded-For is believed
+	trustedProxies []string
 }
 
-func NewServer(srv *http.Server, db *sql.DB, l ports.UserLogger, oauth *auth.Client, rmq *clients.RabbitMQ) *Server {
+// ServerDeps holds the clients and settings the server builds its services
+// from.
+type ServerDeps struct {
+	DB        *sql.DB
+	Logger    ports.UserLogger
+	OAuth     *auth.Client
+	RMQ       *clients.RabbitMQ
+	Sessions  ports.SessionRevoker
+	Vault     ports.CardVault
+	Cipher    ports.FieldCipher
+	Mailer    ports.Mailer
+	Policy    ports.PasswordPolicy
+	Attempts  ports.LoginAttemptStore
+	Throttle  service.LoginThrottle
+	Hasher    ports.PasswordHasher
+	TwoFactor service.TwoFactorSettings
+	AppURL    string
+	// TrustedProxies lists the addresses or CIDRs of the proxies in front of
+	// the server, the client address of other requests is their peer.
+	TrustedProxies []string
+}
+
+func NewServer(srv *http.Server, deps ServerDeps) *Server {
+	db, l, cipher := deps.DB, deps.Logger, deps.Cipher
 	server := &Server{
 		db:       db,
 		l:        l,
 		srv:      srv,
-		oauth:    oauth,
-		rabbitmq: rmq,
+		oauth:    deps.OAuth,
+		rabbitmq: deps.RMQ,
+
+		trustedProxies: deps.TrustedProxies,
 	}
 
-	ur := repositories.NewUsersRepository(db, l)
-	us := service.NewUsersService(ur, rmq)
+	ur := repositories.NewUsersRepository(db, l, cipher)
+	us := service.NewUsersService(service.UsersServiceDeps{
+		Repo:              ur,
+		RMQ:               deps.RMQ,
+		Tokens:            repositories.NewUserTokenRepository(db, l, cipher),
+		Mailer:            deps.Mailer,
+		Sessions:          deps.Sessions,
+		Revocations:       repositories.NewSessionRevocationRepository(db, l),
+		Policy:            deps.Policy,
+		Attempts:          deps.Attempts,
+		Throttle:          deps.Throttle,
+		Hasher:            deps.Hasher,
+		TwoFactor:         repositories.NewTwoFactorRepository(db, l, cipher),
+		TwoFactorSettings: deps.TwoFactor,
+		LoginEvents:       repositories.NewLoginEventRepository(db, l, cipher),
+		AccessTokens:      repositories.NewAccessTokenRepository(db, l),
+		Log:               l,
+		AppURL:            deps.AppURL,
+	})
+
+	ar := repositories.NewShippingAddressRepository(db, l, cipher)
+	as := service.NewShippingAddressService(ar)
+
+	pr := repositories.NewPaymentOptionRepository(db, l, cipher)
+	ps := service.NewPaymentOptionService(pr, ar, deps.Vault, us, l)
 
-	router := server.Handler(us)
+	server.users = us
+	router := server.Handler(us, as, ps)
 
 	srv.Handler = router
 	return server
 }
 
 func (s *Server) Start() {
-	if err := s.srv.ListenAndServe(); err != nil {
+	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("error while serving: %v", err)
 	}
 }
 
+// Stop lets the requests in flight finish, then the emails they left being
+// sent, before closing the clients. Whatever is still running when ctx is
+// done is dropped.
 func (s *Server) Stop(ctx context.Context) {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		log.Println("Server Shutdown:", err)
+	}
+	if err := s.users.Wait(ctx); err != nil {
+		log.Println("emails still being sent were dropped:", err)
+	}
+
 	s.db.Close()
 	s.oauth.CC.Close()
 	s.rabbitmq.Channel.Close()
 	s.rabbitmq.Connection.Close()
 
-	go func() {
-		if err := s.srv.Shutdown(ctx); err != nil {
-			log.Fatal("Server Shutdown:", err)
-		}
-	}()
-
 	log.Println("Server exiting")
 	os.Exit(0)
 }
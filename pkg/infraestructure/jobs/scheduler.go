@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+// Every runs job right away and then once per interval until ctx is done.
+// Runs never overlap, a slow run just delays the next tick.
+func Every(ctx context.Context, interval time.Duration, l ports.UserLogger, name string, job func(time.Time) rest_errors.RestErr) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(time.Now().UTC()); err != nil {
+			l.Error("job "+name+" failed", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -50,6 +50,11 @@ func (l *userLogger) Info(msg string, tags ...zap.Field) {
 	l.log.Sync()
 }
 
+func (l *userLogger) Warn(msg string, tags ...zap.Field) {
+	l.log.Warn(msg, tags...)
+	l.log.Sync()
+}
+
 func (l *userLogger) Error(msg string, err error, tags ...zap.Field) {
 	tags = append(tags, zap.NamedError("error", err))
 	l.log.Error(msg, tags...)
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEmail = domain.Email{
+	To:      "oscaac@gmail.com",
+	Subject: "Verify your email address",
+	Body:    "Hi Oscar,\n\nhttp://localhost:3000/verify?token=abc\n",
+}
+
+func TestFormatMessage(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		msg, err := formatMessage("no-reply@bookstore.local", testEmail, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+
+		assert.Nil(t, err)
+		assert.Contains(t, string(msg), "To: oscaac@gmail.com\r\n")
+		assert.Contains(t, string(msg), "Subject: Verify your email address\r\n")
+		assert.Contains(t, string(msg), "Date: Sat, 17 Oct 2026 12:00:00 +0000\r\n")
+		assert.True(t, strings.HasSuffix(string(msg), "\r\n\r\nHi Oscar,\r\n\r\nhttp://localhost:3000/verify?token=abc\r\n"))
+	})
+
+	t.Run("HeaderInjection", func(t *testing.T) {
+		e := testEmail
+		e.Subject = "Hello\r\nBcc: victim@example.com"
+
+		_, err := formatMessage("no-reply@bookstore.local", e, time.Now())
+
+		assert.EqualValues(t, ErrInvalidHeader, err)
+	})
+
+	t.Run("InvalidRecipient", func(t *testing.T) {
+		e := testEmail
+		e.To = "not an email"
+
+		_, err := formatMessage("no-reply@bookstore.local", e, time.Now())
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestOutboxMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewOutboxMailer(dir, "no-reply@bookstore.local")
+	assert.Nil(t, err)
+
+	assert.Nil(t, m.Send(testEmail))
+	assert.Nil(t, m.Send(testEmail))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+	assert.True(t, strings.HasSuffix(files[0].Name(), "-oscaac_gmail.com.eml"))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "From: no-reply@bookstore.local\r\n")
+	assert.Contains(t, string(b), "token=abc")
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// outboxMailer writes every email as a .eml file to a local directory
+// instead of sending it. It is meant for development and tests, where the
+// messages can be opened with any mail client.
+type outboxMailer struct {
+	mu   sync.Mutex
+	dir  string
+	from string
+	seq  int
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func NewOutboxMailer(dir, from string) (ports.Mailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &outboxMailer{dir: dir, from: from}, nil
+}
+
+func (m *outboxMailer) Send(e domain.Email) error {
+	now := time.Now()
+	msg, err := formatMessage(m.from, e, now)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seq++
+	name := fmt.Sprintf("%s-%04d-%s.eml", now.UTC().Format("20060102T150405"), m.seq, unsafeFileChars.ReplaceAllString(e.To, "_"))
+	return ioutil.WriteFile(filepath.Join(m.dir, name), msg, 0600)
+}
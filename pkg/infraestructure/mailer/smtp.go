@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// smtpMailer sends emails through an SMTP relay.
+type smtpMailer struct {
+	addr string
+	// from is the From header, which may carry a display name, sender is
+	// its bare address, the only form the MAIL FROM command takes.
+	from   string
+	sender string
+	auth   smtp.Auth
+}
+
+// NewSMTPMailer returns a Mailer that relays emails through the SMTP server
+// at addr (host:port), sending them from the given address, with or without
+// a display name. Authentication is skipped when username is empty.
+func NewSMTPMailer(addr, from, username, password string) (ports.Mailer, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	m := &smtpMailer{
+		addr:   addr,
+		from:   from,
+		sender: sender.Address,
+	}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+func (m *smtpMailer) Send(e domain.Email) error {
+	msg, err := formatMessage(m.from, e, time.Now())
+	if err != nil {
+		return err
+	}
+	// formatMessage already checked the recipient parses
+	to, _ := mail.ParseAddress(e.To)
+	return smtp.SendMail(m.addr, m.auth, m.sender, []string{to.Address}, msg)
+}
@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpSession is what a fakeSMTPServer received during one session.
+type smtpSession struct {
+	mailFrom string
+	rcptTo   []string
+	data     string
+}
+
+// fakeSMTPServer accepts a single SMTP session on a local port and sends
+// what it received through the returned channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan smtpSession) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var s smtpSession
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				s.mailFrom = line[len("MAIL FROM:"):]
+				tp.PrintfLine("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.rcptTo = append(s.rcptTo, line[len("RCPT TO:"):])
+				tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				s.data = string(data)
+				tp.PrintfLine("250 OK")
+			case cmd == "QUIT":
+				tp.PrintfLine("221 bye")
+				sessions <- s
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return l.Addr().String(), sessions
+}
+
+func TestSMTPMailer(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		addr, sessions := fakeSMTPServer(t)
+		m, err := NewSMTPMailer(addr, "Bookstore <no-reply@bookstore.local>", "", "")
+		assert.Nil(t, err)
+
+		e := testEmail
+		e.To = "Oscar Isaac <oscaac@gmail.com>"
+		assert.Nil(t, m.Send(e))
+
+		s := <-sessions
+		// the envelope only takes bare addresses
+		assert.EqualValues(t, "<no-reply@bookstore.local>", s.mailFrom)
+		assert.EqualValues(t, []string{"<oscaac@gmail.com>"}, s.rcptTo)
+		// while the headers keep the display names
+		assert.Contains(t, s.data, "From: Bookstore <no-reply@bookstore.local>\n")
+		assert.Contains(t, s.data, "To: Oscar Isaac <oscaac@gmail.com>\n")
+	})
+
+	t.Run("InvalidSender", func(t *testing.T) {
+		m, err := NewSMTPMailer("127.0.0.1:25", "Bookstore", "", "")
+
+		assert.Nil(t, m)
+		assert.NotNil(t, err)
+	})
+}
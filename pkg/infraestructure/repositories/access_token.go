@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceAccessTokenRepo     sync.Once
+	instanceAccessTokenRepo *accessTokenRepository
+)
+
+// accessTokenRepository stores the personal access tokens of users. Revoked
+// tokens are kept, but never returned.
+type accessTokenRepository struct {
+	db  *sql.DB
+	log ports.UserLogger
+}
+
+func NewAccessTokenRepository(db *sql.DB, logger ports.UserLogger) ports.AccessTokenRepository {
+	onceAccessTokenRepo.Do(func() {
+		instanceAccessTokenRepo = &accessTokenRepository{
+			db:  db,
+			log: logger,
+		}
+	})
+	return instanceAccessTokenRepo
+}
+
+const (
+	queryInsertAccessToken    = "INSERT INTO access_tokens(user_id, name, hint, scopes, token_hash, expires_at, date_created) VALUES(?, ?, ?, ?, ?, ?, ?);"
+	queryListAccessTokens     = "SELECT id, user_id, name, hint, scopes, token_hash, expires_at, last_used_at, date_created FROM access_tokens WHERE user_id=? AND revoked_at IS NULL ORDER BY id DESC;"
+	queryGetAccessTokenByHash = "SELECT id, user_id, name, hint, scopes, token_hash, expires_at, last_used_at, date_created FROM access_tokens WHERE token_hash=? AND revoked_at IS NULL;"
+	queryRevokeAccessToken    = "UPDATE access_tokens SET revoked_at=? WHERE id=? AND user_id=? AND revoked_at IS NULL;"
+	queryRevokeAccessTokens   = "UPDATE access_tokens SET revoked_at=? WHERE user_id=? AND revoked_at IS NULL;"
+	queryTouchAccessToken     = "UPDATE access_tokens SET last_used_at=? WHERE id=?;"
+)
+
+// scopes are stored space separated, like in OAuth
+const scopeSeparator = " "
+
+func (r *accessTokenRepository) Save(t *domain.AccessToken) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryInsertAccessToken)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(t.UserID, t.Name, t.Hint, strings.Join(t.Scopes, scopeSeparator), t.TokenHash, t.ExpiresAt, t.DateCreated)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	tokenId, err := result.LastInsertId()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	t.Id = tokenId
+	return nil
+}
+
+func (r *accessTokenRepository) List(userId int64) ([]domain.AccessToken, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryListAccessTokens)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	tokens := make([]domain.AccessToken, 0)
+	for rows.Next() {
+		t, err := scanAccessToken(rows)
+		if err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		tokens = append(tokens, *t)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return tokens, nil
+}
+
+func (r *accessTokenRepository) GetByHash(hash string) (*domain.AccessToken, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetAccessTokenByHash)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	t, err := scanAccessToken(stmt.QueryRow(hash))
+	if err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return t, nil
+}
+
+func (r *accessTokenRepository) Revoke(userId int64, id int64, revokedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryRevokeAccessToken)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(revokedAt, id, userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return rest_errors.NewNotFoundError("token not found")
+	}
+	return nil
+}
+
+func (r *accessTokenRepository) RevokeAll(userId int64, revokedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryRevokeAccessTokens)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(revokedAt, userId); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *accessTokenRepository) Touch(id int64, usedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryTouchAccessToken)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(usedAt, id); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func scanAccessToken(row rowScanner) (*domain.AccessToken, error) {
+	var t domain.AccessToken
+	var scopes string
+	var lastUsedAt sql.NullString
+	if err := row.Scan(&t.Id, &t.UserID, &t.Name, &t.Hint, &scopes, &t.TokenHash, &t.ExpiresAt, &lastUsedAt, &t.DateCreated); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	t.LastUsedAt = lastUsedAt.String
+	return &t, nil
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var accessTokenColumns = []string{"id", "user_id", "name", "hint", "scopes", "token_hash", "expires_at", "last_used_at", "date_created"}
+
+func TestSaveAccessToken(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	token := domain.AccessToken{
+		UserID:      1,
+		Name:        "deploy script",
+		Hint:        "bpat_7kq2",
+		Scopes:      []string{domain.ScopeAddressesRead, domain.ScopeAddressesWrite},
+		TokenHash:   "hash",
+		ExpiresAt:   "2027-01-15 12:00:00",
+		DateCreated: "2026-10-17 12:00:00",
+	}
+	mock.ExpectPrepare(regexp.QuoteMeta(queryInsertAccessToken)).ExpectExec().
+		WithArgs(1, "deploy script", "bpat_7kq2", "addresses:read addresses:write", "hash", "2027-01-15 12:00:00", "2026-10-17 12:00:00").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	err := repo.Save(&token)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, token.Id)
+}
+
+func TestListAccessTokens(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	rows := sqlmock.NewRows(accessTokenColumns).
+		AddRow(4, 1, "backup", "bpat_Zx0a", "users:read", "hash4", "2027-01-15 12:00:00", "2026-10-17 13:00:00", "2026-10-17 12:00:00").
+		AddRow(3, 1, "deploy script", "bpat_7kq2", "addresses:read addresses:write", "hash3", "2027-01-15 12:00:00", nil, "2026-10-16 12:00:00")
+	mock.ExpectPrepare(regexp.QuoteMeta(queryListAccessTokens)).ExpectQuery().WithArgs(1).WillReturnRows(rows)
+
+	tokens, err := repo.List(1)
+
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 2) {
+		assert.EqualValues(t, "2026-10-17 13:00:00", tokens[0].LastUsedAt)
+		assert.EqualValues(t, "", tokens[1].LastUsedAt)
+		assert.EqualValues(t, []string{domain.ScopeAddressesRead, domain.ScopeAddressesWrite}, tokens[1].Scopes)
+	}
+}
+
+func TestGetAccessTokenByHash(t *testing.T) {
+	query := regexp.QuoteMeta(queryGetAccessTokenByHash)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(accessTokenColumns).
+			AddRow(3, 1, "deploy script", "bpat_7kq2", "addresses:read", "hash", "2027-01-15 12:00:00", nil, "2026-10-17 12:00:00")
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs("hash").WillReturnRows(rows)
+
+		token, err := repo.GetByHash("hash")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 3, token.Id)
+		assert.True(t, token.HasScope(domain.ScopeAddressesRead))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs("hash").WillReturnRows(sqlmock.NewRows(accessTokenColumns))
+
+		token, err := repo.GetByHash("hash")
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WillReturnError(errors.New("error"))
+
+		token, err := repo.GetByHash("hash")
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	query := regexp.QuoteMeta(queryRevokeAccessToken)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", 3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Revoke(1, 3, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", 3, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.Revoke(2, 3, "2026-10-17 12:00:00")
+
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestRevokeAllAccessTokens(t *testing.T) {
+	query := regexp.QuoteMeta(queryRevokeAccessTokens)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", 1).WillReturnResult(sqlmock.NewResult(0, 2))
+
+		err := repo.RevokeAll(1, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &accessTokenRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", 1).WillReturnError(errors.New(""))
+
+		err := repo.RevokeAll(1, "2026-10-17 12:00:00")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
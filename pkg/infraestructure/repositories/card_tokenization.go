@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// Payment options saved before card numbers were tokenized kept the PAN in
+// the column now named card_token and got an empty last4 when migration
+// 000002 added it. Tokenized rows always have their last4 set, so an empty
+// one is what tells the legacy rows apart.
+const (
+	queryCountUntokenizedCards = "SELECT COUNT(*) FROM payment_options WHERE last4='';"
+	queryListUntokenizedCards  = "SELECT id, card_token FROM payment_options WHERE last4='' AND id>? ORDER BY id LIMIT ?;"
+	queryTokenizeCard          = "UPDATE payment_options SET card_token=?, last4=? WHERE id=? AND last4='' AND card_token=?;"
+)
+
+// TokenizationStats counts the legacy payment options moved to the vault and
+// the ones deleted by the application while they were being moved.
+type TokenizationStats struct {
+	Tokenized int
+	Changed   int
+}
+
+// CardTokenizer moves the card numbers of legacy payment options into the
+// vault and stores their token and last four digits instead. Rows are
+// updated one at a time and only if they still hold the number that was
+// read.
+type CardTokenizer struct {
+	db    *sql.DB
+	log   ports.UserLogger
+	vault ports.CardVault
+}
+
+func NewCardTokenizer(db *sql.DB, logger ports.UserLogger, vault ports.CardVault) *CardTokenizer {
+	return &CardTokenizer{
+		db:    db,
+		log:   logger,
+		vault: vault,
+	}
+}
+
+// Pending returns how many payment options still hold a card number.
+func (c *CardTokenizer) Pending() (int, error) {
+	var pending int
+	if err := c.db.QueryRow(queryCountUntokenizedCards).Scan(&pending); err != nil {
+		c.log.Error(err.Error(), err)
+		return 0, err
+	}
+	return pending, nil
+}
+
+// Tokenize walks the legacy payment options in batches of batchSize rows.
+func (c *CardTokenizer) Tokenize(batchSize int) (*TokenizationStats, error) {
+	stats := &TokenizationStats{}
+
+	var lastId int64
+	for {
+		rows, err := c.db.Query(queryListUntokenizedCards, lastId, batchSize)
+		if err != nil {
+			c.log.Error(err.Error(), err)
+			return stats, err
+		}
+
+		type row struct {
+			id  int64
+			pan string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.pan); err != nil {
+				rows.Close()
+				c.log.Error(err.Error(), err)
+				return stats, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.log.Error(err.Error(), err)
+			return stats, err
+		}
+
+		for _, r := range batch {
+			lastId = r.id
+			tokenized, err := c.tokenizeRow(r.id, r.pan)
+			if err != nil {
+				c.log.Error(err.Error(), err)
+				return stats, err
+			}
+			if tokenized {
+				stats.Tokenized++
+			} else {
+				stats.Changed++
+			}
+		}
+
+		if len(batch) < batchSize {
+			return stats, nil
+		}
+	}
+}
+
+// tokenizeRow moves the card number of a single row into the vault. It
+// returns false when the row changed since it was read, the token is dropped
+// then.
+func (c *CardTokenizer) tokenizeRow(id int64, stored string) (bool, error) {
+	// numbers were stored as sent, separators included
+	pan := strings.NewReplacer(" ", "", "-", "").Replace(stored)
+	if len(pan) < 4 || strings.Trim(pan, "0123456789") != "" {
+		return false, fmt.Errorf("payment option %d doesn't hold a card number", id)
+	}
+
+	token, err := c.vault.Tokenize(pan)
+	if err != nil {
+		return false, fmt.Errorf("payment option %d: %w", id, err)
+	}
+
+	// the token is kept when the update fails, it may have gone through and
+	// an unused card in the vault is harmless, unlike a row pointing nowhere
+	result, err := c.db.Exec(queryTokenizeCard, token, pan[len(pan)-4:], id, stored)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		c.vault.Delete(token)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// vaultMock keeps the cards in memory and hands out sequential tokens.
+type vaultMock struct {
+	cards map[string]string
+	seq   int
+}
+
+func (v *vaultMock) Tokenize(pan string) (string, error) {
+	v.seq++
+	token := fmt.Sprintf("tok_%d", v.seq)
+	v.cards[token] = pan
+	return token, nil
+}
+func (v *vaultMock) Detokenize(token string) (string, error) {
+	pan, ok := v.cards[token]
+	if !ok {
+		return "", errors.New("token not found")
+	}
+	return pan, nil
+}
+func (v *vaultMock) Delete(token string) error {
+	delete(v.cards, token)
+	return nil
+}
+
+func TestTokenizeCards(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+		cards := &vaultMock{cards: make(map[string]string)}
+
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUntokenizedCards)).WithArgs(0, 2).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "card_token"}).
+				AddRow(1, "4242 4242 4242 4242").
+				AddRow(3, "5555-5555-5555-4444"))
+		mock.ExpectExec(regexp.QuoteMeta(queryTokenizeCard)).
+			WithArgs("tok_1", "4242", 1, "4242 4242 4242 4242").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// deleted by its owner meanwhile
+		mock.ExpectExec(regexp.QuoteMeta(queryTokenizeCard)).
+			WithArgs("tok_2", "4444", 3, "5555-5555-5555-4444").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUntokenizedCards)).WithArgs(3, 2).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "card_token"}).
+				AddRow(4, "378282246310005"))
+		mock.ExpectExec(regexp.QuoteMeta(queryTokenizeCard)).
+			WithArgs("tok_3", "0005", 4, "378282246310005").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		stats, err := NewCardTokenizer(db, &loggerMock{}, cards).Tokenize(2)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, stats.Tokenized)
+		assert.EqualValues(t, 1, stats.Changed)
+		assert.EqualValues(t, map[string]string{"tok_1": "4242424242424242", "tok_3": "378282246310005"}, cards.cards)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NotACardNumber", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+		cards := &vaultMock{cards: make(map[string]string)}
+
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUntokenizedCards)).WithArgs(0, 10).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "card_token"}).
+				AddRow(7, "vault_0123456789abcdef"))
+
+		stats, err := NewCardTokenizer(db, &loggerMock{}, cards).Tokenize(10)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, 0, stats.Tokenized)
+		assert.Len(t, cards.cards, 0)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPendingCardTokenization(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryCountUntokenizedCards)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	pending, err := NewCardTokenizer(db, &loggerMock{}, &vaultMock{}).Pending()
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, pending)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
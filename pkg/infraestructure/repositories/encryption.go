@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// encryptValues replaces each of values with its ciphertext.
+func encryptValues(c ports.FieldCipher, values ...*string) error {
+	for _, v := range values {
+		encrypted, err := c.Encrypt(*v)
+		if err != nil {
+			return err
+		}
+		*v = encrypted
+	}
+	return nil
+}
+
+// decryptValues replaces each of values with its plaintext.
+func decryptValues(c ports.FieldCipher, values ...*string) error {
+	for _, v := range values {
+		decrypted, err := c.Decrypt(*v)
+		if err != nil {
+			return err
+		}
+		*v = decrypted
+	}
+	return nil
+}
+
+// sealAddress returns a copy of a with its personal data encrypted, ready to
+// be stored. Both shipping and billing addresses go through it.
+func sealAddress(c ports.FieldCipher, a domain.ShippingAddress) (domain.ShippingAddress, error) {
+	err := encryptValues(c, &a.EmailInvoice, &a.FullName, &a.AddressLine1, &a.AddressLine2, &a.PostCode)
+	return a, err
+}
+
+func openAddress(c ports.FieldCipher, a *domain.ShippingAddress) error {
+	return decryptValues(c, &a.EmailInvoice, &a.FullName, &a.AddressLine1, &a.AddressLine2, &a.PostCode)
+}
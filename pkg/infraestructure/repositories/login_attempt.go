@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceLoginAttemptRepo     sync.Once
+	instanceLoginAttemptRepo *loginAttemptRepository
+)
+
+// loginAttemptRepository stores login attempts in MySQL so every instance of
+// the service shares them. Keys contain email addresses, only their hash is
+// stored.
+type loginAttemptRepository struct {
+	db  *sql.DB
+	log ports.UserLogger
+}
+
+func NewLoginAttemptRepository(db *sql.DB, logger ports.UserLogger) ports.LoginAttemptStore {
+	onceLoginAttemptRepo.Do(func() {
+		instanceLoginAttemptRepo = &loginAttemptRepository{
+			db:  db,
+			log: logger,
+		}
+	})
+	return instanceLoginAttemptRepo
+}
+
+const (
+	attemptsDateLayout = "2006-01-02 15:04:05"
+
+	queryGetLoginAttempts = "SELECT failures, last_failure, locked_until FROM login_attempts WHERE key_hash=?;"
+	// failures is assigned before last_failure, so it still compares the
+	// previous failure with the start of the window
+	queryFailLoginAttempt  = "INSERT INTO login_attempts(key_hash, failures, last_failure) VALUES(?, 1, ?) ON DUPLICATE KEY UPDATE failures=IF(last_failure < ?, 1, failures + 1), last_failure=VALUES(last_failure);"
+	queryLockLoginAttempts = "INSERT INTO login_attempts(key_hash, failures, locked_until) VALUES(?, 0, ?) ON DUPLICATE KEY UPDATE failures=0, locked_until=VALUES(locked_until);"
+	queryResetLoginAttempt = "DELETE FROM login_attempts WHERE key_hash=?;"
+)
+
+func hashAttemptKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *loginAttemptRepository) Get(key string) (*domain.LoginAttempts, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetLoginAttempts)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	var attempts domain.LoginAttempts
+	var lastFailure, lockedUntil sql.NullString
+	if err := stmt.QueryRow(hashAttemptKey(key)).Scan(&attempts.Failures, &lastFailure, &lockedUntil); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return &domain.LoginAttempts{}, nil
+		}
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	if lastFailure.Valid {
+		attempts.LastFailure, _ = time.Parse(attemptsDateLayout, lastFailure.String)
+	}
+	if lockedUntil.Valid {
+		attempts.LockedUntil, _ = time.Parse(attemptsDateLayout, lockedUntil.String)
+	}
+	return &attempts, nil
+}
+
+func (r *loginAttemptRepository) Fail(key string, failedAt time.Time, window time.Duration) (*domain.LoginAttempts, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryFailLoginAttempt)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	failedAt = failedAt.UTC()
+	if _, err := stmt.Exec(hashAttemptKey(key), failedAt.Format(attemptsDateLayout), failedAt.Add(-window).Format(attemptsDateLayout)); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return r.Get(key)
+}
+
+func (r *loginAttemptRepository) Lock(key string, until time.Time) rest_errors.RestErr {
+	return r.exec(queryLockLoginAttempts, hashAttemptKey(key), until.UTC().Format(attemptsDateLayout))
+}
+
+func (r *loginAttemptRepository) Reset(key string) rest_errors.RestErr {
+	return r.exec(queryResetLoginAttempt, hashAttemptKey(key))
+}
+
+func (r *loginAttemptRepository) exec(query string, args ...interface{}) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(args...); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
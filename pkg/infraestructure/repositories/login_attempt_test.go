@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var loginAttemptColumns = []string{"failures", "last_failure", "locked_until"}
+
+func TestGetLoginAttempts(t *testing.T) {
+	query := regexp.QuoteMeta(queryGetLoginAttempts)
+	key := "account:oscaac@gmail.com"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginAttemptRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(loginAttemptColumns).AddRow(2, "2026-10-17 12:00:00", nil)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(hashAttemptKey(key)).WillReturnRows(rows)
+
+		attempts, err := repo.Get(key)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, attempts.Failures)
+		assert.EqualValues(t, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), attempts.LastFailure)
+		assert.True(t, attempts.LockedUntil.IsZero())
+	})
+
+	t.Run("NoAttempts", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginAttemptRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(hashAttemptKey(key)).WillReturnRows(sqlmock.NewRows(loginAttemptColumns))
+
+		attempts, err := repo.Get(key)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, attempts.Failures)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginAttemptRepository{db: db, log: &loggerMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(hashAttemptKey(key)).WillReturnError(errors.New("connection lost"))
+
+		attempts, err := repo.Get(key)
+
+		assert.Nil(t, attempts)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestFailLoginAttempt(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &loginAttemptRepository{db: db, log: &loggerMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	key := "ip:203.0.113.7"
+	failedAt := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	mock.ExpectPrepare(regexp.QuoteMeta(queryFailLoginAttempt)).ExpectExec().
+		WithArgs(hashAttemptKey(key), "2026-10-17 12:00:00", "2026-10-17 11:45:00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	rows := sqlmock.NewRows(loginAttemptColumns).AddRow(1, "2026-10-17 12:00:00", nil)
+	mock.ExpectPrepare(regexp.QuoteMeta(queryGetLoginAttempts)).ExpectQuery().WithArgs(hashAttemptKey(key)).WillReturnRows(rows)
+
+	attempts, err := repo.Fail(key, failedAt, 15*time.Minute)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, attempts.Failures)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestLockLoginAttempts(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &loginAttemptRepository{db: db, log: &loggerMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	key := "account:oscaac@gmail.com"
+	mock.ExpectPrepare(regexp.QuoteMeta(queryLockLoginAttempts)).ExpectExec().
+		WithArgs(hashAttemptKey(key), "2026-10-17 12:15:00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Lock(key, time.Date(2026, 10, 17, 12, 15, 0, 0, time.UTC))
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"database/sql"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceLoginEventRepo     sync.Once
+	instanceLoginEventRepo *loginEventRepository
+)
+
+// loginEventRepository stores the login history of users, with the address
+// and user agent of the client encrypted with the field cipher. Attempts on
+// emails without an account are stored without a user.
+type loginEventRepository struct {
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
+}
+
+func NewLoginEventRepository(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) ports.LoginEventRepository {
+	onceLoginEventRepo.Do(func() {
+		instanceLoginEventRepo = &loginEventRepository{
+			db:     db,
+			log:    logger,
+			cipher: cipher,
+		}
+	})
+	return instanceLoginEventRepo
+}
+
+const (
+	querySaveLoginEvent   = "INSERT INTO login_events(user_id, success, reason, ip, user_agent, fingerprint, new_device, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?);"
+	queryListLoginEvents  = "SELECT id, user_id, success, reason, ip, user_agent, new_device, created_at FROM login_events WHERE user_id=? AND id<? ORDER BY id DESC LIMIT ?;"
+	queryDeviceLogins     = "SELECT COUNT(*), COALESCE(SUM(fingerprint=?), 0) FROM login_events WHERE user_id=? AND success=true;"
+	queryPruneLoginEvents = "DELETE FROM login_events WHERE created_at<?;"
+)
+
+func (r *loginEventRepository) Save(event *domain.LoginEvent) rest_errors.RestErr {
+	ip, userAgent := event.IP, event.UserAgent
+	if err := encryptValues(r.cipher, &ip, &userAgent); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	stmt, err := r.db.Prepare(querySaveLoginEvent)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	var userId, reason interface{}
+	if event.UserID != 0 {
+		userId = event.UserID
+	}
+	if event.Reason != "" {
+		reason = event.Reason
+	}
+	result, err := stmt.Exec(userId, event.Success, reason, ip, userAgent, event.Fingerprint, event.NewDevice, event.CreatedAt)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	eventId, err := result.LastInsertId()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	event.Id = eventId
+	return nil
+}
+
+func (r *loginEventRepository) List(userId int64, before int64, limit int) ([]domain.LoginEvent, rest_errors.RestErr) {
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+
+	stmt, err := r.db.Prepare(queryListLoginEvents)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId, before, limit)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	events := make([]domain.LoginEvent, 0)
+	for rows.Next() {
+		var event domain.LoginEvent
+		var reason sql.NullString
+		if err := rows.Scan(&event.Id, &event.UserID, &event.Success, &reason, &event.IP, &event.UserAgent, &event.NewDevice, &event.CreatedAt); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		event.Reason = reason.String
+		if err := decryptValues(r.cipher, &event.IP, &event.UserAgent); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return events, nil
+}
+
+func (r *loginEventRepository) DeviceLogins(userId int64, fingerprint string) (int, int, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryDeviceLogins)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return 0, 0, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	var total, device int
+	if err := stmt.QueryRow(fingerprint, userId).Scan(&total, &device); err != nil {
+		r.log.Error(err.Error(), err)
+		return 0, 0, rest_errors.NewInternalServerError("db error")
+	}
+	return total, device, nil
+}
+
+func (r *loginEventRepository) Prune(before time.Time) (int64, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryPruneLoginEvents)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return 0, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(before.UTC().Format(attemptsDateLayout))
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return 0, rest_errors.NewInternalServerError("db error")
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return 0, rest_errors.NewInternalServerError("db error")
+	}
+	return pruned, nil
+}
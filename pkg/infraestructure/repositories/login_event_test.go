@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var loginEventColumns = []string{"id", "user_id", "success", "reason", "ip", "user_agent", "new_device", "created_at"}
+
+func TestSaveLoginEvent(t *testing.T) {
+	query := regexp.QuoteMeta(querySaveLoginEvent)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		event := domain.LoginEvent{UserID: 1, Success: true, IP: "203.0.113.7", UserAgent: "bookstore-tests/1.0", Fingerprint: "fp", CreatedAt: "2026-10-17 12:00:00"}
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(1, true, nil, "203.0.113.7", "bookstore-tests/1.0", "fp", false, "2026-10-17 12:00:00").WillReturnResult(sqlmock.NewResult(7, 1))
+
+		err := repo.Save(&event)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 7, event.Id)
+	})
+
+	t.Run("UnknownAccount", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		event := domain.LoginEvent{Reason: domain.LoginReasonInvalidCredentials, IP: "203.0.113.7", UserAgent: "bookstore-tests/1.0", Fingerprint: "fp", CreatedAt: "2026-10-17 12:00:00"}
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(nil, false, domain.LoginReasonInvalidCredentials, "203.0.113.7", "bookstore-tests/1.0", "fp", false, "2026-10-17 12:00:00").WillReturnResult(sqlmock.NewResult(8, 1))
+
+		err := repo.Save(&event)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WillReturnError(errors.New("error"))
+
+		err := repo.Save(&domain.LoginEvent{UserID: 1})
+
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.EqualValues(t, "db error", err.Message())
+	})
+}
+
+func TestListLoginEvents(t *testing.T) {
+	query := regexp.QuoteMeta(queryListLoginEvents)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(loginEventColumns).
+			AddRow(8, 1, false, domain.LoginReasonInvalidCredentials, "203.0.113.7", "bookstore-tests/1.0", false, "2026-10-17 12:01:00").
+			AddRow(7, 1, true, nil, "203.0.113.7", "bookstore-tests/1.0", true, "2026-10-17 12:00:00")
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(1, int64(math.MaxInt64), 20).WillReturnRows(rows)
+
+		events, err := repo.List(1, 0, 20)
+
+		assert.Nil(t, err)
+		assert.Len(t, events, 2)
+		assert.EqualValues(t, domain.LoginReasonInvalidCredentials, events[0].Reason)
+		assert.EqualValues(t, "", events[1].Reason)
+		assert.True(t, events[1].NewDevice)
+	})
+
+	t.Run("Before", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(1, 7, 20).WillReturnRows(sqlmock.NewRows(loginEventColumns))
+
+		events, err := repo.List(1, 7, 20)
+
+		assert.Nil(t, err)
+		assert.NotNil(t, events)
+		assert.Len(t, events, 0)
+	})
+}
+
+func TestDeviceLogins(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	mock.ExpectPrepare(regexp.QuoteMeta(queryDeviceLogins)).ExpectQuery().WithArgs("fp", 1).WillReturnRows(sqlmock.NewRows([]string{"total", "device"}).AddRow(12, 0))
+
+	total, device, err := repo.DeviceLogins(1, "fp")
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 12, total)
+	assert.EqualValues(t, 0, device)
+}
+
+func TestPruneLoginEvents(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &loginEventRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	mock.ExpectPrepare(regexp.QuoteMeta(queryPruneLoginEvents)).ExpectExec().WithArgs("2026-07-19 12:00:00").WillReturnResult(sqlmock.NewResult(0, 42))
+
+	pruned, err := repo.Prune(time.Date(2026, 7, 19, 12, 0, 0, 0, time.UTC))
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 42, pruned)
+}
@@ -0,0 +1,350 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	oncePaymentOptionRepo     sync.Once
+	instancePaymentOptionRepo *paymentOptionRepository
+)
+
+type paymentOptionRepository struct {
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
+}
+
+func NewPaymentOptionRepository(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) ports.PaymentOptionRepository {
+	oncePaymentOptionRepo.Do(func() {
+		instancePaymentOptionRepo = &paymentOptionRepository{
+			db:     db,
+			log:    logger,
+			cipher: cipher,
+		}
+	})
+	return instancePaymentOptionRepo
+}
+
+const (
+	selectPaymentOption = "SELECT p.id, p.user_id, p.card_type, p.card_token, p.last4, p.expiry_month, p.expiry_year, p.name_on_card, p.is_default, p.billing_address_id, p.billing_same_as_shipping, " +
+		"b.id, b.email_invoice, b.full_name, b.address_line1, b.address_line2, b.city, b.state, b.post_code, b.country " +
+		"FROM payment_options p LEFT JOIN billing_addresses b ON b.payment_option_id=p.id "
+
+	queryListPaymentOptions      = selectPaymentOption + "WHERE p.user_id=?;"
+	queryGetPaymentOption        = selectPaymentOption + "WHERE p.id=? AND p.user_id=?;"
+	queryGetDefaultPaymentOption = selectPaymentOption + "WHERE p.user_id=? AND p.is_default=true;"
+	queryInsertPaymentOption     = "INSERT INTO payment_options(user_id, card_type, card_token, last4, expiry_month, expiry_year, name_on_card, billing_address_id, billing_same_as_shipping) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	queryInsertBillingAddress    = "INSERT INTO billing_addresses(payment_option_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	queryLockPaymentOption       = "SELECT is_default FROM payment_options WHERE id=? AND user_id=? FOR UPDATE;"
+	queryDeletePaymentOption     = "DELETE FROM payment_options WHERE id=? AND user_id=?;"
+	queryClearDefaultPayment     = "UPDATE payment_options SET is_default=false WHERE user_id=? AND is_default=true;"
+	querySetDefaultPayment       = "UPDATE payment_options SET is_default=true WHERE id=? AND user_id=?;"
+	queryPromotePaymentOption    = "UPDATE payment_options SET is_default=true WHERE user_id=? ORDER BY id LIMIT 1;"
+	queryListExpiringPayment     = selectPaymentOption + "WHERE (p.expiry_year, p.expiry_month) >= (?, ?) AND (p.expiry_year, p.expiry_month) <= (?, ?) AND NOT EXISTS (SELECT 1 FROM payment_option_notifications n WHERE n.payment_option_id=p.id AND n.expiry_year=p.expiry_year AND n.expiry_month=p.expiry_month);"
+	queryInsertExpiryNotified    = "INSERT IGNORE INTO payment_option_notifications(payment_option_id, expiry_year, expiry_month, notified_at) VALUES(?, ?, ?, ?);"
+	queryDeleteExpiryNotified    = "DELETE FROM payment_option_notifications WHERE payment_option_id=? AND expiry_year=? AND expiry_month=?;"
+)
+
+// scanPaymentOption reads a row of selectPaymentOption. The billing columns
+// come from a left join and are only set for inline billing addresses.
+func scanPaymentOption(row rowScanner, c ports.FieldCipher) (*domain.PaymentOption, error) {
+	var po domain.PaymentOption
+	var billingAddressId, inlineId sql.NullInt64
+	var email, fullName, line1, line2, city, state, postCode, country sql.NullString
+	if err := row.Scan(&po.Id, &po.UserID, &po.CardType, &po.CardToken, &po.Last4, &po.ExpiryMonth, &po.ExpiryYear, &po.NameOnCard, &po.IsDefault, &billingAddressId, &po.BillingSameAsShipping,
+		&inlineId, &email, &fullName, &line1, &line2, &city, &state, &postCode, &country); err != nil {
+		return nil, err
+	}
+
+	if err := decryptValues(c, &po.NameOnCard); err != nil {
+		return nil, err
+	}
+
+	po.BillingAddressID = billingAddressId.Int64
+	if inlineId.Valid {
+		po.BillingAddress = &domain.ShippingAddress{
+			UserId:       po.UserID,
+			EmailInvoice: email.String,
+			FullName:     fullName.String,
+			AddressLine1: line1.String,
+			AddressLine2: line2.String,
+			City:         city.String,
+			State:        state.String,
+			PostCode:     postCode.String,
+			Country:      country.String,
+		}
+		if err := openAddress(c, po.BillingAddress); err != nil {
+			return nil, err
+		}
+	}
+	return &po, nil
+}
+
+func (r *paymentOptionRepository) List(userId int64) ([]domain.PaymentOption, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryListPaymentOptions)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	options := make([]domain.PaymentOption, 0)
+	for rows.Next() {
+		po, err := scanPaymentOption(rows, r.cipher)
+		if err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		options = append(options, *po)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return options, nil
+}
+
+func (r *paymentOptionRepository) Get(userId, id int64) (*domain.PaymentOption, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetPaymentOption)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	po, err := scanPaymentOption(stmt.QueryRow(id, userId), r.cipher)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("payment option not found")
+		}
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return po, nil
+}
+
+// Save inserts the payment option and, when it carries an inline billing
+// address, that address too, within the same transaction.
+func (r *paymentOptionRepository) Save(po *domain.PaymentOption) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	nameOnCard := po.NameOnCard
+	if err := encryptValues(r.cipher, &nameOnCard); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	billingAddressId := sql.NullInt64{Int64: po.BillingAddressID, Valid: po.BillingAddressID != 0}
+	insertResult, err := tx.Exec(queryInsertPaymentOption, po.UserID, po.CardType, po.CardToken, po.Last4, po.ExpiryMonth, po.ExpiryYear, nameOnCard, billingAddressId, po.BillingSameAsShipping)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	poId, err := insertResult.LastInsertId()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if po.BillingAddress != nil && !billingAddressId.Valid && !po.BillingSameAsShipping {
+		a, err := sealAddress(r.cipher, *po.BillingAddress)
+		if err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+		if _, err := tx.Exec(queryInsertBillingAddress, poId, a.EmailInvoice, a.FullName, a.AddressLine1, nullString(a.AddressLine2), a.City, nullString(a.State), a.PostCode, a.Country); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	po.Id = poId
+	return nil
+}
+
+func (r *paymentOptionRepository) GetDefault(userId int64) (*domain.PaymentOption, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetDefaultPaymentOption)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	po, err := scanPaymentOption(stmt.QueryRow(userId), r.cipher)
+	if err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("default payment option not found")
+		}
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return po, nil
+}
+
+// SetDefault clears the current default of the user and flags the given
+// payment option instead, both within the same transaction.
+func (r *paymentOptionRepository) SetDefault(userId, id int64) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(queryClearDefaultPayment, userId); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	result, err := tx.Exec(querySetDefaultPayment, id, userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return rest_errors.NewNotFoundError("payment option not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+// Delete removes a payment option and, if it was the default one, promotes
+// the oldest remaining payment option of the user to default.
+func (r *paymentOptionRepository) Delete(userId, id int64) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	if err := tx.QueryRow(queryLockPaymentOption, id, userId).Scan(&wasDefault); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return rest_errors.NewNotFoundError("payment option not found")
+		}
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if _, err := tx.Exec(queryDeletePaymentOption, id, userId); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if wasDefault {
+		if _, err := tx.Exec(queryPromotePaymentOption, userId); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+// ListExpiring returns the payment options whose expiry month falls between
+// the months of from and until, both included, and that weren't announced
+// yet for their current expiry date.
+func (r *paymentOptionRepository) ListExpiring(from, until time.Time) ([]domain.PaymentOption, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryListExpiringPayment)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(from.Year(), int(from.Month()), until.Year(), int(until.Month()))
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	options := make([]domain.PaymentOption, 0)
+	for rows.Next() {
+		po, err := scanPaymentOption(rows, r.cipher)
+		if err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		options = append(options, *po)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return options, nil
+}
+
+// MarkExpiryNotified records that the expiry of po was announced. It returns
+// false when it had already been recorded, which lets concurrent runs of the
+// job agree on who publishes the event.
+func (r *paymentOptionRepository) MarkExpiryNotified(po *domain.PaymentOption) (bool, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryInsertExpiryNotified)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(po.Id, po.ExpiryYear, po.ExpiryMonth, time.Now().UTC())
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	return affected == 1, nil
+}
+
+func (r *paymentOptionRepository) UnmarkExpiryNotified(po *domain.PaymentOption) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryDeleteExpiryNotified)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(po.Id, po.ExpiryYear, po.ExpiryMonth); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
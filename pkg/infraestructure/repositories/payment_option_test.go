@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPaymentOption = domain.PaymentOption{
+	Id:          1,
+	UserID:      1,
+	CardType:    "visa",
+	CardToken:   "tok_0123456789abcdef",
+	Last4:       "4242",
+	ExpiryMonth: 12,
+	ExpiryYear:  2030,
+	NameOnCard:  "Oscar Isaac",
+}
+
+var paymentOptionColumns = []string{"id", "user_id", "card_type", "card_token", "last4", "expiry_month", "expiry_year", "name_on_card", "is_default", "billing_address_id", "billing_same_as_shipping",
+	"b.id", "email_invoice", "full_name", "address_line1", "address_line2", "city", "state", "post_code", "country"}
+
+func TestGetPaymentOption(t *testing.T) {
+	query := regexp.QuoteMeta(queryGetPaymentOption)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(paymentOptionColumns).
+			AddRow(testPaymentOption.Id, testPaymentOption.UserID, testPaymentOption.CardType, testPaymentOption.CardToken, testPaymentOption.Last4, testPaymentOption.ExpiryMonth, testPaymentOption.ExpiryYear, testPaymentOption.NameOnCard, true, nil, false,
+				nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testPaymentOption.Id, testPaymentOption.UserID).WillReturnRows(rows)
+
+		po, err := repo.Get(testPaymentOption.UserID, testPaymentOption.Id)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, testPaymentOption.CardToken, po.CardToken)
+		assert.EqualValues(t, "4242", po.Last4)
+		assert.Nil(t, po.BillingAddress)
+	})
+
+	t.Run("InlineBillingAddress", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(paymentOptionColumns).
+			AddRow(testPaymentOption.Id, testPaymentOption.UserID, testPaymentOption.CardType, testPaymentOption.CardToken, testPaymentOption.Last4, testPaymentOption.ExpiryMonth, testPaymentOption.ExpiryYear, testPaymentOption.NameOnCard, true, nil, false,
+				4, testAddress.EmailInvoice, testAddress.FullName, testAddress.AddressLine1, nil, testAddress.City, "OR", testAddress.PostCode, testAddress.Country)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testPaymentOption.Id, testPaymentOption.UserID).WillReturnRows(rows)
+
+		po, err := repo.Get(testPaymentOption.UserID, testPaymentOption.Id)
+
+		assert.Nil(t, err)
+		assert.NotNil(t, po.BillingAddress)
+		assert.EqualValues(t, testAddress.City, po.BillingAddress.City)
+		assert.EqualValues(t, "OR", po.BillingAddress.State)
+		assert.EqualValues(t, "", po.BillingAddress.AddressLine2)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testPaymentOption.Id, testPaymentOption.UserID).WillReturnError(errors.New("error: no rows in result set"))
+
+		po, err := repo.Get(testPaymentOption.UserID, testPaymentOption.Id)
+
+		assert.Nil(t, po)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "payment option not found", err.Message())
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestSavePaymentOption(t *testing.T) {
+	query := "INSERT INTO payment_options\\(user_id, card_type, card_token, last4, expiry_month, expiry_year, name_on_card, billing_address_id, billing_same_as_shipping\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\);"
+	billingQuery := "INSERT INTO billing_addresses\\(payment_option_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\);"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).
+			WithArgs(testPaymentOption.UserID, testPaymentOption.CardType, testPaymentOption.CardToken, testPaymentOption.Last4, testPaymentOption.ExpiryMonth, testPaymentOption.ExpiryYear, testPaymentOption.NameOnCard, nil, false).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectCommit()
+
+		po := testPaymentOption
+		err := repo.Save(&po)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 3, po.Id)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("InlineBillingAddress", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec(billingQuery).
+			WithArgs(3, testAddress.EmailInvoice, testAddress.FullName, testAddress.AddressLine1, nil, testAddress.City, nil, testAddress.PostCode, testAddress.Country).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		po := testPaymentOption
+		billingAddress := testAddress
+		po.BillingAddress = &billingAddress
+		err := repo.Save(&po)
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ExecError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		po := testPaymentOption
+		err := repo.Save(&po)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "db error", err.Message())
+	})
+}
+
+func TestDeletePaymentOption(t *testing.T) {
+	lockQuery := "SELECT is_default FROM payment_options WHERE id=\\? AND user_id=\\? FOR UPDATE;"
+	deleteQuery := "DELETE FROM payment_options WHERE id=\\? AND user_id=\\?;"
+	promoteQuery := "UPDATE payment_options SET is_default=true WHERE user_id=\\? ORDER BY id LIMIT 1;"
+
+	t.Run("PromotesNewDefault", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(testPaymentOption.Id, testPaymentOption.UserID).WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(true))
+		mock.ExpectExec(deleteQuery).WithArgs(testPaymentOption.Id, testPaymentOption.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(promoteQuery).WithArgs(testPaymentOption.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Delete(testPaymentOption.UserID, testPaymentOption.Id)
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(testPaymentOption.Id, testPaymentOption.UserID).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.Delete(testPaymentOption.UserID, testPaymentOption.Id)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestListExpiringPaymentOptions(t *testing.T) {
+	query := regexp.QuoteMeta(queryListExpiringPayment)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(paymentOptionColumns).
+			AddRow(testPaymentOption.Id, testPaymentOption.UserID, testPaymentOption.CardType, testPaymentOption.CardToken, testPaymentOption.Last4, 11, 2026, testPaymentOption.NameOnCard, true, nil, false,
+				nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(2026, 10, 2026, 11).WillReturnRows(rows)
+
+		from := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+		options, err := repo.ListExpiring(from, from.AddDate(0, 1, 0))
+
+		assert.Nil(t, err)
+		assert.Len(t, options, 1)
+		assert.EqualValues(t, 11, options[0].ExpiryMonth)
+	})
+}
+
+func TestMarkExpiryNotified(t *testing.T) {
+	query := "INSERT IGNORE INTO payment_option_notifications\\(payment_option_id, expiry_year, expiry_month, notified_at\\) VALUES\\(\\?, \\?, \\?, \\?\\);"
+
+	t.Run("Claimed", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().
+			WithArgs(testPaymentOption.Id, testPaymentOption.ExpiryYear, testPaymentOption.ExpiryMonth, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		po := testPaymentOption
+		claimed, err := repo.MarkExpiryNotified(&po)
+
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("AlreadyNotified", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &paymentOptionRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().
+			WithArgs(testPaymentOption.Id, testPaymentOption.ExpiryYear, testPaymentOption.ExpiryMonth, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		po := testPaymentOption
+		claimed, err := repo.MarkExpiryNotified(&po)
+
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+	})
+}
@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// encryptedTable lists the columns of a table that hold encrypted values.
+type encryptedTable struct {
+	name    string
+	columns []string
+	// indexColumn, when set, holds the blind index of indexSource.
+	indexColumn string
+	indexSource string
+}
+
+var encryptedTables = []encryptedTable{
+	{name: "users", columns: []string{"first_name", "last_name", "email"}, indexColumn: "email_bidx", indexSource: "email"},
+	{name: "shipping_addresses", columns: []string{"email_invoice", "full_name", "address_line1", "address_line2", "post_code"}},
+	{name: "billing_addresses", columns: []string{"email_invoice", "full_name", "address_line1", "address_line2", "post_code"}},
+	{name: "payment_options", columns: []string{"name_on_card"}},
+	{name: "user_two_factor", columns: []string{"secret"}},
+	{name: "login_events", columns: []string{"ip", "user_agent"}},
+	{name: "user_tokens", columns: []string{"payload"}},
+}
+
+// RotationStats counts rows per table: the ones re-encrypted, the ones that
+// were already up to date and the ones the application changed while they
+// were being rotated, which are left as written by the application.
+type RotationStats struct {
+	Rotated map[string]int
+	Current map[string]int
+	Changed map[string]int
+}
+
+const (
+	rowCurrent = iota
+	rowRotated
+	rowChanged
+)
+
+// KeyRotator re-encrypts every encrypted column with the active key of the
+// cipher and backfills missing blind indexes. It is safe to run while the
+// application serves traffic: rows are updated one at a time and only if
+// they still hold the values that were read, anything written meanwhile by
+// the application already uses the active key.
+type KeyRotator struct {
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
+}
+
+func NewKeyRotator(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) *KeyRotator {
+	return &KeyRotator{
+		db:     db,
+		log:    logger,
+		cipher: cipher,
+	}
+}
+
+// queryCountMissingEmailIndex counts the users stored before emails were
+// encrypted, purged users have no email left to index.
+const queryCountMissingEmailIndex = "SELECT COUNT(*) FROM users WHERE email_bidx IS NULL AND status<>'purged';"
+
+// PendingIndexes returns how many users still lack the blind index of their
+// email. Until Rotate backfills it, nothing but Save checks their emails are
+// unique.
+func (k *KeyRotator) PendingIndexes() (int, error) {
+	var pending int
+	if err := k.db.QueryRow(queryCountMissingEmailIndex).Scan(&pending); err != nil {
+		k.log.Error(err.Error(), err)
+		return 0, err
+	}
+	return pending, nil
+}
+
+// Rotate walks every encrypted table in batches of batchSize rows.
+func (k *KeyRotator) Rotate(batchSize int) (*RotationStats, error) {
+	stats := &RotationStats{
+		Rotated: make(map[string]int),
+		Current: make(map[string]int),
+		Changed: make(map[string]int),
+	}
+	for _, table := range encryptedTables {
+		if err := k.rotateTable(table, batchSize, stats); err != nil {
+			k.log.Error(err.Error(), err)
+			return stats, fmt.Errorf("rotating %s: %w", table.name, err)
+		}
+	}
+	return stats, nil
+}
+
+func (k *KeyRotator) rotateTable(table encryptedTable, batchSize int, stats *RotationStats) error {
+	selectQuery, updateQuery := rotationQueries(table)
+
+	var lastId int64
+	for {
+		rows, err := k.db.Query(selectQuery, lastId, batchSize)
+		if err != nil {
+			return err
+		}
+
+		type row struct {
+			id     int64
+			values []sql.NullString
+		}
+		var batch []row
+		for rows.Next() {
+			r := row{values: make([]sql.NullString, len(table.columns)+1)}
+			dest := []interface{}{&r.id}
+			for i := range r.values {
+				dest = append(dest, &r.values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range batch {
+			lastId = r.id
+			outcome, err := k.rotateRow(table, updateQuery, r.id, r.values)
+			if err != nil {
+				return err
+			}
+			switch outcome {
+			case rowRotated:
+				stats.Rotated[table.name]++
+			case rowChanged:
+				stats.Changed[table.name]++
+			default:
+				stats.Current[table.name]++
+			}
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+// rotateRow re-encrypts a single row. values holds the stored columns in the
+// order of table.columns, followed by the stored blind index, always NULL
+// for tables without one.
+func (k *KeyRotator) rotateRow(table encryptedTable, updateQuery string, id int64, values []sql.NullString) (int, error) {
+	columns := values[:len(table.columns)]
+
+	stale := false
+	for _, v := range columns {
+		if v.Valid && k.cipher.NeedsRotation(v.String) {
+			stale = true
+		}
+	}
+	if !stale && table.indexColumn == "" {
+		return rowCurrent, nil
+	}
+
+	args := make([]interface{}, 0, 2*len(values)+1)
+	var indexValue string
+	for i, v := range columns {
+		if !v.Valid {
+			args = append(args, nil)
+			continue
+		}
+		plaintext, err := k.cipher.Decrypt(v.String)
+		if err != nil {
+			return 0, fmt.Errorf("row %d, column %s: %w", id, table.columns[i], err)
+		}
+		if table.columns[i] == table.indexSource {
+			indexValue = k.cipher.BlindIndex(plaintext)
+		}
+
+		if !stale {
+			args = append(args, v.String)
+			continue
+		}
+		encrypted, err := k.cipher.Encrypt(plaintext)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, encrypted)
+	}
+
+	if table.indexColumn != "" {
+		index := values[len(table.columns)]
+		if !stale && index.Valid && index.String == indexValue {
+			return rowCurrent, nil
+		}
+		args = append(args, indexValue)
+	}
+
+	args = append(args, id)
+	for _, v := range columns {
+		if v.Valid {
+			args = append(args, v.String)
+		} else {
+			args = append(args, nil)
+		}
+	}
+
+	result, err := k.db.Exec(updateQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return rowChanged, nil
+	}
+	return rowRotated, nil
+}
+
+// rotationQueries builds the batch select and the compare-and-set update of
+// a table. Names come from encryptedTables, never from user input.
+func rotationQueries(table encryptedTable) (string, string) {
+	selected := append([]string{"id"}, table.columns...)
+	if table.indexColumn != "" {
+		selected = append(selected, table.indexColumn)
+	} else {
+		selected = append(selected, "NULL")
+	}
+
+	set := make([]string, 0, len(table.columns)+1)
+	where := []string{"id=?"}
+	for _, column := range table.columns {
+		set = append(set, column+"=?")
+		where = append(where, column+"<=>?")
+	}
+	if table.indexColumn != "" {
+		set = append(set, table.indexColumn+"=?")
+	}
+
+	selectQuery := fmt.Sprintf("SELECT %s FROM %s WHERE id>? ORDER BY id LIMIT ?;", strings.Join(selected, ", "), table.name)
+	updateQuery := fmt.Sprintf("UPDATE %s SET %s WHERE %s;", table.name, strings.Join(set, ", "), strings.Join(where, " AND "))
+	return selectQuery, updateQuery
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixCipher marks values encrypted with the active key with "new:" and
+// treats "old:" values as encrypted with a retired one.
+type prefixCipher struct{}
+
+func (c *prefixCipher) Encrypt(s string) (string, error) { return "new:" + s, nil }
+func (c *prefixCipher) Decrypt(s string) (string, error) {
+	return strings.TrimPrefix(strings.TrimPrefix(s, "new:"), "old:"), nil
+}
+func (c *prefixCipher) BlindIndex(s string) string  { return "bidx:" + s }
+func (c *prefixCipher) NeedsRotation(s string) bool { return !strings.HasPrefix(s, "new:") }
+
+func TestRotate(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	usersSelect, usersUpdate := rotationQueries(encryptedTables[0])
+	mock.ExpectQuery(regexp.QuoteMeta(usersSelect)).WithArgs(0, 10).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "email_bidx"}).
+			AddRow(1, "old:Oscar", "old:Isaac", "oscaac@gmail.com", nil).
+			AddRow(2, "new:Frances", "new:McDormand", "new:fran@gmail.com", "bidx:fran@gmail.com").
+			AddRow(3, "new:Ethan", "new:Coen", "new:ethan@gmail.com", nil).
+			AddRow(4, "old:Joel", "old:Coen", "old:joel@gmail.com", "bidx:joel@gmail.com"))
+	mock.ExpectExec(regexp.QuoteMeta(usersUpdate)).
+		WithArgs("new:Oscar", "new:Isaac", "new:oscaac@gmail.com", "bidx:oscaac@gmail.com", 1, "old:Oscar", "old:Isaac", "oscaac@gmail.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(usersUpdate)).
+		WithArgs("new:Ethan", "new:Coen", "new:ethan@gmail.com", "bidx:ethan@gmail.com", 3, "new:Ethan", "new:Coen", "new:ethan@gmail.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(usersUpdate)).
+		WithArgs("new:Joel", "new:Coen", "new:joel@gmail.com", "bidx:joel@gmail.com", 4, "old:Joel", "old:Coen", "old:joel@gmail.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	addressesSelect, addressesUpdate := rotationQueries(encryptedTables[1])
+	mock.ExpectQuery(regexp.QuoteMeta(addressesSelect)).WithArgs(0, 10).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "email_invoice", "full_name", "address_line1", "address_line2", "post_code", "NULL"}).
+			AddRow(1, "old:oscaac@gmail.com", "old:Oscar Isaac", "old:742 Evergreen Terrace", nil, "old:97403", nil))
+	mock.ExpectExec(regexp.QuoteMeta(addressesUpdate)).
+		WithArgs("new:oscaac@gmail.com", "new:Oscar Isaac", "new:742 Evergreen Terrace", nil, "new:97403", 1, "old:oscaac@gmail.com", "old:Oscar Isaac", "old:742 Evergreen Terrace", nil, "old:97403").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	for _, table := range encryptedTables[2:] {
+		selectQuery, _ := rotationQueries(table)
+		mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(0, 10).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	stats, err := NewKeyRotator(db, &loggerMock{}, &prefixCipher{}).Rotate(10)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, stats.Rotated["users"])
+	assert.EqualValues(t, 1, stats.Current["users"])
+	assert.EqualValues(t, 1, stats.Changed["users"])
+	assert.EqualValues(t, 1, stats.Rotated["shipping_addresses"])
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRotationQueries(t *testing.T) {
+	selectQuery, updateQuery := rotationQueries(encryptedTables[0])
+
+	assert.EqualValues(t, "SELECT id, first_name, last_name, email, email_bidx FROM users WHERE id>? ORDER BY id LIMIT ?;", selectQuery)
+	assert.EqualValues(t, "UPDATE users SET first_name=?, last_name=?, email=?, email_bidx=? WHERE id=? AND first_name<=>? AND last_name<=>? AND email<=>?;", updateQuery)
+}
+
+func TestPendingIndexes(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(queryCountMissingEmailIndex)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	pending, err := NewKeyRotator(db, &loggerMock{}, &prefixCipher{}).PendingIndexes()
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, pending)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceSessionRevocationRepo     sync.Once
+	instanceSessionRevocationRepo *sessionRevocationRepository
+)
+
+type sessionRevocationRepository struct {
+	db  *sql.DB
+	log ports.UserLogger
+}
+
+func NewSessionRevocationRepository(db *sql.DB, logger ports.UserLogger) ports.SessionRevocationRepository {
+	onceSessionRevocationRepo.Do(func() {
+		instanceSessionRevocationRepo = &sessionRevocationRepository{
+			db:  db,
+			log: logger,
+		}
+	})
+	return instanceSessionRevocationRepo
+}
+
+const (
+	queryEnqueueSessionRevocation = "INSERT INTO session_revocations(user_id, requested_at) VALUES(?, ?) ON DUPLICATE KEY UPDATE requested_at=VALUES(requested_at);"
+	queryListSessionRevocations   = "SELECT user_id, requested_at FROM session_revocations ORDER BY requested_at LIMIT ?;"
+	queryDeleteSessionRevocation  = "DELETE FROM session_revocations WHERE user_id=? AND requested_at=?;"
+)
+
+func (r *sessionRevocationRepository) Enqueue(userId int64, requestedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryEnqueueSessionRevocation)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(userId, requestedAt); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *sessionRevocationRepository) ListPending(limit int) ([]domain.SessionRevocation, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryListSessionRevocations)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(limit)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	revocations := make([]domain.SessionRevocation, 0)
+	for rows.Next() {
+		var rev domain.SessionRevocation
+		if err := rows.Scan(&rev.UserID, &rev.RequestedAt); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		revocations = append(revocations, rev)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return revocations, nil
+}
+
+func (r *sessionRevocationRepository) Done(rev domain.SessionRevocation) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryDeleteSessionRevocation)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(rev.UserID, rev.RequestedAt); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueSessionRevocation(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+		repo := &sessionRevocationRepository{db: db, log: &loggerMock{}}
+
+		mock.ExpectPrepare(regexp.QuoteMeta(queryEnqueueSessionRevocation)).ExpectExec().
+			WithArgs(1, "2026-10-17 12:00:00").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.Enqueue(1, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("DBError", func(t *testing.T) {
+		db, mock := NewMock()
+		defer db.Close()
+		repo := &sessionRevocationRepository{db: db, log: &loggerMock{}}
+
+		mock.ExpectPrepare(regexp.QuoteMeta(queryEnqueueSessionRevocation)).ExpectExec().
+			WithArgs(1, "2026-10-17 12:00:00").
+			WillReturnError(errors.New("connection refused"))
+
+		err := repo.Enqueue(1, "2026-10-17 12:00:00")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestListPendingSessionRevocations(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+	repo := &sessionRevocationRepository{db: db, log: &loggerMock{}}
+
+	mock.ExpectPrepare(regexp.QuoteMeta(queryListSessionRevocations)).ExpectQuery().WithArgs(10).WillReturnRows(
+		sqlmock.NewRows([]string{"user_id", "requested_at"}).
+			AddRow(3, "2026-10-17 11:00:00").
+			AddRow(1, "2026-10-17 12:00:00"))
+
+	revocations, err := repo.ListPending(10)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, []domain.SessionRevocation{
+		{UserID: 3, RequestedAt: "2026-10-17 11:00:00"},
+		{UserID: 1, RequestedAt: "2026-10-17 12:00:00"},
+	}, revocations)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDoneSessionRevocation(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+	repo := &sessionRevocationRepository{db: db, log: &loggerMock{}}
+
+	// requested again meanwhile, the row is left for the next retry
+	mock.ExpectPrepare(regexp.QuoteMeta(queryDeleteSessionRevocation)).ExpectExec().
+		WithArgs(1, "2026-10-17 12:00:00").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.Done(domain.SessionRevocation{UserID: 1, RequestedAt: "2026-10-17 12:00:00"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,261 @@
+package repositories
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceShippingAddressRepo     sync.Once
+	instanceShippingAddressRepo *shippingAddressRepository
+)
+
+type shippingAddressRepository struct {
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
+}
+
+func NewShippingAddressRepository(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) ports.ShippingAddressRepository {
+	onceShippingAddressRepo.Do(func() {
+		instanceShippingAddressRepo = &shippingAddressRepository{
+			db:     db,
+			log:    logger,
+			cipher: cipher,
+		}
+	})
+	return instanceShippingAddressRepo
+}
+
+const (
+	queryListAddresses       = "SELECT id, user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country, is_default FROM shipping_addresses WHERE user_id=?;"
+	queryGetAddress          = "SELECT id, user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country, is_default FROM shipping_addresses WHERE id=? AND user_id=?;"
+	queryGetDefaultAddress   = "SELECT id, user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country, is_default FROM shipping_addresses WHERE user_id=? AND is_default=true;"
+	queryInsertAddress       = "INSERT INTO shipping_addresses(user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	queryUpdateAddress       = "UPDATE shipping_addresses SET email_invoice=?, full_name=?, address_line1=?, address_line2=?, city=?, state=?, post_code=?, country=? WHERE id=? AND user_id=?;"
+	queryLockAddress         = "SELECT is_default FROM shipping_addresses WHERE id=? AND user_id=? FOR UPDATE;"
+	queryDeleteAddress       = "DELETE FROM shipping_addresses WHERE id=? AND user_id=?;"
+	queryClearDefaultAddress = "UPDATE shipping_addresses SET is_default=false WHERE user_id=? AND is_default=true;"
+	querySetDefaultAddress   = "UPDATE shipping_addresses SET is_default=true WHERE id=? AND user_id=?;"
+	queryPromoteAddress      = "UPDATE shipping_addresses SET is_default=true WHERE user_id=? ORDER BY id LIMIT 1;"
+)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAddress(row rowScanner, c ports.FieldCipher) (*domain.ShippingAddress, error) {
+	var address domain.ShippingAddress
+	var line2, state sql.NullString
+	if err := row.Scan(&address.Id, &address.UserId, &address.EmailInvoice, &address.FullName, &address.AddressLine1, &line2, &address.City, &state, &address.PostCode, &address.Country, &address.IsDefault); err != nil {
+		return nil, err
+	}
+	address.AddressLine2 = line2.String
+	address.State = state.String
+	if err := openAddress(c, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (r *shippingAddressRepository) List(userId int64) ([]domain.ShippingAddress, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryListAddresses)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	addresses := make([]domain.ShippingAddress, 0)
+	for rows.Next() {
+		address, err := scanAddress(rows, r.cipher)
+		if err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		addresses = append(addresses, *address)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return addresses, nil
+}
+
+func (r *shippingAddressRepository) Get(userId, id int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetAddress)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	address, err := scanAddress(stmt.QueryRow(id, userId), r.cipher)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("address not found")
+		}
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return address, nil
+}
+
+func (r *shippingAddressRepository) Save(address *domain.ShippingAddress) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryInsertAddress)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	sealed, err := sealAddress(r.cipher, *address)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	insertResult, err := stmt.Exec(sealed.UserId, sealed.EmailInvoice, sealed.FullName, sealed.AddressLine1, nullString(sealed.AddressLine2), sealed.City, nullString(sealed.State), sealed.PostCode, sealed.Country)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	addressId, err := insertResult.LastInsertId()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	address.Id = addressId
+	return nil
+}
+
+func (r *shippingAddressRepository) Update(address *domain.ShippingAddress) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryUpdateAddress)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	sealed, err := sealAddress(r.cipher, *address)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	_, err = stmt.Exec(sealed.EmailInvoice, sealed.FullName, sealed.AddressLine1, nullString(sealed.AddressLine2), sealed.City, nullString(sealed.State), sealed.PostCode, sealed.Country, sealed.Id, sealed.UserId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *shippingAddressRepository) GetDefault(userId int64) (*domain.ShippingAddress, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetDefaultAddress)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	address, err := scanAddress(stmt.QueryRow(userId), r.cipher)
+	if err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("default address not found")
+		}
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return address, nil
+}
+
+// SetDefault clears the current default of the user and flags the given
+// address instead, both within the same transaction.
+func (r *shippingAddressRepository) SetDefault(userId, id int64) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(queryClearDefaultAddress, userId); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	result, err := tx.Exec(querySetDefaultAddress, id, userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return rest_errors.NewNotFoundError("address not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+// Delete removes an address and, if it was the default one, promotes the
+// oldest remaining address of the user to default.
+func (r *shippingAddressRepository) Delete(userId, id int64) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	if err := tx.QueryRow(queryLockAddress, id, userId).Scan(&wasDefault); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return rest_errors.NewNotFoundError("address not found")
+		}
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if _, err := tx.Exec(queryDeleteAddress, id, userId); err != nil {
+		if strings.Contains(err.Error(), errForeignKey) {
+			return rest_errors.NewRestError("address is used as billing address of a payment option", http.StatusConflict, "conflict")
+		}
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if wasDefault {
+		if _, err := tx.Exec(queryPromoteAddress, userId); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
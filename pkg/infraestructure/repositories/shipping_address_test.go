@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAddress = domain.ShippingAddress{
+	Id:           1,
+	UserId:       1,
+	EmailInvoice: "oscaac@gmail.com",
+	FullName:     "Oscar Isaac",
+	AddressLine1: "742 Evergreen Terrace",
+	City:         "Springfield",
+	PostCode:     "97403",
+	Country:      "US",
+}
+
+var addressColumns = []string{"id", "user_id", "email_invoice", "full_name", "address_line1", "address_line2", "city", "state", "post_code", "country", "is_default"}
+
+func TestListAddresses(t *testing.T) {
+	query := "SELECT id, user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country, is_default FROM shipping_addresses WHERE user_id=\\?;"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(addressColumns).
+			AddRow(testAddress.Id, testAddress.UserId, testAddress.EmailInvoice, testAddress.FullName, testAddress.AddressLine1, nil, testAddress.City, nil, testAddress.PostCode, testAddress.Country, false).
+			AddRow(2, testAddress.UserId, testAddress.EmailInvoice, testAddress.FullName, "Apt 2", "Floor 3", testAddress.City, "OR", testAddress.PostCode, testAddress.Country, false)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testAddress.UserId).WillReturnRows(rows)
+
+		addresses, err := repo.List(testAddress.UserId)
+
+		assert.Nil(t, err)
+		assert.Len(t, addresses, 2)
+		assert.EqualValues(t, "", addresses[0].State)
+		assert.EqualValues(t, "OR", addresses[1].State)
+	})
+
+	t.Run("QueryingError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testAddress.UserId).WillReturnError(sql.ErrConnDone)
+
+		addresses, err := repo.List(testAddress.UserId)
+
+		assert.Nil(t, addresses)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "db error", err.Message())
+	})
+}
+
+func TestGetAddress(t *testing.T) {
+	query := "SELECT id, user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country, is_default FROM shipping_addresses WHERE id=\\? AND user_id=\\?;"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(addressColumns).
+			AddRow(testAddress.Id, testAddress.UserId, testAddress.EmailInvoice, testAddress.FullName, testAddress.AddressLine1, nil, testAddress.City, nil, testAddress.PostCode, testAddress.Country, false)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testAddress.Id, testAddress.UserId).WillReturnRows(rows)
+
+		address, err := repo.Get(testAddress.UserId, testAddress.Id)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, testAddress.City, address.City)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(testAddress.Id, testAddress.UserId).WillReturnError(errors.New("error: no rows in result set"))
+
+		address, err := repo.Get(testAddress.UserId, testAddress.Id)
+
+		assert.Nil(t, address)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "address not found", err.Message())
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestSaveAddress(t *testing.T) {
+	query := "INSERT INTO shipping_addresses\\(user_id, email_invoice, full_name, address_line1, address_line2, city, state, post_code, country\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\);"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().
+			WithArgs(testAddress.UserId, testAddress.EmailInvoice, testAddress.FullName, testAddress.AddressLine1, nil, testAddress.City, nil, testAddress.PostCode, testAddress.Country).
+			WillReturnResult(sqlmock.NewResult(7, 1))
+
+		address := testAddress
+		err := repo.Save(&address)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 7, address.Id)
+	})
+
+	t.Run("PrepareError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).WillReturnError(sql.ErrConnDone)
+
+		address := testAddress
+		err := repo.Save(&address)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestSetDefaultAddress(t *testing.T) {
+	clearQuery := "UPDATE shipping_addresses SET is_default=false WHERE user_id=\\? AND is_default=true;"
+	setQuery := "UPDATE shipping_addresses SET is_default=true WHERE id=\\? AND user_id=\\?;"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(clearQuery).WithArgs(testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(setQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.SetDefault(testAddress.UserId, testAddress.Id)
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(clearQuery).WithArgs(testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(setQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.SetDefault(testAddress.UserId, testAddress.Id)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteAddress(t *testing.T) {
+	lockQuery := "SELECT is_default FROM shipping_addresses WHERE id=\\? AND user_id=\\? FOR UPDATE;"
+	deleteQuery := "DELETE FROM shipping_addresses WHERE id=\\? AND user_id=\\?;"
+	promoteQuery := "UPDATE shipping_addresses SET is_default=true WHERE user_id=\\? ORDER BY id LIMIT 1;"
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(false))
+		mock.ExpectExec(deleteQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Delete(testAddress.UserId, testAddress.Id)
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("PromotesNewDefault", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(true))
+		mock.ExpectExec(deleteQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(promoteQuery).WithArgs(testAddress.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Delete(testAddress.UserId, testAddress.Id)
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.Delete(testAddress.UserId, testAddress.Id)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+
+	t.Run("UsedAsBillingAddress", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &shippingAddressRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockQuery).WithArgs(testAddress.Id, testAddress.UserId).WillReturnRows(sqlmock.NewRows([]string{"is_default"}).AddRow(false))
+		mock.ExpectExec(deleteQuery).WithArgs(testAddress.Id, testAddress.UserId).
+			WillReturnError(errors.New("Error 1451: Cannot delete or update a parent row: a foreign key constraint fails"))
+		mock.ExpectRollback()
+
+		err := repo.Delete(testAddress.UserId, testAddress.Id)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+}
@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceTwoFactorRepo     sync.Once
+	instanceTwoFactorRepo *twoFactorRepository
+)
+
+// twoFactorRepository stores TOTP secrets encrypted with the field cipher and
+// only hashes of the recovery codes.
+type twoFactorRepository struct {
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
+}
+
+func NewTwoFactorRepository(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) ports.TwoFactorRepository {
+	onceTwoFactorRepo.Do(func() {
+		instanceTwoFactorRepo = &twoFactorRepository{
+			db:     db,
+			log:    logger,
+			cipher: cipher,
+		}
+	})
+	return instanceTwoFactorRepo
+}
+
+const (
+	queryGetTwoFactor         = "SELECT user_id, secret, enabled_at, last_used_step, required FROM user_two_factor WHERE user_id=?;"
+	querySaveTwoFactorSecret  = "INSERT INTO user_two_factor(user_id, secret) VALUES(?, ?) ON DUPLICATE KEY UPDATE secret=VALUES(secret), enabled_at=NULL, last_used_step=0;"
+	queryEnableTwoFactor      = "UPDATE user_two_factor SET enabled_at=?, last_used_step=? WHERE user_id=? AND secret IS NOT NULL;"
+	queryUseTwoFactorStep     = "UPDATE user_two_factor SET last_used_step=? WHERE user_id=? AND last_used_step<?;"
+	queryDisableTwoFactor     = "UPDATE user_two_factor SET secret=NULL, enabled_at=NULL, last_used_step=0 WHERE user_id=?;"
+	querySetTwoFactorRequired = "INSERT INTO user_two_factor(user_id, required) VALUES(?, ?) ON DUPLICATE KEY UPDATE required=VALUES(required);"
+	queryDeleteRecoveryCodes  = "DELETE FROM user_recovery_codes WHERE user_id=?;"
+	queryInsertRecoveryCode   = "INSERT INTO user_recovery_codes(user_id, code_hash) VALUES(?, ?);"
+	queryUseRecoveryCode      = "UPDATE user_recovery_codes SET used_at=? WHERE user_id=? AND code_hash=? AND used_at IS NULL;"
+)
+
+func (r *twoFactorRepository) Get(userId int64) (*domain.TwoFactor, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryGetTwoFactor)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	var t domain.TwoFactor
+	var secret, enabledAt sql.NullString
+	if err := stmt.QueryRow(userId).Scan(&t.UserID, &secret, &enabledAt, &t.LastUsedStep, &t.Required); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("two-factor authentication not found")
+		}
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	t.Secret, t.EnabledAt = secret.String, enabledAt.String
+	if t.Secret != "" {
+		if err := decryptValues(r.cipher, &t.Secret); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+	}
+	return &t, nil
+}
+
+func (r *twoFactorRepository) SaveSecret(userId int64, secret string) rest_errors.RestErr {
+	if err := encryptValues(r.cipher, &secret); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return r.exec(querySaveTwoFactorSecret, userId, secret)
+}
+
+func (r *twoFactorRepository) Enable(userId int64, enabledAt string, step int64, recoveryCodeHashes []string) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(queryEnableTwoFactor, enabledAt, step, userId)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if affected == 0 {
+		return rest_errors.NewNotFoundError("two-factor authentication not found")
+	}
+
+	if _, err := tx.Exec(queryDeleteRecoveryCodes, userId); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(queryInsertRecoveryCode, userId, hash); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) UseStep(userId int64, step int64) (bool, rest_errors.RestErr) {
+	return r.execAffected(queryUseTwoFactorStep, step, userId, step)
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(userId int64, codeHash string, usedAt string) (bool, rest_errors.RestErr) {
+	return r.execAffected(queryUseRecoveryCode, usedAt, userId, codeHash)
+}
+
+func (r *twoFactorRepository) Disable(userId int64) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{queryDisableTwoFactor, queryDeleteRecoveryCodes} {
+		if _, err := tx.Exec(query, userId); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) SetRequired(userId int64, required bool) rest_errors.RestErr {
+	return r.exec(querySetTwoFactorRequired, userId, required)
+}
+
+func (r *twoFactorRepository) exec(query string, args ...interface{}) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(args...); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+// execAffected runs a conditional update and reports whether it matched.
+func (r *twoFactorRepository) execAffected(query string, args ...interface{}) (bool, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(args...)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	return affected > 0, nil
+}
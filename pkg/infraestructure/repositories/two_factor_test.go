@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var twoFactorColumns = []string{"user_id", "secret", "enabled_at", "last_used_step", "required"}
+
+func TestGetTwoFactor(t *testing.T) {
+	query := regexp.QuoteMeta(queryGetTwoFactor)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(twoFactorColumns).AddRow(1, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "2026-10-17 12:00:00", 56666666, false)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(1).WillReturnRows(rows)
+
+		tf, err := repo.Get(1)
+
+		assert.Nil(t, err)
+		assert.True(t, tf.Enabled())
+		assert.EqualValues(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", tf.Secret)
+		assert.EqualValues(t, 56666666, tf.LastUsedStep)
+	})
+
+	t.Run("OnlyRequired", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(twoFactorColumns).AddRow(1, nil, nil, 0, true)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(1).WillReturnRows(rows)
+
+		tf, err := repo.Get(1)
+
+		assert.Nil(t, err)
+		assert.False(t, tf.Enabled())
+		assert.True(t, tf.Required)
+		assert.EqualValues(t, "", tf.Secret)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows(twoFactorColumns))
+
+		tf, err := repo.Get(1)
+
+		assert.Nil(t, tf)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestEnableTwoFactor(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(queryEnableTwoFactor)).WithArgs("2026-10-17 12:00:00", 56666666, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(queryDeleteRecoveryCodes)).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(queryInsertRecoveryCode)).WithArgs(1, "hash1").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(queryInsertRecoveryCode)).WithArgs(1, "hash2").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		err := repo.Enable(1, "2026-10-17 12:00:00", 56666666, []string{"hash1", "hash2"})
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NoSecret", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(queryEnableTwoFactor)).WithArgs("2026-10-17 12:00:00", 56666666, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.Enable(1, "2026-10-17 12:00:00", 56666666, []string{"hash1"})
+
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(queryEnableTwoFactor)).WithArgs("2026-10-17 12:00:00", 56666666, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(queryDeleteRecoveryCodes)).WithArgs(1).WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+
+		err := repo.Enable(1, "2026-10-17 12:00:00", 56666666, []string{"hash1"})
+
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.EqualValues(t, "db error", err.Message())
+	})
+}
+
+func TestUseTwoFactorStep(t *testing.T) {
+	query := regexp.QuoteMeta(queryUseTwoFactorStep)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(56666667, 1, 56666667).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		used, err := repo.UseStep(1, 56666667)
+
+		assert.Nil(t, err)
+		assert.True(t, used)
+	})
+
+	t.Run("AlreadyUsed", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &twoFactorRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(56666667, 1, 56666667).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		used, err := repo.UseStep(1, 56666667)
+
+		assert.Nil(t, err)
+		assert.False(t, used)
+	})
+}
@@ -19,33 +19,84 @@ var (
 )
 
 type usersRepository struct {
-	db  *sql.DB
-	log ports.UserLogger
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
 }
 
-func NewUsersRepository(db *sql.DB, logger ports.UserLogger) ports.UsersRepository {
+func NewUsersRepository(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) ports.UsersRepository {
 	onceUsersRepo.Do(func() {
 		instanceUsersRepo = &usersRepository{
-			db:  db,
-			log: logger,
+			db:     db,
+			log:    logger,
+			cipher: cipher,
 		}
 	})
 	return instanceUsersRepo
 }
 
 const (
-	queryGetUser         = "SELECT id, first_name, last_name, email, date_created, status, role FROM users WHERE id=?;"
-	queryGetUserByEmail  = "SELECT id, first_name, last_name, email, date_created, status, role, password FROM users WHERE email=?;"
-	queryInsertUser      = "INSERT INTO users(first_name, last_name, email, date_created, status, password, role) VALUES(?, ?, ?, ?, ?, ?, ?);"
-	queryUpdateUser      = "UPDATE users SET first_name=?, last_name=?, email=?, password=?, last_modified=? WHERE id=?;"
-	queryUpdateUserAdmin = "UPDATE users SET first_name=?, last_name=?, email=?, password=?, status=?, role=?, last_modified=? WHERE id=?;"
-	queryDeleteUser      = "UPDATE users SET status='inactive' WHERE id=?;"
+	queryGetUser        = "SELECT id, first_name, last_name, email, date_created, status, role FROM users WHERE id=?;"
+	queryGetUserByEmail = "SELECT id, first_name, last_name, email, date_created, status, role, password FROM users WHERE email_bidx=? OR (email_bidx IS NULL AND email=?);"
+	// rows stored before emails were encrypted have no blind index, the
+	// unique index on it doesn't cover them
+	queryCountLegacyEmail = "SELECT COUNT(*) FROM users WHERE email_bidx IS NULL AND email=?;"
+	queryInsertUser       = "INSERT INTO users(first_name, last_name, email, email_bidx, date_created, status, password, role) VALUES(?, ?, ?, ?, ?, ?, ?, ?);"
+	queryUpdateUser       = "UPDATE users SET first_name=?, last_name=?, email=?, email_bidx=?, last_modified=? WHERE id=?;"
+	queryUpdateUserAdmin  = "UPDATE users SET first_name=?, last_name=?, email=?, email_bidx=?, status=?, role=?, last_modified=? WHERE id=?;"
+	queryUpdateEmail      = "UPDATE users SET email=?, email_bidx=?, last_modified=? WHERE id=?;"
+	queryUpdatePassword   = "UPDATE users SET password=?, last_modified=? WHERE id=?;"
+	querySetUserStatus    = "UPDATE users SET status=?, last_modified=? WHERE id=? AND status=?;"
+	// MySQL assigns from left to right, status_before_delete gets the status
+	// the user had until now
+	queryDeleteUser  = "UPDATE users SET status_before_delete=status, status='deleted', deleted_at=? WHERE id=?;"
+	queryListDeleted = "SELECT id, first_name, last_name, email, date_created, status, role, deleted_at FROM users WHERE status='deleted' AND deleted_at<=? ORDER BY deleted_at LIMIT ?;"
 )
 
+// Users that never logged in count as inactive since they registered. A
+// login clears the dormancy warning, so a warned user that is still active
+// hasn't logged in since.
 const (
-	errNoRow = "no rows in result"
+	queryRecordLogin          = "UPDATE users SET last_login_at=?, dormancy_warned_at=NULL WHERE id=?;"
+	queryListInactive         = "SELECT id, first_name, last_name, email, date_created, status, role, last_login_at FROM users WHERE status='active' AND dormancy_warned_at IS NULL AND COALESCE(last_login_at, date_created)<=? ORDER BY id LIMIT ?;"
+	queryListDormancyWarned   = "SELECT id, first_name, last_name, email, date_created, status, role, last_login_at FROM users WHERE status='active' AND dormancy_warned_at<=? ORDER BY dormancy_warned_at LIMIT ?;"
+	queryMarkDormancyWarned   = "UPDATE users SET dormancy_warned_at=? WHERE id=? AND status='active' AND dormancy_warned_at IS NULL;"
+	queryUnmarkDormancyWarned = "UPDATE users SET dormancy_warned_at=NULL WHERE id=?;"
+	queryMarkDormant          = "UPDATE users SET status='dormant', dormancy_warned_at=NULL, last_modified=? WHERE id=? AND status='active' AND dormancy_warned_at IS NOT NULL;"
 )
 
+// Purging runs in a single transaction that locks the user first, so a login
+// restoring the account can't interleave with it. Payment options go before
+// addresses, they may reference them as billing address.
+const (
+	queryLockDeletedUser     = "SELECT status FROM users WHERE id=? FOR UPDATE;"
+	queryPurgePaymentOptions = "DELETE FROM payment_options WHERE user_id=?;"
+	queryPurgeAddresses      = "DELETE FROM shipping_addresses WHERE user_id=?;"
+	queryPurgeTokens         = "DELETE FROM user_tokens WHERE user_id=?;"
+	queryPurgeRecoveryCodes  = "DELETE FROM user_recovery_codes WHERE user_id=?;"
+	queryPurgeTwoFactor      = "DELETE FROM user_two_factor WHERE user_id=?;"
+	queryPurgeLoginEvents    = "DELETE FROM login_events WHERE user_id=?;"
+	queryPurgeAccessTokens   = "DELETE FROM access_tokens WHERE user_id=?;"
+	queryAnonymizeUser       = "UPDATE users SET first_name='', last_name='', email=CONCAT('deleted-', id), email_bidx=NULL, password='', status='purged', status_before_delete=NULL, last_modified=? WHERE id=?;"
+)
+
+// Restoring locks the user too, so it reads the status to go back to and
+// leaves the deleted state at once.
+const (
+	queryLockRestoredUser = "SELECT status, status_before_delete FROM users WHERE id=? FOR UPDATE;"
+	queryRestoreUser      = "UPDATE users SET status=?, status_before_delete=NULL, deleted_at=NULL WHERE id=?;"
+)
+
+const (
+	errNoRow      = "no rows in result"
+	errForeignKey = "a foreign key constraint fails"
+)
+
+// errEmailTaken reports a write that hit the unique index on the email.
+func errEmailTaken() rest_errors.RestErr {
+	return rest_errors.NewRestError("email already in use", http.StatusConflict, "conflict")
+}
+
 func (r *usersRepository) Get(id int64) (*domain.User, rest_errors.RestErr) {
 	stmt, err := r.db.Prepare(queryGetUser)
 	if err != nil {
@@ -63,6 +114,10 @@ func (r *usersRepository) Get(id int64) (*domain.User, rest_errors.RestErr) {
 		}
 		return nil, rest_errors.NewInternalServerError("db error")
 	}
+	if err := decryptValues(r.cipher, &user.FirstName, &user.LastName, &user.Email); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
 
 	return &user, nil
 }
@@ -75,8 +130,10 @@ func (r *usersRepository) GetByEmail(email string) (*domain.User, rest_errors.Re
 	}
 	defer stmt.Close()
 
+	// rows written before encryption have no blind index yet and still hold
+	// the email in plaintext, until the keys are rotated
 	var user domain.User
-	result := stmt.QueryRow(email)
+	result := stmt.QueryRow(r.cipher.BlindIndex(email), email)
 	if err := result.Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.Role, &user.Password); err != nil {
 		r.log.Error(err.Error(), err)
 		if strings.Contains(err.Error(), errNoRow) {
@@ -84,10 +141,23 @@ func (r *usersRepository) GetByEmail(email string) (*domain.User, rest_errors.Re
 		}
 		return nil, rest_errors.NewInternalServerError("db error")
 	}
+	if err := decryptValues(r.cipher, &user.FirstName, &user.LastName, &user.Email); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
 	return &user, nil
 }
 
 func (r *usersRepository) Save(user *domain.User) rest_errors.RestErr {
+	var legacy int
+	if err := r.db.QueryRow(queryCountLegacyEmail, user.Email).Scan(&legacy); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if legacy > 0 {
+		return errEmailTaken()
+	}
+
 	stmt, err := r.db.Prepare(queryInsertUser)
 	if err != nil {
 		r.log.Error(err.Error(), err)
@@ -95,11 +165,17 @@ func (r *usersRepository) Save(user *domain.User) rest_errors.RestErr {
 	}
 	defer stmt.Close()
 
-	insertResult, err := stmt.Exec(user.FirstName, user.LastName, user.Email, user.DateCreated, user.Status, user.Password, user.Role)
+	firstName, lastName, email := user.FirstName, user.LastName, user.Email
+	if err := encryptValues(r.cipher, &firstName, &lastName, &email); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	insertResult, err := stmt.Exec(firstName, lastName, email, r.cipher.BlindIndex(user.Email), user.DateCreated, user.Status, user.Password, user.Role)
 	if err != nil {
 		r.log.Error(err.Error(), err)
 		if strings.Contains(err.Error(), "Duplicate entry") {
-			return rest_errors.NewRestError("email already in user", http.StatusConflict, "conflict")
+			return errEmailTaken()
 		}
 		return rest_errors.NewInternalServerError("db error")
 	}
@@ -121,9 +197,18 @@ func (r *usersRepository) Update(user *domain.User) rest_errors.RestErr {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(user.FirstName, user.LastName, user.Email, user.Password, user.LastModified, user.Id)
+	firstName, lastName, email := user.FirstName, user.LastName, user.Email
+	if err := encryptValues(r.cipher, &firstName, &lastName, &email); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	_, err = stmt.Exec(firstName, lastName, email, r.cipher.BlindIndex(user.Email), user.LastModified, user.Id)
 	if err != nil {
 		r.log.Error(err.Error(), err)
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return errEmailTaken()
+		}
 		return rest_errors.NewInternalServerError("db error")
 	}
 
@@ -138,16 +223,86 @@ func (r *usersRepository) UpdateAdmin(user *domain.User) rest_errors.RestErr {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(user.FirstName, user.LastName, user.Email, user.Password, user.Status, user.Role, user.LastModified, user.Id)
+	firstName, lastName, email := user.FirstName, user.LastName, user.Email
+	if err := encryptValues(r.cipher, &firstName, &lastName, &email); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	_, err = stmt.Exec(firstName, lastName, email, r.cipher.BlindIndex(user.Email), user.Status, user.Role, user.LastModified, user.Id)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return errEmailTaken()
+		}
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	return nil
+}
+
+// UpdateEmail sets the email of a user, it fails with conflict when another
+// account already uses it.
+func (r *usersRepository) UpdateEmail(id int64, email, modifiedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryUpdateEmail)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	encrypted := email
+	if err := encryptValues(r.cipher, &encrypted); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if _, err := stmt.Exec(encrypted, r.cipher.BlindIndex(email), modifiedAt, id); err != nil {
+		r.log.Error(err.Error(), err)
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return errEmailTaken()
+		}
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *usersRepository) UpdatePassword(id int64, password, modifiedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryUpdatePassword)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(password, modifiedAt, id); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+func (r *usersRepository) SetStatus(id int64, from, to, modifiedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(querySetUserStatus)
 	if err != nil {
 		r.log.Error(err.Error(), err)
 		return rest_errors.NewInternalServerError("db error")
 	}
+	defer stmt.Close()
 
+	result, err := stmt.Exec(to, modifiedAt, id, from)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return rest_errors.NewNotFoundError("user not found")
+	}
 	return nil
 }
 
-func (r *usersRepository) Delete(id int64) rest_errors.RestErr {
+// Delete soft deletes a user, the row is kept until it's purged.
+func (r *usersRepository) Delete(id int64, deletedAt string) rest_errors.RestErr {
 	stmt, err := r.db.Prepare(queryDeleteUser)
 	if err != nil {
 		r.log.Error(err.Error(), err)
@@ -155,9 +310,255 @@ func (r *usersRepository) Delete(id int64) rest_errors.RestErr {
 	}
 	defer stmt.Close()
 
+	if _, err = stmt.Exec(deletedAt, id); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+// Restore cancels the deletion of a soft deleted user, putting it back in
+// the status it had before and returning that status. It fails with not
+// found when the user isn't deleted, which includes users already purged.
+func (r *usersRepository) Restore(id int64) (string, rest_errors.RestErr) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return "", rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	var status string
+	var before sql.NullString
+	if err := tx.QueryRow(queryLockRestoredUser, id).Scan(&status, &before); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return "", rest_errors.NewNotFoundError("deleted user not found")
+		}
+		r.log.Error(err.Error(), err)
+		return "", rest_errors.NewInternalServerError("db error")
+	}
+	if status != domain.StatusDeleted {
+		return "", rest_errors.NewNotFoundError("deleted user not found")
+	}
+	restored := before.String
+	if restored == "" {
+		restored = domain.StatusActive
+	}
+
+	if _, err := tx.Exec(queryRestoreUser, restored, id); err != nil {
+		r.log.Error(err.Error(), err)
+		return "", rest_errors.NewInternalServerError("db error")
+	}
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return "", rest_errors.NewInternalServerError("db error")
+	}
+	return restored, nil
+}
+
+// ListDeleted returns up to limit users soft deleted at or before the given
+// date, oldest first.
+func (r *usersRepository) ListDeleted(before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryListDeleted)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(before, limit)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0)
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.Role, &user.DeletedAt); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		if err := decryptValues(r.cipher, &user.FirstName, &user.LastName, &user.Email); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return users, nil
+}
+
+// RecordLogin stores the time of the last successful login of a user and
+// drops their dormancy warning.
+func (r *usersRepository) RecordLogin(id int64, loggedInAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryRecordLogin)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err = stmt.Exec(loggedInAt, id); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
+
+// ListInactive returns up to limit active users that weren't warned about
+// dormancy and haven't logged in since the given date.
+func (r *usersRepository) ListInactive(before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	return r.listByLastLogin(queryListInactive, before, limit)
+}
+
+// ListDormancyWarned returns up to limit active users warned about dormancy
+// at or before the given date, oldest warning first.
+func (r *usersRepository) ListDormancyWarned(before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	return r.listByLastLogin(queryListDormancyWarned, before, limit)
+}
+
+func (r *usersRepository) listByLastLogin(query string, before string, limit int) ([]domain.User, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(before, limit)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0)
+	for rows.Next() {
+		var user domain.User
+		var lastLoginAt sql.NullString
+		if err := rows.Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.Role, &lastLoginAt); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		user.LastLoginAt = lastLoginAt.String
+		if err := decryptValues(r.cipher, &user.FirstName, &user.LastName, &user.Email); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return users, nil
+}
+
+// MarkDormancyWarned records that an active user was warned about dormancy.
+// It returns false when they already were, or aren't active anymore, so a
+// user is only warned once.
+func (r *usersRepository) MarkDormancyWarned(id int64, warnedAt string) (bool, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryMarkDormancyWarned)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(warnedAt, id)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	return affected == 1, nil
+}
+
+// UnmarkDormancyWarned releases a warning claimed with MarkDormancyWarned
+// that couldn't be sent.
+func (r *usersRepository) UnmarkDormancyWarned(id int64) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryUnmarkDormancyWarned)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
 	if _, err = stmt.Exec(id); err != nil {
 		r.log.Error(err.Error(), err)
 		return rest_errors.NewInternalServerError("db error")
 	}
 	return nil
 }
+
+// MarkDormant moves a warned active user to the dormant state and drops the
+// warning, so they're warned again if they become inactive once more. It
+// fails with not found when the user isn't warned and active anymore.
+func (r *usersRepository) MarkDormant(id int64, modifiedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryMarkDormant)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(modifiedAt, id)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return rest_errors.NewNotFoundError("warned user not found")
+	}
+	return nil
+}
+
+// Purge removes the addresses and payment options of a soft deleted user and
+// anonymizes its row, which is kept so other services can still resolve the
+// id. It fails with a conflict when the user is no longer deleted.
+func (r *usersRepository) Purge(id int64, purgedAt string) rest_errors.RestErr {
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRow(queryLockDeletedUser, id).Scan(&status); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return rest_errors.NewNotFoundError("user not found")
+		}
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	if status != domain.StatusDeleted {
+		return rest_errors.NewRestError("user is not deleted", http.StatusConflict, "conflict")
+	}
+
+	for _, query := range []string{queryPurgePaymentOptions, queryPurgeAddresses, queryPurgeTokens, queryPurgeRecoveryCodes, queryPurgeTwoFactor, queryPurgeLoginEvents, queryPurgeAccessTokens} {
+		if _, err := tx.Exec(query, id); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+	}
+	if _, err := tx.Exec(queryAnonymizeUser, purgedAt, id); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
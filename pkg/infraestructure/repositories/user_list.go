@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+const (
+	queryListUsers  = "SELECT id, first_name, last_name, email, date_created, status, role, last_login_at FROM users"
+	queryCountUsers = "SELECT COUNT(*) FROM users"
+)
+
+// prefixScanLimit is the most users a listing by prefix reads, see List.
+var prefixScanLimit = 5000
+
+// userSortColumns maps the fields users can be sorted on to their column,
+// only these ever make it into a listing query.
+var userSortColumns = map[string]string{
+	domain.UserSortId:          "id",
+	domain.UserSortDateCreated: "date_created",
+	domain.UserSortStatus:      "status",
+	domain.UserSortRole:        "role",
+}
+
+// List returns the page of users matching filter along with how many users
+// match it in total.
+//
+// Names and emails are stored encrypted, so a filter with a prefix can't be
+// applied by the database: every user matching the rest of the filter is
+// read, decrypted and matched here. At most prefixScanLimit users are read,
+// listings matching more fail with bad request and have to be narrowed with
+// the other filters.
+func (r *usersRepository) List(filter domain.UserFilter) ([]domain.User, int, rest_errors.RestErr) {
+	sortColumn, ok := userSortColumns[filter.Sort]
+	if !ok {
+		sortColumn = userSortColumns[domain.UserSortId]
+	}
+	where, args := userFilterConditions(filter)
+
+	if filter.Prefix != "" {
+		return r.listByPrefix(filter, sortColumn, where, args)
+	}
+
+	var total int
+	if err := r.db.QueryRow(queryCountUsers+whereClause(where)+";", args...).Scan(&total); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, 0, rest_errors.NewInternalServerError("db error")
+	}
+
+	if filter.After != nil {
+		condition, cursorArgs := cursorCondition(sortColumn, filter.Desc, filter.After)
+		where = append(where, condition)
+		args = append(args, cursorArgs...)
+	}
+	query := queryListUsers + whereClause(where) + orderClause(sortColumn, filter.Desc) + " LIMIT ? OFFSET ?;"
+	args = append(args, filter.Limit, filter.Offset)
+
+	users, err := r.queryListedUsers(query, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *usersRepository) listByPrefix(filter domain.UserFilter, sortColumn string, where []string, args []interface{}) ([]domain.User, int, rest_errors.RestErr) {
+	// one more than the limit tells whether there were more to read
+	query := queryListUsers + whereClause(where) + orderClause(sortColumn, filter.Desc) + " LIMIT ?;"
+	users, err := r.queryListedUsers(query, append(args, prefixScanLimit+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(users) > prefixScanLimit {
+		return nil, 0, rest_errors.NewBadRequestError(fmt.Sprintf("more than %d users match the filter, narrow it down to filter by prefix", prefixScanLimit))
+	}
+
+	prefix := strings.ToLower(filter.Prefix)
+	matching := make([]domain.User, 0)
+	for _, user := range users {
+		if strings.HasPrefix(strings.ToLower(user.Email), prefix) ||
+			strings.HasPrefix(strings.ToLower(user.FirstName), prefix) ||
+			strings.HasPrefix(strings.ToLower(user.LastName), prefix) {
+			matching = append(matching, user)
+		}
+	}
+	total := len(matching)
+
+	page := matching
+	if filter.After != nil {
+		page = page[:0:0]
+		for _, user := range matching {
+			if isAfterCursor(user, filter.Desc, filter.After) {
+				page = append(page, user)
+			}
+		}
+	}
+	if filter.Offset >= len(page) {
+		return []domain.User{}, total, nil
+	}
+	page = page[filter.Offset:]
+	if filter.Limit < len(page) {
+		page = page[:filter.Limit]
+	}
+	return page, total, nil
+}
+
+func (r *usersRepository) queryListedUsers(query string, args []interface{}) ([]domain.User, rest_errors.RestErr) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0)
+	for rows.Next() {
+		var user domain.User
+		var lastLoginAt sql.NullString
+		if err := rows.Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.Role, &lastLoginAt); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		user.LastLoginAt = lastLoginAt.String
+		if err := decryptValues(r.cipher, &user.FirstName, &user.LastName, &user.Email); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	return users, nil
+}
+
+// userFilterConditions returns the conditions of the WHERE clause selecting
+// the users matching filter, prefix aside, and their arguments.
+func userFilterConditions(filter domain.UserFilter) ([]string, []interface{}) {
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if filter.Status != "" {
+		where = append(where, "status=?")
+		args = append(args, filter.Status)
+	} else {
+		where = append(where, "status<>?")
+		args = append(args, domain.StatusPurged)
+	}
+	if filter.Role != "" {
+		where = append(where, "role=?")
+		args = append(args, filter.Role)
+	}
+	if filter.CreatedFrom != "" {
+		where = append(where, "date_created>=?")
+		args = append(args, filter.CreatedFrom)
+	}
+	if filter.CreatedTo != "" {
+		where = append(where, "date_created<=?")
+		args = append(args, filter.CreatedTo)
+	}
+	return where, args
+}
+
+// cursorCondition returns the condition selecting the users sorted after the
+// cursor, ids break ties between users with the same value.
+func cursorCondition(sortColumn string, desc bool, cursor *domain.UserCursor) (string, []interface{}) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	if sortColumn == "id" {
+		return "id" + op + "?", []interface{}{cursor.Id}
+	}
+	return "(" + sortColumn + op + "? OR (" + sortColumn + "=? AND id" + op + "?))", []interface{}{cursor.Value, cursor.Value, cursor.Id}
+}
+
+// isAfterCursor is cursorCondition for users already read.
+func isAfterCursor(user domain.User, desc bool, cursor *domain.UserCursor) bool {
+	var cmp int
+	switch cursor.Sort {
+	case domain.UserSortDateCreated:
+		cmp = strings.Compare(user.DateCreated, cursor.Value)
+	case domain.UserSortStatus:
+		cmp = strings.Compare(user.Status, cursor.Value)
+	case domain.UserSortRole:
+		cmp = strings.Compare(user.Role, cursor.Value)
+	}
+	if cmp == 0 {
+		switch {
+		case user.Id < cursor.Id:
+			cmp = -1
+		case user.Id > cursor.Id:
+			cmp = 1
+		}
+	}
+	if desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func orderClause(sortColumn string, desc bool) string {
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+	if sortColumn == "id" {
+		return " ORDER BY id" + direction
+	}
+	return " ORDER BY " + sortColumn + direction + ", id" + direction
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var listedColumns = []string{"id", "first_name", "last_name", "email", "date_created", "status", "role", "last_login_at"}
+
+func TestListUsers(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountUsers+" WHERE status=? AND role=? AND date_created>=? AND date_created<=?;")).
+			WithArgs("active", "admin", "2021-01-01 00:00:00", "2021-12-31 23:59:59").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+		rows := sqlmock.NewRows(listedColumns).
+			AddRow(test.Id, test.FirstName, test.LastName, test.Email, test.DateCreated, "active", "admin", "2021-12-01 10:00:00")
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUsers+" WHERE status=? AND role=? AND date_created>=? AND date_created<=? AND (date_created<? OR (date_created=? AND id<?)) ORDER BY date_created DESC, id DESC LIMIT ? OFFSET ?;")).
+			WithArgs("active", "admin", "2021-01-01 00:00:00", "2021-12-31 23:59:59", "2021-06-01 00:00:00", "2021-06-01 00:00:00", 40, 11, 0).
+			WillReturnRows(rows)
+
+		users, total, err := repo.List(domain.UserFilter{
+			Status:      "active",
+			Role:        "admin",
+			CreatedFrom: "2021-01-01 00:00:00",
+			CreatedTo:   "2021-12-31 23:59:59",
+			Sort:        domain.UserSortDateCreated,
+			Desc:        true,
+			Limit:       11,
+			After:       &domain.UserCursor{Sort: domain.UserSortDateCreated, Desc: true, Value: "2021-06-01 00:00:00", Id: 40},
+		})
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 12, total)
+		if assert.Len(t, users, 1) {
+			assert.EqualValues(t, test.Email, users[0].Email)
+			assert.EqualValues(t, "2021-12-01 10:00:00", users[0].LastLoginAt)
+		}
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("SkipsPurged", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountUsers + " WHERE status<>?;")).
+			WithArgs("purged").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		// unknown sort fields fall back to the id
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUsers+" WHERE status<>? ORDER BY id ASC LIMIT ? OFFSET ?;")).
+			WithArgs("purged", 21, 40).
+			WillReturnRows(sqlmock.NewRows(listedColumns))
+
+		users, total, err := repo.List(domain.UserFilter{Sort: "email; DROP TABLE users", Limit: 21, Offset: 40})
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, total)
+		assert.Len(t, users, 0)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(listedColumns).
+			AddRow(1, "Oscar", "Isaac", "oscaac@gmail.com", test.DateCreated, "active", "user", nil).
+			AddRow(2, "Ana", "Osorio", "ana@gmail.com", test.DateCreated, "active", "user", nil).
+			AddRow(3, "Juan", "Perez", "juan@gmail.com", test.DateCreated, "active", "user", nil).
+			AddRow(4, "Olga", "Diaz", "olga@gmail.com", test.DateCreated, "active", "user", nil)
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUsers+" WHERE status<>? AND date_created>=? ORDER BY id ASC LIMIT ?;")).
+			WithArgs("purged", "2006-01-01 00:00:00", prefixScanLimit+1).
+			WillReturnRows(rows)
+
+		users, total, err := repo.List(domain.UserFilter{
+			CreatedFrom: "2006-01-01 00:00:00",
+			Prefix:      "O",
+			Sort:        domain.UserSortId,
+			Limit:       1,
+			After:       &domain.UserCursor{Sort: domain.UserSortId, Value: "1", Id: 1},
+		})
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 3, total)
+		if assert.Len(t, users, 1) {
+			assert.EqualValues(t, 2, users[0].Id)
+		}
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("PrefixScanLimit", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+		defer func(limit int) { prefixScanLimit = limit }(prefixScanLimit)
+		prefixScanLimit = 2
+
+		rows := sqlmock.NewRows(listedColumns).
+			AddRow(1, "Oscar", "Isaac", "oscaac@gmail.com", test.DateCreated, "active", "user", nil).
+			AddRow(2, "Ana", "Osorio", "ana@gmail.com", test.DateCreated, "active", "user", nil).
+			AddRow(3, "Olga", "Diaz", "olga@gmail.com", test.DateCreated, "active", "user", nil)
+		mock.ExpectQuery(regexp.QuoteMeta(queryListUsers+" WHERE status<>? ORDER BY id ASC LIMIT ?;")).
+			WithArgs("purged", 3).
+			WillReturnRows(rows)
+
+		users, _, err := repo.List(domain.UserFilter{Prefix: "O", Sort: domain.UserSortId, Limit: 1})
+
+		assert.Nil(t, users)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status())
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("DbError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountUsers)).WillReturnError(errors.New(""))
+
+		users, _, err := repo.List(domain.UserFilter{Limit: 21})
+
+		assert.Nil(t, users)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+		assert.EqualValues(t, "db error", err.Message())
+	})
+}
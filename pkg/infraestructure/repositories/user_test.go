@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -38,6 +39,16 @@ type loggerMock struct {
 
 func (l *loggerMock) Error(msg string, err error, tags ...zap.Field) {}
 func (l *loggerMock) Info(msg string, tags ...zap.Field)             {}
+func (l *loggerMock) Warn(msg string, tags ...zap.Field)             {}
+
+// cipherMock stores values as they are, so the expectations of the tests
+// can keep using plaintext.
+type cipherMock struct{}
+
+func (c *cipherMock) Encrypt(s string) (string, error) { return s, nil }
+func (c *cipherMock) Decrypt(s string) (string, error) { return s, nil }
+func (c *cipherMock) BlindIndex(s string) string       { return "bidx:" + s }
+func (c *cipherMock) NeedsRotation(s string) bool      { return false }
 
 func TestGet(t *testing.T) {
 	query := "SELECT id, first_name, last_name, email, date_created, status, role FROM users WHERE id=\\?;"
@@ -45,7 +56,7 @@ func TestGet(t *testing.T) {
 	t.Run("NoError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -66,7 +77,7 @@ func TestGet(t *testing.T) {
 	t.Run("UserNotFound", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -83,7 +94,7 @@ func TestGet(t *testing.T) {
 	t.Run("QueryingError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -99,7 +110,7 @@ func TestGet(t *testing.T) {
 	t.Run("PrepareError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -116,19 +127,19 @@ func TestGet(t *testing.T) {
 }
 
 func TestGetByEmail(t *testing.T) {
-	query := "SELECT id, first_name, last_name, email, date_created, status, role, password FROM users WHERE email=\\?;"
+	query := "SELECT id, first_name, last_name, email, date_created, status, role, password FROM users WHERE email_bidx=\\? OR \\(email_bidx IS NULL AND email=\\?\\);"
 
 	t.Run("NoError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
 		rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "date_created", "status", "role", "password"}).
 			AddRow(test.Id, test.FirstName, test.LastName, test.Email, test.DateCreated, test.Status, test.Role, test.Password)
-		mock.ExpectPrepare(query).ExpectQuery().WithArgs(test.Email).WillReturnRows(rows)
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs("bidx:"+test.Email, test.Email).WillReturnRows(rows)
 
 		user, err := repo.GetByEmail(test.Email)
 
@@ -141,12 +152,12 @@ func TestGetByEmail(t *testing.T) {
 	t.Run("UserNotFound", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectQuery().WithArgs(test.Email).WillReturnError(errors.New("error: no rows in result set"))
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs("bidx:"+test.Email, test.Email).WillReturnError(errors.New("error: no rows in result set"))
 
 		user, err := repo.GetByEmail(test.Email)
 		assert.Nil(t, user)
@@ -158,12 +169,12 @@ func TestGetByEmail(t *testing.T) {
 	t.Run("QueryingError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectQuery().WithArgs(test.Email).WillReturnError(errors.New("error"))
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs("bidx:"+test.Email, test.Email).WillReturnError(errors.New("error"))
 		user, err := repo.GetByEmail(test.Email)
 		assert.Nil(t, user)
 		assert.NotNil(t, err)
@@ -174,7 +185,7 @@ func TestGetByEmail(t *testing.T) {
 	t.Run("PrepareError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -191,17 +202,18 @@ func TestGetByEmail(t *testing.T) {
 }
 
 func TestSave(t *testing.T) {
-	query := "INSERT INTO users\\(first_name, last_name, email, date_created, status, password, role\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?\\);"
+	query := "INSERT INTO users\\(first_name, last_name, email, email_bidx, date_created, status, password, role\\) VALUES\\(\\?, \\?, \\?, \\?, \\?, \\?, \\?, \\?\\);"
 
 	t.Run("NoError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, test.DateCreated, test.Status, test.Password, test.Role).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountLegacyEmail)).WithArgs(test.Email).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, "bidx:"+test.Email, test.DateCreated, test.Status, test.Password, test.Role).WillReturnResult(sqlmock.NewResult(1, 1))
 
 		err := repo.Save(&test)
 
@@ -211,12 +223,13 @@ func TestSave(t *testing.T) {
 	t.Run("SavingUser", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, test.DateCreated, test.Status, test.Password, test.Role).WillReturnError(errors.New("..."))
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountLegacyEmail)).WithArgs(test.Email).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, "bidx:"+test.Email, test.DateCreated, test.Status, test.Password, test.Role).WillReturnError(errors.New("..."))
 
 		err := repo.Save(&test)
 
@@ -225,14 +238,32 @@ func TestSave(t *testing.T) {
 		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	})
 
+	t.Run("LegacyEmailTaken", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountLegacyEmail)).WithArgs(test.Email).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		err := repo.Save(&test)
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("PrepareError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
+		mock.ExpectQuery(regexp.QuoteMeta(queryCountLegacyEmail)).WithArgs(test.Email).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 		mock.ExpectPrepare(query).WillReturnError(sql.ErrConnDone)
 
 		err := repo.Save(&test)
@@ -244,17 +275,17 @@ func TestSave(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
-	query := "UPDATE users SET first_name=\\?, last_name=\\?, email=\\?, password=\\?, last_modified=\\? WHERE id=\\?;"
+	query := "UPDATE users SET first_name=\\?, last_name=\\?, email=\\?, email_bidx=\\?, last_modified=\\? WHERE id=\\?;"
 
 	t.Run("NoError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, "random@gmail.com", test.Password, "2006-01-02 15:04:05", test.Id).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, "random@gmail.com", "bidx:random@gmail.com", "2006-01-02 15:04:05", test.Id).WillReturnResult(sqlmock.NewResult(1, 1))
 
 		test.Email = "random@gmail.com"
 		test.LastModified = "2006-01-02 15:04:05"
@@ -267,12 +298,12 @@ func TestUpdate(t *testing.T) {
 	t.Run("ExecError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, test.Password, test.LastModified, test.Id).WillReturnError(sql.ErrConnDone)
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, "bidx:"+test.Email, test.LastModified, test.Id).WillReturnError(sql.ErrConnDone)
 
 		err := repo.Update(&test)
 		assert.NotNil(t, err)
@@ -280,10 +311,25 @@ func TestUpdate(t *testing.T) {
 		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	})
 
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WillReturnError(errors.New("Error 1062: Duplicate entry 'bidx' for key 'users.email_bidx'"))
+
+		err := repo.Update(&test)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+
 	t.Run("PrepareError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -299,17 +345,17 @@ func TestUpdate(t *testing.T) {
 }
 
 func TestUpdateAdmin(t *testing.T) {
-	query := "UPDATE users SET first_name=\\?, last_name=\\?, email=\\?, password=\\?, status=\\?, role=\\?, last_modified=\\? WHERE id=\\?;"
+	query := "UPDATE users SET first_name=\\?, last_name=\\?, email=\\?, email_bidx=\\?, status=\\?, role=\\?, last_modified=\\? WHERE id=\\?;"
 
 	t.Run("NoError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, "random@gmail.com", test.Password, test.Status, test.Role, "2006-01-02 15:04:05", test.Id).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, "random@gmail.com", "bidx:random@gmail.com", test.Status, test.Role, "2006-01-02 15:04:05", test.Id).WillReturnResult(sqlmock.NewResult(1, 1))
 
 		test.Email = "random@gmail.com"
 		test.LastModified = "2006-01-02 15:04:05"
@@ -322,12 +368,12 @@ func TestUpdateAdmin(t *testing.T) {
 	t.Run("ExecError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, test.Password, test.Status, test.Role, test.LastModified, test.Id).WillReturnError(sql.ErrConnDone)
+		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.FirstName, test.LastName, test.Email, "bidx:"+test.Email, test.Status, test.Role, test.LastModified, test.Id).WillReturnError(sql.ErrConnDone)
 
 		err := repo.UpdateAdmin(&test)
 		assert.NotNil(t, err)
@@ -338,7 +384,7 @@ func TestUpdateAdmin(t *testing.T) {
 	t.Run("PrepareError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
@@ -354,19 +400,19 @@ func TestUpdateAdmin(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
-	query := "UPDATE users SET status='inactive' WHERE id=\\?;"
+	query := regexp.QuoteMeta(queryDeleteUser)
 
 	t.Run("NoError", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(1, 1))
 
-		err := repo.Delete(test.Id)
+		err := repo.Delete(test.Id, "2026-10-17 12:00:00")
 
 		assert.Nil(t, err)
 	})
@@ -374,14 +420,14 @@ func TestDelete(t *testing.T) {
 	t.Run("ErrorPrepare", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
 		mock.ExpectPrepare(query).WillReturnError(sql.ErrConnDone)
 
-		err := repo.Delete(test.Id)
+		err := repo.Delete(test.Id, "2026-10-17 12:00:00")
 
 		assert.NotNil(t, err)
 	})
@@ -389,15 +435,336 @@ func TestDelete(t *testing.T) {
 	t.Run("ErrorExec", func(t *testing.T) {
 		db, mock := NewMock()
 
-		repo := &usersRepository{db: db, log: &loggerMock{}}
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnError(sql.ErrConnDone)
+
+		err := repo.Delete(test.Id, "2026-10-17 12:00:00")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestUpdatePassword(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	mock.ExpectPrepare(regexp.QuoteMeta(queryUpdatePassword)).ExpectExec().WithArgs("hash", "2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.UpdatePassword(test.Id, "hash", "2026-10-17 12:00:00")
+
+	assert.Nil(t, err)
+}
+
+func TestUpdateEmail(t *testing.T) {
+	query := regexp.QuoteMeta(queryUpdateEmail)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("new@gmail.com", "bidx:new@gmail.com", "2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UpdateEmail(test.Id, "new@gmail.com", "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WillReturnError(errors.New("Error 1062: Duplicate entry 'bidx' for key 'users.email_bidx'"))
+
+		err := repo.UpdateEmail(test.Id, "new@gmail.com", "2026-10-17 12:00:00")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, "email already in use", err.Message())
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+	})
+}
+
+func TestSetStatus(t *testing.T) {
+	query := regexp.QuoteMeta(querySetUserStatus)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("active", "2026-10-17 12:00:00", test.Id, "pending_verification").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.SetStatus(test.Id, "pending_verification", "active", "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("StatusChanged", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("active", "2026-10-17 12:00:00", test.Id, "pending_verification").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.SetStatus(test.Id, "pending_verification", "active", "2026-10-17 12:00:00")
+
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(queryLockRestoredUser)).WithArgs(test.Id).
+			WillReturnRows(sqlmock.NewRows([]string{"status", "status_before_delete"}).AddRow("deleted", "suspended"))
+		mock.ExpectExec(regexp.QuoteMeta(queryRestoreUser)).WithArgs("suspended", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, err := repo.Restore(test.Id)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "suspended", status)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("DeletedBeforeTracking", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(queryLockRestoredUser)).WithArgs(test.Id).
+			WillReturnRows(sqlmock.NewRows([]string{"status", "status_before_delete"}).AddRow("deleted", nil))
+		mock.ExpectExec(regexp.QuoteMeta(queryRestoreUser)).WithArgs("active", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, err := repo.Restore(test.Id)
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "active", status)
+	})
+
+	t.Run("NotDeleted", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(queryLockRestoredUser)).WithArgs(test.Id).
+			WillReturnRows(sqlmock.NewRows([]string{"status", "status_before_delete"}).AddRow("purged", nil))
+		mock.ExpectRollback()
+
+		status, err := repo.Restore(test.Id)
+
+		assert.EqualValues(t, "", status)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestListDeleted(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "date_created", "status", "role", "deleted_at"}).
+			AddRow(test.Id, test.FirstName, test.LastName, test.Email, test.DateCreated, "deleted", test.Role, "2026-09-01 10:00:00")
+		mock.ExpectPrepare(regexp.QuoteMeta(queryListDeleted)).ExpectQuery().WithArgs("2026-09-17 12:00:00", 100).WillReturnRows(rows)
+
+		users, err := repo.ListDeleted("2026-09-17 12:00:00", 100)
+
+		assert.Nil(t, err)
+		assert.Len(t, users, 1)
+		assert.EqualValues(t, "2026-09-01 10:00:00", users[0].DeletedAt)
+	})
+}
+
+func TestRecordLogin(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	mock.ExpectPrepare(regexp.QuoteMeta(queryRecordLogin)).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.RecordLogin(test.Id, "2026-10-17 12:00:00")
+
+	assert.Nil(t, err)
+}
+
+func TestListInactive(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "email", "date_created", "status", "role", "last_login_at"}).
+		AddRow(test.Id, test.FirstName, test.LastName, test.Email, test.DateCreated, "active", test.Role, "2025-11-01 10:00:00").
+		AddRow(2, test.FirstName, test.LastName, "isaac@gmail.com", test.DateCreated, "active", test.Role, nil)
+	mock.ExpectPrepare(regexp.QuoteMeta(queryListInactive)).ExpectQuery().WithArgs("2025-11-16 12:00:00", 100).WillReturnRows(rows)
+
+	users, err := repo.ListInactive("2025-11-16 12:00:00", 100)
+
+	assert.Nil(t, err)
+	if assert.Len(t, users, 2) {
+		assert.EqualValues(t, "2025-11-01 10:00:00", users[0].LastLoginAt)
+		// never logged in
+		assert.EqualValues(t, "", users[1].LastLoginAt)
+	}
+}
+
+func TestMarkDormancyWarned(t *testing.T) {
+	t.Run("Claimed", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(regexp.QuoteMeta(queryMarkDormancyWarned)).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		claimed, err := repo.MarkDormancyWarned(test.Id, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("AlreadyWarned", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(regexp.QuoteMeta(queryMarkDormancyWarned)).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		claimed, err := repo.MarkDormancyWarned(test.Id, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+	})
+}
+
+func TestMarkDormant(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(regexp.QuoteMeta(queryMarkDormant)).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.MarkDormant(test.Id, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("LoggedInSince", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(regexp.QuoteMeta(queryMarkDormant)).ExpectExec().WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.MarkDormant(test.Id, "2026-10-17 12:00:00")
+
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(queryLockDeletedUser)).WithArgs(test.Id).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("deleted"))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgePaymentOptions)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgeAddresses)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgeTokens)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgeRecoveryCodes)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgeTwoFactor)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgeLoginEvents)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 12))
+		mock.ExpectExec(regexp.QuoteMeta(queryPurgeAccessTokens)).WithArgs(test.Id).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta(queryAnonymizeUser)).WithArgs("2026-10-17 12:00:00", test.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.Purge(test.Id, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Restored", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &usersRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
 		defer func() {
 			repo.db.Close()
 		}()
 
-		mock.ExpectPrepare(query).ExpectExec().WithArgs(test.Id).WillReturnError(sql.ErrConnDone)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(queryLockDeletedUser)).WithArgs(test.Id).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("active"))
+		mock.ExpectRollback()
 
-		err := repo.Delete(test.Id)
+		err := repo.Purge(test.Id, "2026-10-17 12:00:00")
 
 		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusConflict, err.Status())
+		assert.Nil(t, mock.ExpectationsWereMet())
 	})
 }
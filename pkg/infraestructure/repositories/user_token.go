@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+	"github.com/FacuBar/bookstore_utils-go/rest_errors"
+)
+
+var (
+	onceUserTokenRepo     sync.Once
+	instanceUserTokenRepo *userTokenRepository
+)
+
+// userTokenRepository stores the tokens sent to users, with their payload
+// encrypted with the field cipher.
+type userTokenRepository struct {
+	db     *sql.DB
+	log    ports.UserLogger
+	cipher ports.FieldCipher
+}
+
+func NewUserTokenRepository(db *sql.DB, logger ports.UserLogger, cipher ports.FieldCipher) ports.UserTokenRepository {
+	onceUserTokenRepo.Do(func() {
+		instanceUserTokenRepo = &userTokenRepository{
+			db:     db,
+			log:    logger,
+			cipher: cipher,
+		}
+	})
+	return instanceUserTokenRepo
+}
+
+const (
+	queryInsertUserToken      = "INSERT INTO user_tokens(user_id, purpose, token_hash, payload, expires_at, date_created) VALUES(?, ?, ?, ?, ?, ?);"
+	queryGetUserTokenByHash   = "SELECT id, user_id, purpose, token_hash, payload, expires_at, used_at, date_created FROM user_tokens WHERE purpose=? AND token_hash=?;"
+	queryGetLatestUserToken   = "SELECT id, user_id, purpose, token_hash, payload, expires_at, used_at, date_created FROM user_tokens WHERE user_id=? AND purpose=? ORDER BY date_created DESC, id DESC LIMIT 1;"
+	queryMarkUserTokenUsed    = "UPDATE user_tokens SET used_at=? WHERE id=? AND used_at IS NULL;"
+	queryInvalidateUserTokens = "UPDATE user_tokens SET used_at=? WHERE user_id=? AND purpose=? AND used_at IS NULL;"
+)
+
+func (r *userTokenRepository) Save(t *domain.UserToken) rest_errors.RestErr {
+	var payload interface{}
+	if t.Payload != "" {
+		encrypted := t.Payload
+		if err := encryptValues(r.cipher, &encrypted); err != nil {
+			r.log.Error(err.Error(), err)
+			return rest_errors.NewInternalServerError("db error")
+		}
+		payload = encrypted
+	}
+
+	stmt, err := r.db.Prepare(queryInsertUserToken)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	insertResult, err := stmt.Exec(t.UserID, t.Purpose, t.TokenHash, payload, t.ExpiresAt, t.DateCreated)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+
+	id, err := insertResult.LastInsertId()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	t.Id = id
+	return nil
+}
+
+func (r *userTokenRepository) GetByHash(purpose, hash string) (*domain.UserToken, rest_errors.RestErr) {
+	return r.getOne(queryGetUserTokenByHash, purpose, hash)
+}
+
+// GetLatest returns the last token issued to a user for purpose, used or not.
+func (r *userTokenRepository) GetLatest(userId int64, purpose string) (*domain.UserToken, rest_errors.RestErr) {
+	return r.getOne(queryGetLatestUserToken, userId, purpose)
+}
+
+func (r *userTokenRepository) getOne(query string, args ...interface{}) (*domain.UserToken, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(query)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	var t domain.UserToken
+	var payload, usedAt sql.NullString
+	if err := stmt.QueryRow(args...).Scan(&t.Id, &t.UserID, &t.Purpose, &t.TokenHash, &payload, &t.ExpiresAt, &usedAt, &t.DateCreated); err != nil {
+		if strings.Contains(err.Error(), errNoRow) {
+			return nil, rest_errors.NewNotFoundError("token not found")
+		}
+		r.log.Error(err.Error(), err)
+		return nil, rest_errors.NewInternalServerError("db error")
+	}
+	t.UsedAt = usedAt.String
+	if payload.Valid {
+		t.Payload = payload.String
+		if err := decryptValues(r.cipher, &t.Payload); err != nil {
+			r.log.Error(err.Error(), err)
+			return nil, rest_errors.NewInternalServerError("db error")
+		}
+	}
+	return &t, nil
+}
+
+func (r *userTokenRepository) MarkUsed(id int64, usedAt string) (bool, rest_errors.RestErr) {
+	stmt, err := r.db.Prepare(queryMarkUserTokenUsed)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(usedAt, id)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return false, rest_errors.NewInternalServerError("db error")
+	}
+	return affected > 0, nil
+}
+
+func (r *userTokenRepository) Invalidate(userId int64, purpose, usedAt string) rest_errors.RestErr {
+	stmt, err := r.db.Prepare(queryInvalidateUserTokens)
+	if err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(usedAt, userId, purpose); err != nil {
+		r.log.Error(err.Error(), err)
+		return rest_errors.NewInternalServerError("db error")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"database/sql"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FacuBar/bookstore_users-api/pkg/core/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var tokenColumns = []string{"id", "user_id", "purpose", "token_hash", "payload", "expires_at", "used_at", "date_created"}
+
+func TestSaveUserToken(t *testing.T) {
+	db, mock := NewMock()
+
+	repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+	defer func() {
+		repo.db.Close()
+	}()
+
+	token := domain.UserToken{
+		UserID:      1,
+		Purpose:     domain.TokenPurposeEmailVerification,
+		TokenHash:   "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		ExpiresAt:   "2026-10-18 12:00:00",
+		DateCreated: "2026-10-17 12:00:00",
+	}
+	mock.ExpectPrepare(regexp.QuoteMeta(queryInsertUserToken)).ExpectExec().
+		WithArgs(token.UserID, token.Purpose, token.TokenHash, nil, token.ExpiresAt, token.DateCreated).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	err := repo.Save(&token)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 7, token.Id)
+}
+
+func TestGetUserTokenByHash(t *testing.T) {
+	query := regexp.QuoteMeta(queryGetUserTokenByHash)
+
+	t.Run("NoError", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(tokenColumns).
+			AddRow(7, 1, domain.TokenPurposeEmailVerification, "hash", nil, "2026-10-18 12:00:00", nil, "2026-10-17 12:00:00")
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(domain.TokenPurposeEmailVerification, "hash").WillReturnRows(rows)
+
+		token, err := repo.GetByHash(domain.TokenPurposeEmailVerification, "hash")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, 7, token.Id)
+		assert.EqualValues(t, 1, token.UserID)
+		assert.EqualValues(t, "", token.UsedAt)
+	})
+
+	t.Run("Payload", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		rows := sqlmock.NewRows(tokenColumns).
+			AddRow(8, 1, domain.TokenPurposeEmailChange, "hash", "new@gmail.com", "2026-10-18 12:00:00", nil, "2026-10-17 12:00:00")
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(domain.TokenPurposeEmailChange, "hash").WillReturnRows(rows)
+
+		token, err := repo.GetByHash(domain.TokenPurposeEmailChange, "hash")
+
+		assert.Nil(t, err)
+		assert.EqualValues(t, "new@gmail.com", token.Payload)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectQuery().WithArgs(domain.TokenPurposeEmailVerification, "hash").WillReturnRows(sqlmock.NewRows(tokenColumns))
+
+		token, err := repo.GetByHash(domain.TokenPurposeEmailVerification, "hash")
+
+		assert.Nil(t, token)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, http.StatusNotFound, err.Status())
+	})
+
+	t.Run("ErrorPrepare", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).WillReturnError(sql.ErrConnDone)
+
+		token, err := repo.GetByHash(domain.TokenPurposeEmailVerification, "hash")
+
+		assert.Nil(t, token)
+		assert.EqualValues(t, http.StatusInternalServerError, err.Status())
+	})
+}
+
+func TestMarkUserTokenUsed(t *testing.T) {
+	query := regexp.QuoteMeta(queryMarkUserTokenUsed)
+
+	t.Run("Claimed", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		claimed, err := repo.MarkUsed(7, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("AlreadyUsed", func(t *testing.T) {
+		db, mock := NewMock()
+
+		repo := &userTokenRepository{db: db, log: &loggerMock{}, cipher: &cipherMock{}}
+		defer func() {
+			repo.db.Close()
+		}()
+
+		mock.ExpectPrepare(query).ExpectExec().WithArgs("2026-10-17 12:00:00", 7).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		claimed, err := repo.MarkUsed(7, "2026-10-17 12:00:00")
+
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+	})
+}
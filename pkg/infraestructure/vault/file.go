@@ -0,0 +1,120 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// fileVault is a CardVault backed by a JSON file on the local disk. It is
+// meant for development and tests, production deployments should point the
+// CardVault port to a PCI compliant provider.
+type fileVault struct {
+	mu    sync.Mutex
+	path  string
+	cards map[string]string
+}
+
+var ErrTokenNotFound = errors.New("card token not found")
+
+const tokenPrefix = "tok_"
+
+func NewFileVault(path string) (ports.CardVault, error) {
+	v := &fileVault{
+		path:  path,
+		cards: make(map[string]string),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return v, nil
+		}
+		return nil, err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &v.cards); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *fileVault) Tokenize(pan string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(b)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.cards[token] = pan
+	if err := v.flush(); err != nil {
+		delete(v.cards, token)
+		return "", err
+	}
+	return token, nil
+}
+
+func (v *fileVault) Detokenize(token string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pan, ok := v.cards[token]
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+	return pan, nil
+}
+
+func (v *fileVault) Delete(token string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pan, ok := v.cards[token]
+	if !ok {
+		return nil
+	}
+	delete(v.cards, token)
+	if err := v.flush(); err != nil {
+		v.cards[token] = pan
+		return err
+	}
+	return nil
+}
+
+// flush atomically rewrites the vault file, callers must hold v.mu.
+func (v *fileVault) flush() error {
+	b, err := json.Marshal(v.cards)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), v.path)
+}
@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileVault(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		v, err := NewFileVault(path)
+		assert.Nil(t, err)
+
+		token, err := v.Tokenize("4242424242424242")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(token, tokenPrefix))
+		assert.NotContains(t, token, "4242")
+
+		pan, err := v.Detokenize(token)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "4242424242424242", pan)
+	})
+
+	t.Run("Persists", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		v, _ := NewFileVault(path)
+		token, _ := v.Tokenize("5555555555554444")
+
+		reopened, err := NewFileVault(path)
+		assert.Nil(t, err)
+		pan, err := reopened.Detokenize(token)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "5555555555554444", pan)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		v, _ := NewFileVault(path)
+		token, _ := v.Tokenize("5555555555554444")
+
+		assert.Nil(t, v.Delete(token))
+		_, err := v.Detokenize(token)
+		assert.EqualValues(t, ErrTokenNotFound, err)
+
+		b, _ := ioutil.ReadFile(path)
+		assert.NotContains(t, string(b), "5555555555554444")
+	})
+}
@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// httpVault is a CardVault backed by a remote tokenization service. Card
+// numbers are posted to <base>/tokens, which answers with their token, and
+// read back or deleted at <base>/tokens/<token>.
+type httpVault struct {
+	base   string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPVault returns a CardVault that keeps the cards in the tokenization
+// service at baseURL, authenticating with apiKey. The service is only
+// reached over https, card numbers never travel in clear text.
+func NewHTTPVault(baseURL, apiKey string) (ports.CardVault, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault url: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("invalid vault url: expected an https address")
+	}
+	if apiKey == "" {
+		return nil, errors.New("missing vault api key")
+	}
+	return &httpVault{
+		base:   strings.TrimSuffix(baseURL, "/"),
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type vaultCard struct {
+	Token string `json:"token,omitempty"`
+	PAN   string `json:"pan,omitempty"`
+}
+
+func (v *httpVault) Tokenize(pan string) (string, error) {
+	var card vaultCard
+	if err := v.do(http.MethodPost, "/tokens", &vaultCard{PAN: pan}, &card); err != nil {
+		return "", err
+	}
+	if card.Token == "" {
+		return "", errors.New("card vault returned no token")
+	}
+	return card.Token, nil
+}
+
+func (v *httpVault) Detokenize(token string) (string, error) {
+	var card vaultCard
+	if err := v.do(http.MethodGet, "/tokens/"+url.PathEscape(token), nil, &card); err != nil {
+		return "", err
+	}
+	if card.PAN == "" {
+		return "", errors.New("card vault returned no card number")
+	}
+	return card.PAN, nil
+}
+
+func (v *httpVault) Delete(token string) error {
+	err := v.do(http.MethodDelete, "/tokens/"+url.PathEscape(token), nil, nil)
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil
+	}
+	return err
+}
+
+// do sends body, if any, as JSON and decodes the answer into out, if any. A
+// 404 is reported as ErrTokenNotFound.
+func (v *httpVault) do(method, path string, body, out interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, v.base+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+v.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("card vault unreachable: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrTokenNotFound
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return fmt.Errorf("card vault answered %s", res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
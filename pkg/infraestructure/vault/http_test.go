@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestHTTPVault serves an in memory tokenization service over TLS.
+func newTestHTTPVault(t *testing.T) *httpVault {
+	cards := make(map[string]string)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token := strings.TrimPrefix(r.URL.Path, "/v1/tokens/")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/tokens":
+			var card vaultCard
+			json.NewDecoder(r.Body).Decode(&card)
+			token = "tok_" + card.PAN[len(card.PAN)-4:]
+			cards[token] = card.PAN
+			json.NewEncoder(w).Encode(vaultCard{Token: token})
+		case r.Method == http.MethodGet && cards[token] != "":
+			json.NewEncoder(w).Encode(vaultCard{PAN: cards[token]})
+		case r.Method == http.MethodDelete && cards[token] != "":
+			delete(cards, token)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	v, err := NewHTTPVault(srv.URL+"/v1/", "secret")
+	assert.Nil(t, err)
+	hv := v.(*httpVault)
+	hv.client = srv.Client()
+	return hv
+}
+
+func TestHTTPVault(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		v := newTestHTTPVault(t)
+
+		token, err := v.Tokenize("4242424242424242")
+		assert.Nil(t, err)
+		assert.EqualValues(t, "tok_4242", token)
+
+		pan, err := v.Detokenize(token)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "4242424242424242", pan)
+
+		assert.Nil(t, v.Delete(token))
+		_, err = v.Detokenize(token)
+		assert.Equal(t, ErrTokenNotFound, err)
+	})
+
+	t.Run("DeleteUnknownToken", func(t *testing.T) {
+		v := newTestHTTPVault(t)
+
+		assert.Nil(t, v.Delete("tok_0000"))
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		v := newTestHTTPVault(t)
+		v.apiKey = "wrong"
+
+		_, err := v.Tokenize("4242424242424242")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("PlainHTTP", func(t *testing.T) {
+		_, err := NewHTTPVault("http://vault.local", "secret")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("MissingAPIKey", func(t *testing.T) {
+		_, err := NewHTTPVault("https://vault.local", "")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,50 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/FacuBar/bookstore_users-api/pkg/core/ports"
+)
+
+// Settings picks the CardVault backend and configures it.
+type Settings struct {
+	// Backend is either "http", a remote tokenization service, or "file",
+	// a local file only allowed in the development and test environments.
+	Backend string
+	Env     string
+
+	URL    string
+	APIKey string
+	Path   string
+}
+
+// SettingsFromEnv reads the settings from CARD_VAULT, APP_ENV,
+// CARD_VAULT_URL, CARD_VAULT_API_KEY and CARD_VAULT_PATH.
+func SettingsFromEnv() Settings {
+	return Settings{
+		Backend: os.Getenv("CARD_VAULT"),
+		Env:     os.Getenv("APP_ENV"),
+		URL:     os.Getenv("CARD_VAULT_URL"),
+		APIKey:  os.Getenv("CARD_VAULT_API_KEY"),
+		Path:    os.Getenv("CARD_VAULT_PATH"),
+	}
+}
+
+var ErrFileVaultNotAllowed = errors.New("the file card vault is only allowed in development and test")
+
+// New returns the CardVault the settings describe.
+func New(s Settings) (ports.CardVault, error) {
+	switch s.Backend {
+	case "http":
+		return NewHTTPVault(s.URL, s.APIKey)
+	case "file":
+		if s.Env != "development" && s.Env != "test" {
+			return nil, ErrFileVaultNotAllowed
+		}
+		return NewFileVault(s.Path)
+	default:
+		return nil, fmt.Errorf("unknown card vault backend %q, expected http or file", s.Backend)
+	}
+}
@@ -0,0 +1,36 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("FileInDevelopment", func(t *testing.T) {
+		v, err := New(Settings{Backend: "file", Env: "development", Path: t.TempDir() + "/vault.json"})
+		assert.Nil(t, err)
+		assert.NotNil(t, v)
+	})
+
+	t.Run("FileInProduction", func(t *testing.T) {
+		_, err := New(Settings{Backend: "file", Env: "production", Path: t.TempDir() + "/vault.json"})
+		assert.Equal(t, ErrFileVaultNotAllowed, err)
+	})
+
+	t.Run("FileWithoutEnv", func(t *testing.T) {
+		_, err := New(Settings{Backend: "file", Path: t.TempDir() + "/vault.json"})
+		assert.Equal(t, ErrFileVaultNotAllowed, err)
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		v, err := New(Settings{Backend: "http", URL: "https://vault.local", APIKey: "secret"})
+		assert.Nil(t, err)
+		assert.NotNil(t, v)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := New(Settings{})
+		assert.NotNil(t, err)
+	})
+}